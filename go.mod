@@ -1,13 +1,20 @@
 module github.com/corestario/dkglib
 
-go 1.12
+go 1.13
 
 require (
 	github.com/corestario/cosmos-utils/client v0.1.0
 	github.com/cosmos/cosmos-sdk v0.28.2-0.20190827131926-5aacf454e1b6
+	github.com/go-kit/kit v0.9.0
+	github.com/kilic/bls12-381 v0.1.0
+	github.com/prometheus/client_golang v0.9.3
 	github.com/tendermint/go-amino v0.15.1
 	github.com/tendermint/tendermint v0.32.8
+	go.dedis.ch/fixbuf v1.0.3
 	go.dedis.ch/kyber/v3 v3.0.9
+	go.dedis.ch/protobuf v1.0.11
+	golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
+	golang.org/x/sys v0.10.0 // indirect
 )
 
 replace golang.org/x/crypto => github.com/tendermint/crypto v0.0.0-20180820045704-3764759f34a5
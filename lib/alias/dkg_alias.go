@@ -1,8 +1,12 @@
 package alias
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
 
+	"github.com/corestario/dkglib/lib/wire"
 	"github.com/tendermint/go-amino"
 	tmalias "github.com/tendermint/tendermint/alias"
 	"github.com/tendermint/tendermint/crypto"
@@ -24,13 +28,60 @@ const (
 	DKGReconstructCommit
 )
 
+// MaxToIndex bounds DKGData.ToIndex, i.e. the number of DKG participants.
+const MaxToIndex = 10000
+
+// maxDataSize limits the size of the payload of every message type.
+var maxDataSize = map[DKGDataType]int{
+	DKGPubKey:            1 << 10,
+	DKGDeal:              64 << 10,
+	DKGResponse:          1 << 10,
+	DKGJustification:     64 << 10,
+	DKGCommits:           256 << 10,
+	DKGComplaint:         256 << 10,
+	DKGReconstructCommit: 4 << 10,
+}
+
+// payloadKinds lists the wire payload kinds allowed for every message type.
+// On-chain and off-chain DKG use different kyber structures for the same type.
+var payloadKinds = map[DKGDataType][]wire.Kind{
+	DKGPubKey:            {wire.KindPoint},
+	DKGDeal:              {wire.KindRabinDeal, wire.KindPedersenDeal},
+	DKGResponse:          {wire.KindRabinResponse, wire.KindPedersenResponse},
+	DKGJustification:     {wire.KindRabinJustification},
+	DKGCommits:           {wire.KindSecretCommits, wire.KindPoint},
+	DKGComplaint:         {wire.KindComplaintCommits},
+	DKGReconstructCommit: {wire.KindReconstructCommits},
+}
+
+// usesToIndex lists the message types that have ToIndex set.
+var usesToIndex = map[DKGDataType]bool{
+	DKGDeal:              true,
+	DKGCommits:           true,
+	DKGComplaint:         true,
+	DKGReconstructCommit: true,
+}
+
+var (
+	ErrUnknownType          = errors.New("unknown DKG data type")
+	ErrInvalidAddress       = errors.New("invalid address")
+	ErrInvalidRoundID       = errors.New("invalid round ID")
+	ErrEmptyData            = errors.New("empty data")
+	ErrDataTooLarge         = errors.New("data is too large")
+	ErrInvalidPayload       = errors.New("invalid payload")
+	ErrInvalidToIndex       = errors.New("invalid ToIndex")
+	ErrInvalidNumEntities   = errors.New("invalid NumEntities")
+	ErrMissingSignature     = errors.New("missing signature")
+	ErrUnexpectedPayloadLen = errors.New("unexpected payload length")
+)
+
 type DKGData struct {
 	Type        DKGDataType
 	Addr        []byte
 	RoundID     int
-	Data        []byte // Data is going to keep serialized kyber objects.
+	Data        []byte // Data keeps kyber objects serialized with the lib/wire encoding.
 	ToIndex     int    // ID of the participant for whom the message is; might be not set
-	NumEntities int    // Number of sub-entities in the Data array, sometimes required for unmarshaling.
+	NumEntities int    // Number of commitments in the Data array, or of justifications announced by an empty justification.
 	Signature   []byte //Signature for verifying data
 }
 
@@ -58,6 +109,91 @@ func (m *DKGData) GetAddrString() string {
 	return crypto.Address(m.Addr).String()
 }
 
+// ValidateBasic runs stateless checks on the message. The payload is not
+// decoded, only its header and size are checked.
 func (m *DKGData) ValidateBasic() error {
+	maxSize, ok := maxDataSize[m.Type]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownType, m.Type)
+	}
+	if len(m.Addr) != crypto.AddressSize {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidAddress, crypto.AddressSize, len(m.Addr))
+	}
+	if m.RoundID < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidRoundID, m.RoundID)
+	}
+	if len(m.Data) > maxSize {
+		return fmt.Errorf("%w: %d bytes, max %d for type %d", ErrDataTooLarge, len(m.Data), maxSize, m.Type)
+	}
+	if len(m.Data) == 0 {
+		// Justifications, complaints and reconstruct commits are void if
+		// there is nothing to complain about.
+		switch m.Type {
+		case DKGPubKey, DKGDeal, DKGResponse, DKGCommits:
+			return fmt.Errorf("%w: type %d", ErrEmptyData, m.Type)
+		}
+	} else if err := m.validatePayloadHeader(); err != nil {
+		return err
+	}
+	// ToIndex is the receiver of a deal, the position of an on-chain
+	// commitment and the dealer a complaint or a reconstruct commit is
+	// about; other messages do not use it.
+	if m.ToIndex < 0 || m.ToIndex >= MaxToIndex || (!usesToIndex[m.Type] && m.ToIndex != 0) {
+		return fmt.Errorf("%w: %d for type %d", ErrInvalidToIndex, m.ToIndex, m.Type)
+	}
+
+	return m.validateNumEntities()
+}
+
+func (m *DKGData) validatePayloadHeader() error {
+	version, kind, err := wire.Header(m.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if version != wire.Version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidPayload, version)
+	}
+	for _, allowed := range payloadKinds[m.Type] {
+		if kind == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: kind %d is not allowed for type %d", ErrInvalidPayload, kind, m.Type)
+}
+
+// validateNumEntities checks that NumEntities matches the number of
+// commitments in the payload; it must be zero for the other types, except
+// for justification acknowledgements.
+func (m *DKGData) validateNumEntities() error {
+	if m.NumEntities < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidNumEntities, m.NumEntities)
+	}
+	if m.Type == DKGJustification && len(m.Data) == 0 {
+		// The acknowledgement closing the justifications of a participant
+		// holds the number of justifications it has sent.
+		return nil
+	}
+	if m.Type != DKGCommits && m.Type != DKGComplaint {
+		if m.NumEntities != 0 {
+			return fmt.Errorf("%w: %d for type %d", ErrInvalidNumEntities, m.NumEntities, m.Type)
+		}
+		return nil
+	}
+	if len(m.Data) == 0 {
+		return nil
+	}
+	if _, kind, _ := wire.Header(m.Data); kind != wire.KindSecretCommits {
+		return nil
+	}
+	// Secret commits start with the header, the dealer index and the number
+	// of commitments.
+	const countOffset = 2 + 4
+	if len(m.Data) < countOffset+4 {
+		return fmt.Errorf("%w: %d bytes", ErrUnexpectedPayloadLen, len(m.Data))
+	}
+	if count := binary.BigEndian.Uint32(m.Data[countOffset:]); int(count) != m.NumEntities {
+		return fmt.Errorf("%w: %d, payload holds %d commitments", ErrInvalidNumEntities, m.NumEntities, count)
+	}
+
 	return nil
 }
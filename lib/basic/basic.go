@@ -1,14 +1,17 @@
 package basic
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
-	"time"
 
 	"github.com/corestario/cosmos-utils/client/authtypes"
-	"github.com/corestario/cosmos-utils/client/context"
+	clicontext "github.com/corestario/cosmos-utils/client/context"
 	"github.com/corestario/cosmos-utils/client/utils"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/dealer"
 	"github.com/corestario/dkglib/lib/msgs"
 	"github.com/corestario/dkglib/lib/offChain"
 	"github.com/corestario/dkglib/lib/onChain"
@@ -22,9 +25,14 @@ import (
 	"github.com/tendermint/tendermint/libs/events"
 	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tendermint/tendermint/types"
+	"go.dedis.ch/kyber/v3/share"
 )
 
+// ErrStopped is returned by Start once DKGBasic has been stopped.
+var ErrStopped = errors.New("DKG is stopped")
+
 type DKGBasic struct {
+	evsw          events.EventSwitch
 	offChain      *offChain.OffChainDKG
 	onChain       *onChain.OnChainDKG
 	mtx           sync.RWMutex
@@ -33,6 +41,14 @@ type DKGBasic struct {
 	OnChainParams OnChainParams
 	blockNotifier chan bool
 	roundID       int
+	pairingSuite  *blsShare.Suite // The suite of the off-chain rounds.
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	stopped bool
+	wg      sync.WaitGroup
+	results chan OnChainResult
 }
 
 type OnChainParams struct {
@@ -41,6 +57,12 @@ type OnChainParams struct {
 	NodeEndpoint string
 	HomeString   string
 	PassPhrase   string
+	// MaxAttempts is the number of on-chain rounds run before giving up;
+	// a single one if zero.
+	MaxAttempts int
+	// PhaseTimeouts limit the wait for the messages of a phase of an
+	// on-chain round; the blocks are the ones notified to DKGBasic.
+	PhaseTimeouts dealer.PhaseTimeouts
 }
 
 var _ dkg.DKG = &DKGBasic{}
@@ -53,12 +75,19 @@ func NewDKGBasic(
 	passPhrase string,
 	homeString string,
 	options ...offChain.DKGOption,
-) (dkg.DKG, error) {
+) (*DKGBasic, error) {
 	logger := log.NewTMLogger(os.Stdout)
+	ctx, cancel := context.WithCancel(context.Background())
+	offChainDKG := offChain.NewOffChainDKG(evsw, chainID, options...)
 	d := &DKGBasic{
-		offChain:      offChain.NewOffChainDKG(evsw, chainID, options...),
+		evsw:          evsw,
+		offChain:      offChainDKG,
 		logger:        logger,
+		pairingSuite:  offChainDKG.PairingSuite(),
 		blockNotifier: make(chan bool, 2),
+		ctx:           ctx,
+		cancel:        cancel,
+		results:       make(chan OnChainResult, resultsBufferSize),
 		OnChainParams: OnChainParams{
 			Cdc:          cdc,
 			ChainID:      chainID,
@@ -70,6 +99,63 @@ func NewDKGBasic(
 	return d, nil
 }
 
+// resultsBufferSize is the number of on-chain results kept for a reader that
+// is late; newer results are dropped once the buffer is full.
+const resultsBufferSize = 16
+
+// Start binds the on-chain rounds to ctx: they are aborted when ctx is done.
+// DKGBasic works without being started, with rounds that run until Stop.
+func (m *DKGBasic) Start(ctx context.Context) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+	if m.started {
+		return errors.New("DKG is already started")
+	}
+	m.started = true
+	m.cancel()
+	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	return nil
+}
+
+// Stop aborts the on-chain round in progress, waits for it to return,
+// unsubscribes from the chain and closes the results channel. It is safe to
+// call Stop more than once.
+func (m *DKGBasic) Stop() {
+	m.mtx.Lock()
+	if m.stopped {
+		m.mtx.Unlock()
+		return
+	}
+	m.stopped = true
+	m.cancel()
+	m.mtx.Unlock()
+
+	m.wg.Wait()
+	if m.onChain != nil {
+		m.onChain.Stop()
+	}
+	close(m.results)
+}
+
+// Results returns the final outcomes of the switches to on-chain DKG, i.e.
+// the results of their last attempts. The channel is closed by Stop.
+func (m *DKGBasic) Results() <-chan OnChainResult {
+	return m.results
+}
+
+func (m *DKGBasic) report(result OnChainResult) {
+	select {
+	case m.results <- result:
+	default:
+		m.logger.Error("On-chain DKG results are not read, dropping result", "round_id", result.RoundID, "error", result.Err)
+	}
+}
+
 type MockFirer struct{}
 
 func (m *MockFirer) FireEvent(event string, data events.EventData) {}
@@ -85,77 +171,35 @@ func (m *DKGBasic) HandleOffChainShare(
 	height int64,
 	validators *types.ValidatorSet,
 	pubKey crypto.PubKey,
-) bool {
+) (bool, error) {
 	// check if on-chain dkg is running
 	m.mtx.RLock()
 
 	if m.isOnChain {
 		m.mtx.RUnlock()
 		m.logger.Info("On-chain DKG is running, stop off-chain attempt")
-		return false
+		return false, nil
 	}
 	m.mtx.RUnlock()
 
-	switchToOnChain := m.offChain.HandleOffChainShare(dkgMsg, height, validators, pubKey)
+	switchToOnChain, err := m.offChain.HandleOffChainShare(dkgMsg, height, validators, pubKey)
 	// have to switch to on-chain
 	if switchToOnChain {
-		m.logger.Info("Switch to on-chain DKG")
-		m.mtx.Lock()
-		m.isOnChain = true
-		m.mtx.Unlock()
+		switchToOnChain = m.switchToOnChain(validators)
+	}
+
+	return switchToOnChain, err
+}
 
-		err := m.initOnChain()
-		if err != nil {
-			m.logger.Error("could not init On chain dkg", "error", err)
-			return false
-		}
-
-		err = m.onChain.StartRound(
-			validators,
-			m.offChain.GetPrivValidator(),
-			&MockFirer{},
-			m.logger,
-			m.roundID,
-		)
-		if err != nil {
-			m.logger.Info("On-chain DKG start round failed", "error", err)
-			panic(err)
-		}
-		roundID := m.roundID
-		m.roundID++
-
-		go func() {
-			for {
-				select {
-				case <-m.blockNotifier:
-					m.logger.Info("DKG ticker in switch")
-					if err, ok := m.onChain.ProcessBlock(roundID); err != nil {
-						m.logger.Info("on-chain DKG process block failed", "error", err)
-						m.mtx.Lock()
-						m.isOnChain = false
-						m.mtx.Unlock()
-						return
-					} else if ok {
-						m.logger.Info("All instances finished on-chain DKG, O.K.")
-						m.mtx.Lock()
-						m.isOnChain = false
-						m.mtx.Unlock()
-						return
-					}
-				default:
-					time.Sleep(time.Second * 1)
-				}
-			}
-		}()
-		// try on-chain till success
-	}
-
-	// returning bool to implement interface, return value, probably, will not be used
-	return true
-}
-
-func (m *DKGBasic) CheckDKGTime(height int64, validators *types.ValidatorSet) {
-	m.offChain.CheckDKGTime(height, validators)
+// CheckDKGTime switches to on-chain DKG if the retry policy of the off-chain
+// rounds has given up on them.
+func (m *DKGBasic) CheckDKGTime(height int64, validators *types.ValidatorSet) error {
+	err := m.offChain.CheckDKGTime(height, validators)
+	var roundErr *dkg.RoundError
+	if errors.As(err, &roundErr) && roundErr.Fallback {
+		m.switchToOnChain(validators)
+	}
+	return err
 }
 
 func (m *DKGBasic) SetVerifier(verifier dkg.Verifier) {
@@ -170,14 +214,50 @@ func (m *DKGBasic) MsgQueue() chan *dkg.DKGDataMessage {
 	return m.offChain.MsgQueue()
 }
 
-func (m *DKGBasic) GetLosers() []*tmtypes.Validator {
-	return append(m.offChain.GetLosers(), m.onChain.GetLosers()...)
+func (m *DKGBasic) GetLosers() ([]*tmtypes.Validator, error) {
+	losers, err := m.offChain.GetLosers()
+	if m.onChain != nil {
+		losers = append(losers, m.onChain.GetLosers()...)
+	}
+	return losers, err
+}
+
+func (m *DKGBasic) GetLoserProofs() []*dkg.LoserProof {
+	proofs := m.offChain.GetLoserProofs()
+	if m.onChain != nil {
+		proofs = append(proofs, m.onChain.GetLoserProofs()...)
+	}
+	return proofs
+}
+
+func (m *DKGBasic) GetEvidence() []*dkg.EquivocationEvidence {
+	evidence := m.offChain.GetEvidence()
+	if m.onChain != nil {
+		evidence = append(evidence, m.onChain.GetEvidence()...)
+	}
+	return evidence
 }
 
 func (m *DKGBasic) StartDKGRound(validators *tmtypes.ValidatorSet) error {
 	return m.offChain.StartDKGRound(validators)
 }
 
+func (m *DKGBasic) StartReshareRound(oldValidators, validators *tmtypes.ValidatorSet, masterPubKey *share.PubPoly) error {
+	return m.offChain.StartReshareRound(oldValidators, validators, masterPubKey)
+}
+
+func (m *DKGBasic) ScheduleReshareRound(oldValidators, validators *tmtypes.ValidatorSet, masterPubKey *share.PubPoly) error {
+	return m.offChain.ScheduleReshareRound(oldValidators, validators, masterPubKey)
+}
+
+func (m *DKGBasic) StartRefreshRound(validators *tmtypes.ValidatorSet) error {
+	return m.offChain.StartRefreshRound(validators)
+}
+
+func (m *DKGBasic) ScheduleRefreshRound(validators *tmtypes.ValidatorSet) error {
+	return m.offChain.ScheduleRefreshRound(validators)
+}
+
 func (m *DKGBasic) IsOnChain() bool {
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
@@ -191,7 +271,7 @@ func (m *DKGBasic) initOnChain() error {
 
 	m.logger.Info("Init on-chain DKG")
 
-	cliCtx, err := context.NewContextWithDelay(m.OnChainParams.ChainID, m.OnChainParams.NodeEndpoint, m.OnChainParams.HomeString)
+	cliCtx, err := clicontext.NewContextWithDelay(m.OnChainParams.ChainID, m.OnChainParams.NodeEndpoint, m.OnChainParams.HomeString)
 	if err != nil {
 		m.logger.Error("Init on-chain DKG error", "function", "NewContextWithDelay", "error", err)
 		return err
@@ -242,7 +322,10 @@ func (m *DKGBasic) initOnChain() error {
 		nil,
 	).WithKeybase(kb)
 
-	m.onChain = onChain.NewOnChainDKG(cliCtx, &txBldr)
+	m.onChain = onChain.NewOnChainDKG(cliCtx, &txBldr,
+		onChain.WithPairingSuite(m.pairingSuite),
+		onChain.WithPhaseTimeouts(m.OnChainParams.PhaseTimeouts),
+	)
 	return nil
 }
 
@@ -0,0 +1,181 @@
+package basic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	dkg "github.com/corestario/dkglib/lib/types"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/types"
+)
+
+// OnChainResult is the outcome of an attempt of on-chain DKG; Err is nil if
+// the round succeeded. It is fired with EventDKGOnChainAttempt after every
+// attempt and with EventDKGOnChainDone after the last one.
+type OnChainResult struct {
+	RoundID int
+	Attempt int // Counted from 1.
+	// Excluded are the losers of the previous attempts left out of the
+	// round.
+	Excluded []crypto.Address
+	Err      error
+}
+
+// switchToOnChain starts the on-chain rounds and reports whether they have
+// been started. The final outcome is sent to Results.
+func (m *DKGBasic) switchToOnChain(validators *types.ValidatorSet) bool {
+	m.mtx.Lock()
+	if m.stopped {
+		m.mtx.Unlock()
+		m.logger.Info("DKG is stopped, not switching to on-chain DKG")
+		return false
+	}
+	if m.isOnChain {
+		m.mtx.Unlock()
+		return true
+	}
+	m.logger.Info("Switch to on-chain DKG")
+	m.isOnChain = true
+	ctx := m.ctx
+	// Stop must not miss the rounds: they are added while the lock is held.
+	m.wg.Add(1)
+	m.mtx.Unlock()
+
+	go m.runOnChain(ctx, validators)
+
+	return true
+}
+
+// runOnChain runs on-chain rounds until one succeeds, the attempts are
+// exhausted or ctx is done. Every retry gets a new round ID and leaves out
+// the losers of the failed round.
+func (m *DKGBasic) runOnChain(ctx context.Context, validators *types.ValidatorSet) {
+	maxAttempts := m.OnChainParams.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var excluded []crypto.Address
+	for attempt := 1; ; attempt++ {
+		result := OnChainResult{RoundID: m.roundID, Attempt: attempt, Excluded: excluded}
+		result.Err = m.runOnChainRound(ctx, validators)
+		m.evsw.FireEvent(dkg.EventDKGOnChainAttempt, result)
+
+		if result.Err == nil || ctx.Err() != nil || attempt >= maxAttempts {
+			m.finishOnChain(result)
+			return
+		}
+
+		// The on-chain DKG is not there if it could not be initialized:
+		// the attempt has no losers then.
+		var proofs []*dkg.LoserProof
+		if m.onChain != nil {
+			proofs = m.onChain.GetLoserProofs()
+		}
+		m.logger.Info("On-chain DKG round failed, retrying", "round_id", result.RoundID, "attempt", attempt, "losers", len(proofs), "error", result.Err)
+		var losers []crypto.Address
+		var err error
+		if validators, losers, err = m.excludeLosers(validators, proofs); err != nil {
+			result.Err = fmt.Errorf("%v (no retry: %v)", result.Err, err)
+			m.finishOnChain(result)
+			return
+		}
+		excluded = append(excluded, losers...)
+	}
+}
+
+// runOnChainRound starts an on-chain round and makes it handle the messages
+// received since the previous block on every new block, until the round ends
+// or ctx is done.
+func (m *DKGBasic) runOnChainRound(ctx context.Context, validators *types.ValidatorSet) error {
+	roundID := m.roundID
+	if err := m.startOnChainRound(validators); err != nil {
+		m.logger.Error("On-chain DKG start round failed", "error", err)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.logger.Info("On-chain DKG aborted", "round_id", roundID)
+			return ctx.Err()
+		case <-m.blockNotifier:
+			if err, ok := m.onChain.ProcessBlock(roundID); err != nil {
+				m.logger.Info("on-chain DKG process block failed", "error", err)
+				return err
+			} else if ok {
+				m.logger.Info("All instances finished on-chain DKG, O.K.")
+				return nil
+			}
+		}
+	}
+}
+
+func (m *DKGBasic) startOnChainRound(validators *types.ValidatorSet) error {
+	if err := m.initOnChain(); err != nil {
+		return fmt.Errorf("could not init on-chain DKG: %v", err)
+	}
+	roundID := m.roundID
+	// A failed start uses the round ID up too, so that the nodes agree on
+	// the ID of the next attempt.
+	m.roundID++
+
+	return m.onChain.StartRound(
+		validators,
+		m.offChain.GetPrivValidator(),
+		&MockFirer{},
+		m.logger,
+		roundID,
+	)
+}
+
+// excludeLosers returns the validators without the losers and the losers
+// left out. Only the losers whose proofs verify and rest on messages published
+// on chain are left out: every node sees those messages and leaves out the
+// same validators. A deal that fails to verify is only known to the node it
+// was sent to, so its dealer is kept. It fails if the node itself is a loser
+// or nobody is left.
+func (m *DKGBasic) excludeLosers(validators *types.ValidatorSet, proofs []*dkg.LoserProof) (*types.ValidatorSet, []crypto.Address, error) {
+	isLoser := make(map[string]bool, len(proofs))
+	var losers []crypto.Address
+	for _, proof := range proofs {
+		if proof.Reason != dkg.LoserMalformedMessage && proof.Reason != dkg.LoserEquivocation {
+			m.logger.Info("On-chain DKG loser proof is not on chain, keeping the validator", "loser", proof.Addr, "reason", proof.Reason)
+			continue
+		}
+		if err := dkg.VerifyLoserProof(m.pairingSuite, validators, proof); err != nil {
+			m.logger.Info("On-chain DKG loser proof does not verify, keeping the validator", "error", err)
+			continue
+		}
+		if addr := proof.Addr.String(); !isLoser[addr] {
+			isLoser[addr] = true
+			losers = append(losers, proof.Addr)
+		}
+	}
+	if isLoser[m.offChain.GetPrivValidator().GetPubKey().Address().String()] {
+		return nil, nil, errors.New("the node is a loser of the round")
+	}
+
+	var left []*types.Validator
+	for _, validator := range validators.Validators {
+		if !isLoser[validator.Address.String()] {
+			left = append(left, validator.Copy())
+		}
+	}
+	if len(left) == 0 {
+		return nil, nil, errors.New("no validators left")
+	}
+
+	return tmtypes.NewValidatorSet(left), losers, nil
+}
+
+func (m *DKGBasic) finishOnChain(result OnChainResult) {
+	m.mtx.Lock()
+	m.isOnChain = false
+	m.mtx.Unlock()
+	m.evsw.FireEvent(dkg.EventDKGOnChainDone, result)
+	m.report(result)
+	m.wg.Done()
+}
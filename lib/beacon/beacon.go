@@ -0,0 +1,278 @@
+// Package beacon keeps the history of the randomness beacon: the chain of
+// threshold signatures recovered at every height, each one signing the
+// previous one.
+package beacon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/tendermint/tendermint/libs/events"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+)
+
+var (
+	ErrNoEntry    = errors.New("beacon: no entry at the height")
+	ErrNoVerifier = errors.New("beacon: no verifier for the height")
+	// ErrNoPredecessor is returned by Add when the entry of the previous
+	// height is unknown: the chain only grows from an anchor.
+	ErrNoPredecessor = errors.New("beacon: no entry at the previous height")
+)
+
+// Entry is the signature recovered at a height.
+type Entry struct {
+	Height    int64
+	Round     int
+	Signature []byte
+}
+
+// Randomness returns the uniform 32-byte value derived from the signature.
+// Randomness, Uint64 and Range read the same stream, which is seeded with
+// the signature.
+func (e *Entry) Randomness() []byte {
+	out := make([]byte, 32)
+	// The XOF outputs far more than 32 bytes, so the read can not fail.
+	blake2xb.New(e.Signature).Read(out)
+	return out
+}
+
+// Uint64 returns a uniform uint64 derived from the signature; it is the
+// first 8 bytes of Randomness.
+func (e *Entry) Uint64() uint64 {
+	return binary.BigEndian.Uint64(e.Randomness())
+}
+
+// Range returns a uniform number in [0, n) derived from the signature.
+func (e *Entry) Range(n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, errors.New("beacon: empty range")
+	}
+	// Values above the largest multiple of n are rejected, so that every
+	// number of the range is equally likely.
+	var (
+		xof   = blake2xb.New(e.Signature)
+		limit = ^uint64(0) - (^uint64(0) % n)
+		buf   = make([]byte, 8)
+	)
+	for {
+		if _, err := xof.Read(buf); err != nil {
+			return 0, fmt.Errorf("beacon: failed to read randomness: %v", err)
+		}
+		if v := binary.BigEndian.Uint64(buf); v < limit {
+			return v % n, nil
+		}
+	}
+}
+
+type keyEpoch struct {
+	from     int64
+	verifier types.Verifier
+}
+
+// DefaultRetention is the number of heights a Beacon keeps the entries of by
+// default.
+const DefaultRetention = 10000
+
+// Beacon is the in-memory history of the beacon.
+type Beacon struct {
+	mtx       sync.RWMutex
+	entries   map[int64]*Entry
+	latest    *Entry
+	epochs    []keyEpoch // Sorted by the height the key becomes active at.
+	retention int64
+}
+
+// Option sets an optional parameter on the Beacon.
+type Option func(*Beacon)
+
+// WithRetention makes the beacon keep the entries of the latest heights
+// heights only; the older ones are dropped as the chain grows. Zero keeps
+// every entry.
+func WithRetention(heights int64) Option {
+	return func(b *Beacon) { b.retention = heights }
+}
+
+func New(options ...Option) *Beacon {
+	b := &Beacon{entries: make(map[int64]*Entry), retention: DefaultRetention}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// SetVerifier registers the verifier of the key that is active from the
+// height on. A negative height stands for the height following the latest
+// entry.
+func (b *Beacon) SetVerifier(height int64, verifier types.Verifier) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if height < 0 {
+		height = 0
+		if b.latest != nil {
+			height = b.latest.Height + 1
+		}
+	}
+	idx := sort.Search(len(b.epochs), func(i int) bool { return b.epochs[i].from >= height })
+	if idx < len(b.epochs) && b.epochs[idx].from == height {
+		b.epochs[idx].verifier = verifier
+		return
+	}
+	b.epochs = append(b.epochs, keyEpoch{})
+	copy(b.epochs[idx+1:], b.epochs[idx:])
+	b.epochs[idx] = keyEpoch{from: height, verifier: verifier}
+}
+
+// ListenKeyChanges registers the verifier of the DKG on every key change.
+func (b *Beacon) ListenKeyChanges(evsw events.EventSwitch, dkg types.DKG) error {
+	return evsw.AddListenerForEvent("beacon", types.EventDKGKeyChange, func(data events.EventData) {
+		height, ok := data.(int64)
+		if !ok {
+			return
+		}
+		if verifier := dkg.Verifier(); verifier != nil && !verifier.IsNil() {
+			b.SetVerifier(height, verifier)
+		}
+	})
+}
+
+// Anchor adds an entry without verifying it, e.g. the genesis seed or a
+// checkpoint the node has from a source it trusts. The chain grows from it.
+func (b *Beacon) Anchor(height int64, round int, signature []byte) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if _, ok := b.entries[height]; ok {
+		return fmt.Errorf("beacon: entry at height %d already exists", height)
+	}
+	b.insert(&Entry{Height: height, Round: round, Signature: signature})
+
+	return nil
+}
+
+// Add appends the signature recovered at the height. The entry of the
+// previous height must be known and signed by it, so every entry is linked
+// to an anchor; if the next entry is known, it must sign the new one.
+func (b *Beacon) Add(height int64, round int, signature []byte) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if _, ok := b.entries[height]; ok {
+		return fmt.Errorf("beacon: entry at height %d already exists", height)
+	}
+	prev, ok := b.entries[height-1]
+	if !ok {
+		return fmt.Errorf("beacon: height %d: %w", height, ErrNoPredecessor)
+	}
+	entry := &Entry{Height: height, Round: round, Signature: signature}
+	if err := b.verifyLink(prev, entry); err != nil {
+		return err
+	}
+	if next, ok := b.entries[height+1]; ok {
+		if err := b.verifyLink(entry, next); err != nil {
+			return err
+		}
+	}
+	b.insert(entry)
+
+	return nil
+}
+
+func (b *Beacon) insert(entry *Entry) {
+	b.entries[entry.Height] = entry
+	if b.latest == nil || b.latest.Height < entry.Height {
+		b.latest = entry
+	}
+	b.prune()
+}
+
+// prune drops the entries that have fallen out of the retention window and
+// the keys no kept entry is signed with.
+func (b *Beacon) prune() {
+	if b.retention <= 0 || int64(len(b.entries)) <= b.retention {
+		return
+	}
+	oldest := b.latest.Height - b.retention + 1
+	for height := range b.entries {
+		if height < oldest {
+			delete(b.entries, height)
+		}
+	}
+	// The first epoch kept is the one active at the oldest height.
+	idx := sort.Search(len(b.epochs), func(i int) bool { return b.epochs[i].from > oldest })
+	if idx > 1 {
+		b.epochs = append(b.epochs[:0], b.epochs[idx-1:]...)
+	}
+}
+
+// Latest returns the entry of the highest height.
+func (b *Beacon) Latest() (*Entry, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if b.latest == nil {
+		return nil, ErrNoEntry
+	}
+	return b.latest, nil
+}
+
+func (b *Beacon) At(height int64) (*Entry, error) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	entry, ok := b.entries[height]
+	if !ok {
+		return nil, ErrNoEntry
+	}
+	return entry, nil
+}
+
+// VerifyChain checks every link between the heights, each one with the key
+// active at the height of its signature.
+func (b *Beacon) VerifyChain(from, to int64) error {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if from > to {
+		return fmt.Errorf("beacon: invalid range [%d, %d]", from, to)
+	}
+	prev, ok := b.entries[from]
+	if !ok {
+		return fmt.Errorf("beacon: height %d: %w", from, ErrNoEntry)
+	}
+	for height := from + 1; height <= to; height++ {
+		entry, ok := b.entries[height]
+		if !ok {
+			return fmt.Errorf("beacon: height %d: %w", height, ErrNoEntry)
+		}
+		if err := b.verifyLink(prev, entry); err != nil {
+			return err
+		}
+		prev = entry
+	}
+
+	return nil
+}
+
+func (b *Beacon) verifyLink(prev, entry *Entry) error {
+	verifier := b.verifierAt(entry.Height)
+	if verifier == nil {
+		return fmt.Errorf("beacon: height %d: %w", entry.Height, ErrNoVerifier)
+	}
+	if err := verifier.VerifyRandomData(prev.Signature, entry.Signature); err != nil {
+		return fmt.Errorf("beacon: height %d: %v", entry.Height, err)
+	}
+	return nil
+}
+
+func (b *Beacon) verifierAt(height int64) types.Verifier {
+	idx := sort.Search(len(b.epochs), func(i int) bool { return b.epochs[i].from > height })
+	if idx == 0 {
+		return nil
+	}
+	return b.epochs[idx-1].verifier
+}
@@ -0,0 +1,194 @@
+package beacon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/types"
+)
+
+// keyVerifier signs by hashing the data with its key.
+type keyVerifier struct {
+	types.MockVerifier
+	key string
+}
+
+func (v *keyVerifier) Sign(data []byte) ([]byte, error) {
+	h := sha256.Sum256(append([]byte(v.key), data...))
+	return h[:], nil
+}
+
+func (v *keyVerifier) VerifyRandomData(prev, curr []byte) error {
+	sig, _ := v.Sign(prev)
+	if !bytes.Equal(sig, curr) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (v *keyVerifier) IsNil() bool { return false }
+
+// grow adds the entries of the heights from to to, each one signing the
+// previous one with the key active at its height.
+func grow(t *testing.T, b *Beacon, keys map[int64]*keyVerifier, from, to int64) {
+	t.Helper()
+	for height := from; height <= to; height++ {
+		prev, err := b.At(height - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, _ := keyAt(keys, height).Sign(prev.Signature)
+		if err := b.Add(height, 1, sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func keyAt(keys map[int64]*keyVerifier, height int64) *keyVerifier {
+	var from int64 = -1
+	for h := range keys {
+		if h <= height && h > from {
+			from = h
+		}
+	}
+	return keys[from]
+}
+
+func TestAdd(t *testing.T) {
+	b := New()
+	key := &keyVerifier{key: "a"}
+	b.SetVerifier(0, key)
+
+	sig, _ := key.Sign([]byte("seed"))
+	if err := b.Add(1, 1, sig); !errors.Is(err, ErrNoPredecessor) {
+		t.Fatalf("expected ErrNoPredecessor, got %v", err)
+	}
+	if err := b.Anchor(0, 0, []byte("seed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(1, 1, []byte("forged")); err == nil {
+		t.Fatal("a forged entry was added")
+	}
+	grow(t, b, map[int64]*keyVerifier{0: key}, 1, 3)
+	if err := b.Add(3, 1, sig); err == nil {
+		t.Fatal("an entry was replaced")
+	}
+
+	latest, err := b.Latest()
+	if err != nil || latest.Height != 3 {
+		t.Fatalf("Latest() = %v, %v", latest, err)
+	}
+	if err := b.VerifyChain(0, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.VerifyChain(0, 4); !errors.Is(err, ErrNoEntry) {
+		t.Fatalf("expected ErrNoEntry, got %v", err)
+	}
+
+	// A new entry must also be signed by the following one.
+	b = New()
+	b.SetVerifier(0, key)
+	if err := b.Anchor(0, 0, []byte("seed")); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := key.Sign([]byte("seed"))
+	second, _ := key.Sign(first)
+	if err := b.Anchor(2, 1, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(1, 1, []byte("forged")); err == nil {
+		t.Fatal("a forged entry was added")
+	}
+	if err := b.Add(1, 1, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.VerifyChain(0, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyEpochs(t *testing.T) {
+	b := New()
+	keys := map[int64]*keyVerifier{0: {key: "a"}, 3: {key: "b"}}
+	b.SetVerifier(0, keys[0])
+	if err := b.Anchor(0, 0, []byte("seed")); err != nil {
+		t.Fatal(err)
+	}
+	grow(t, b, keys, 1, 2)
+
+	// Height 3 is signed with the key of the next epoch, which is not known
+	// yet.
+	prev, _ := b.At(2)
+	sig, _ := keys[3].Sign(prev.Signature)
+	if err := b.Add(3, 2, sig); err == nil {
+		t.Fatal("an entry signed with an unknown key was added")
+	}
+	b.SetVerifier(-1, keys[3])
+	grow(t, b, keys, 3, 5)
+	if err := b.VerifyChain(0, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the key of an epoch breaks its links.
+	b.SetVerifier(3, &keyVerifier{key: "c"})
+	if err := b.VerifyChain(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.VerifyChain(2, 3); err == nil {
+		t.Fatal("a link verified with another key")
+	}
+	if err := b.VerifyChain(3, 1); err == nil {
+		t.Fatal("an empty range verified")
+	}
+}
+
+func TestRetention(t *testing.T) {
+	b := New(WithRetention(4))
+	keys := map[int64]*keyVerifier{0: {key: "a"}, 3: {key: "b"}, 6: {key: "c"}}
+	for from, key := range keys {
+		b.SetVerifier(from, key)
+	}
+	if err := b.Anchor(0, 0, []byte("seed")); err != nil {
+		t.Fatal(err)
+	}
+	grow(t, b, keys, 1, 10)
+
+	if len(b.entries) != 4 {
+		t.Fatalf("%d entries kept, want 4", len(b.entries))
+	}
+	if _, err := b.At(6); err != ErrNoEntry {
+		t.Fatalf("expected ErrNoEntry, got %v", err)
+	}
+	if err := b.VerifyChain(7, 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.epochs) != 1 || b.epochs[0].verifier != keys[6] {
+		t.Fatalf("%d epochs kept, want 1", len(b.epochs))
+	}
+}
+
+func TestRandomness(t *testing.T) {
+	e := &Entry{Signature: []byte("signature")}
+	if !bytes.Equal(e.Randomness(), e.Randomness()) {
+		t.Fatal("the randomness is not deterministic")
+	}
+	if bytes.Equal(e.Randomness(), (&Entry{Signature: []byte("other")}).Randomness()) {
+		t.Fatal("different signatures give the same randomness")
+	}
+	if _, err := e.Range(0); err == nil {
+		t.Fatal("an empty range was accepted")
+	}
+	// Range reads the stream Uint64 is taken from: with no rejection, the
+	// first value is Uint64 modulo n.
+	for _, n := range []uint64{1, 2, 7, 1000} {
+		v, err := e.Range(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v >= n || v != e.Uint64()%n {
+			t.Fatalf("Range(%d) = %d, Uint64() = %d", n, v, e.Uint64())
+		}
+	}
+}
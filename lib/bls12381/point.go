@@ -0,0 +1,370 @@
+package bls12381
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+
+	bls "github.com/kilic/bls12-381"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/mod"
+)
+
+// Domain is the domain separation tag G1 hashes messages with; it is the
+// one of the basic scheme of the IETF BLS signature draft.
+var Domain = []byte("BLS_SIG_BLS12381G1_XMD:SHA-256_SSWU_RO_NUL_")
+
+// The kilic groups keep scratch space, so every operation takes its own.
+
+type pointG1 struct {
+	p *bls.PointG1
+}
+
+func newPointG1() *pointG1 {
+	return &pointG1{p: bls.NewG1().Zero()}
+}
+
+func (p *pointG1) Equal(q kyber.Point) bool {
+	return bls.NewG1().Equal(p.p, q.(*pointG1).p)
+}
+
+func (p *pointG1) Null() kyber.Point {
+	p.p.Zero()
+	return p
+}
+
+func (p *pointG1) Base() kyber.Point {
+	p.p.Set(bls.NewG1().One())
+	return p
+}
+
+func (p *pointG1) Pick(rand cipher.Stream) kyber.Point {
+	return p.Mul(newScalar().Pick(rand), nil)
+}
+
+func (p *pointG1) Set(q kyber.Point) kyber.Point {
+	p.p.Set(q.(*pointG1).p)
+	return p
+}
+
+func (p *pointG1) Clone() kyber.Point {
+	return &pointG1{p: new(bls.PointG1).Set(p.p)}
+}
+
+func (p *pointG1) EmbedLen() int {
+	panic("bls12-381.G1: unsupported operation")
+}
+
+func (p *pointG1) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	panic("bls12-381.G1: unsupported operation")
+}
+
+func (p *pointG1) Data() ([]byte, error) {
+	panic("bls12-381.G1: unsupported operation")
+}
+
+func (p *pointG1) Add(a, b kyber.Point) kyber.Point {
+	bls.NewG1().Add(p.p, a.(*pointG1).p, b.(*pointG1).p)
+	return p
+}
+
+func (p *pointG1) Sub(a, b kyber.Point) kyber.Point {
+	bls.NewG1().Sub(p.p, a.(*pointG1).p, b.(*pointG1).p)
+	return p
+}
+
+func (p *pointG1) Neg(q kyber.Point) kyber.Point {
+	bls.NewG1().Neg(p.p, q.(*pointG1).p)
+	return p
+}
+
+func (p *pointG1) Mul(s kyber.Scalar, q kyber.Point) kyber.Point {
+	if q == nil {
+		q = newPointG1().Base()
+	}
+	bls.NewG1().MulScalarBig(p.p, q.(*pointG1).p, scalarBig(s))
+	return p
+}
+
+// Hash maps the message to a point of G1 (hash-to-curve, random oracle
+// variant), as BLS signatures need.
+func (p *pointG1) Hash(msg []byte) kyber.Point {
+	h, err := bls.NewG1().HashToCurve(msg, Domain)
+	if err != nil {
+		panic(err)
+	}
+	p.p.Set(h)
+	return p
+}
+
+func (p *pointG1) MarshalBinary() ([]byte, error) {
+	// ToCompressed normalizes its argument, so it gets a copy.
+	return bls.NewG1().ToCompressed(new(bls.PointG1).Set(p.p)), nil
+}
+
+func (p *pointG1) UnmarshalBinary(buf []byte) error {
+	// FromCompressed checks that the point is in the prime order subgroup.
+	q, err := bls.NewG1().FromCompressed(buf)
+	if err != nil {
+		return err
+	}
+	p.p.Set(q)
+	return nil
+}
+
+func (p *pointG1) MarshalSize() int {
+	return 48
+}
+
+func (p *pointG1) MarshalTo(w io.Writer) (int, error) {
+	return marshalTo(p, w)
+}
+
+func (p *pointG1) UnmarshalFrom(r io.Reader) (int, error) {
+	return unmarshalFrom(p, r)
+}
+
+func (p *pointG1) String() string {
+	return pointString("bls12-381.G1", p)
+}
+
+type pointG2 struct {
+	p *bls.PointG2
+}
+
+func newPointG2() *pointG2 {
+	return &pointG2{p: bls.NewG2().Zero()}
+}
+
+func (p *pointG2) Equal(q kyber.Point) bool {
+	return bls.NewG2().Equal(p.p, q.(*pointG2).p)
+}
+
+func (p *pointG2) Null() kyber.Point {
+	p.p.Zero()
+	return p
+}
+
+func (p *pointG2) Base() kyber.Point {
+	p.p.Set(bls.NewG2().One())
+	return p
+}
+
+func (p *pointG2) Pick(rand cipher.Stream) kyber.Point {
+	return p.Mul(newScalar().Pick(rand), nil)
+}
+
+func (p *pointG2) Set(q kyber.Point) kyber.Point {
+	p.p.Set(q.(*pointG2).p)
+	return p
+}
+
+func (p *pointG2) Clone() kyber.Point {
+	return &pointG2{p: new(bls.PointG2).Set(p.p)}
+}
+
+func (p *pointG2) EmbedLen() int {
+	panic("bls12-381.G2: unsupported operation")
+}
+
+func (p *pointG2) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	panic("bls12-381.G2: unsupported operation")
+}
+
+func (p *pointG2) Data() ([]byte, error) {
+	panic("bls12-381.G2: unsupported operation")
+}
+
+func (p *pointG2) Add(a, b kyber.Point) kyber.Point {
+	bls.NewG2().Add(p.p, a.(*pointG2).p, b.(*pointG2).p)
+	return p
+}
+
+func (p *pointG2) Sub(a, b kyber.Point) kyber.Point {
+	bls.NewG2().Sub(p.p, a.(*pointG2).p, b.(*pointG2).p)
+	return p
+}
+
+func (p *pointG2) Neg(q kyber.Point) kyber.Point {
+	bls.NewG2().Neg(p.p, q.(*pointG2).p)
+	return p
+}
+
+func (p *pointG2) Mul(s kyber.Scalar, q kyber.Point) kyber.Point {
+	if q == nil {
+		q = newPointG2().Base()
+	}
+	bls.NewG2().MulScalarBig(p.p, q.(*pointG2).p, scalarBig(s))
+	return p
+}
+
+func (p *pointG2) MarshalBinary() ([]byte, error) {
+	return bls.NewG2().ToCompressed(new(bls.PointG2).Set(p.p)), nil
+}
+
+func (p *pointG2) UnmarshalBinary(buf []byte) error {
+	q, err := bls.NewG2().FromCompressed(buf)
+	if err != nil {
+		return err
+	}
+	p.p.Set(q)
+	return nil
+}
+
+func (p *pointG2) MarshalSize() int {
+	return 96
+}
+
+func (p *pointG2) MarshalTo(w io.Writer) (int, error) {
+	return marshalTo(p, w)
+}
+
+func (p *pointG2) UnmarshalFrom(r io.Reader) (int, error) {
+	return unmarshalFrom(p, r)
+}
+
+func (p *pointG2) String() string {
+	return pointString("bls12-381.G2", p)
+}
+
+// pointGT is an element of the target group. The group is multiplicative,
+// so Add multiplies and Mul exponentiates.
+type pointGT struct {
+	e *bls.E
+}
+
+func newPointGT() *pointGT {
+	return &pointGT{e: bls.NewGT().New()}
+}
+
+// Pair sets p to the pairing of a from G1 and b from G2.
+func (p *pointGT) Pair(a, b kyber.Point) kyber.Point {
+	// The engine normalizes the points it is given, so it gets copies.
+	g1 := new(bls.PointG1).Set(a.(*pointG1).p)
+	g2 := new(bls.PointG2).Set(b.(*pointG2).p)
+	p.e.Set(bls.NewEngine().AddPair(g1, g2).Result())
+	return p
+}
+
+func (p *pointGT) Equal(q kyber.Point) bool {
+	return p.e.Equal(q.(*pointGT).e)
+}
+
+func (p *pointGT) Null() kyber.Point {
+	p.e.Set(bls.NewGT().New())
+	return p
+}
+
+func (p *pointGT) Base() kyber.Point {
+	return p.Pair(newPointG1().Base(), newPointG2().Base())
+}
+
+func (p *pointGT) Pick(rand cipher.Stream) kyber.Point {
+	return p.Mul(newScalar().Pick(rand), nil)
+}
+
+func (p *pointGT) Set(q kyber.Point) kyber.Point {
+	p.e.Set(q.(*pointGT).e)
+	return p
+}
+
+func (p *pointGT) Clone() kyber.Point {
+	return &pointGT{e: new(bls.E).Set(p.e)}
+}
+
+func (p *pointGT) EmbedLen() int {
+	panic("bls12-381.GT: unsupported operation")
+}
+
+func (p *pointGT) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	panic("bls12-381.GT: unsupported operation")
+}
+
+func (p *pointGT) Data() ([]byte, error) {
+	panic("bls12-381.GT: unsupported operation")
+}
+
+func (p *pointGT) Add(a, b kyber.Point) kyber.Point {
+	bls.NewGT().Mul(p.e, a.(*pointGT).e, b.(*pointGT).e)
+	return p
+}
+
+func (p *pointGT) Sub(a, b kyber.Point) kyber.Point {
+	inv := bls.NewGT().New()
+	bls.NewGT().Inverse(inv, b.(*pointGT).e)
+	bls.NewGT().Mul(p.e, a.(*pointGT).e, inv)
+	return p
+}
+
+func (p *pointGT) Neg(q kyber.Point) kyber.Point {
+	bls.NewGT().Inverse(p.e, q.(*pointGT).e)
+	return p
+}
+
+func (p *pointGT) Mul(s kyber.Scalar, q kyber.Point) kyber.Point {
+	if q == nil {
+		q = newPointGT().Base()
+	}
+	bls.NewGT().Exp(p.e, q.(*pointGT).e, scalarBig(s))
+	return p
+}
+
+func (p *pointGT) MarshalBinary() ([]byte, error) {
+	return bls.NewGT().ToBytes(p.e), nil
+}
+
+func (p *pointGT) UnmarshalBinary(buf []byte) error {
+	if len(buf) != p.MarshalSize() {
+		return errors.New("bls12-381.GT: invalid length")
+	}
+	e, err := bls.NewGT().FromBytes(buf)
+	if err != nil {
+		return err
+	}
+	p.e.Set(e)
+	return nil
+}
+
+func (p *pointGT) MarshalSize() int {
+	return 576
+}
+
+func (p *pointGT) MarshalTo(w io.Writer) (int, error) {
+	return marshalTo(p, w)
+}
+
+func (p *pointGT) UnmarshalFrom(r io.Reader) (int, error) {
+	return unmarshalFrom(p, r)
+}
+
+func (p *pointGT) String() string {
+	return pointString("bls12-381.GT", p)
+}
+
+func scalarBig(s kyber.Scalar) *big.Int {
+	return &s.(*mod.Int).V
+}
+
+func marshalTo(p kyber.Point, w io.Writer) (int, error) {
+	buf, err := p.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(buf)
+}
+
+func unmarshalFrom(p kyber.Point, r io.Reader) (int, error) {
+	buf := make([]byte, p.MarshalSize())
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return n, err
+	}
+	return n, p.UnmarshalBinary(buf)
+}
+
+func pointString(group string, p kyber.Point) string {
+	buf, _ := p.MarshalBinary()
+	return group + ":" + hex.EncodeToString(buf)
+}
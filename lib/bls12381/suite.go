@@ -0,0 +1,146 @@
+// Package bls12381 adapts the BLS12-381 curve of github.com/kilic/bls12-381
+// to the kyber pairing interfaces, the same way kyber's bn256 package exposes
+// BN256. Points are serialized in the compressed zcash format, and G1 hashes
+// messages with the hash-to-curve suite of the IETF BLS signature draft.
+package bls12381
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"reflect"
+
+	bls "github.com/kilic/bls12-381"
+	"go.dedis.ch/fixbuf"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+)
+
+// Order is the order of the three groups.
+var Order = bls.NewG1().Q()
+
+// Suite implements pairing.Suite for the BLS12-381 pairing.
+type Suite struct {
+	*commonSuite
+	g1 *groupG1
+	g2 *groupG2
+	gt *groupGT
+}
+
+// NewSuite returns a BLS12-381 pairing suite.
+func NewSuite() *Suite {
+	s := &Suite{commonSuite: &commonSuite{}}
+	s.g1 = &groupG1{commonSuite: s.commonSuite}
+	s.g2 = &groupG2{commonSuite: s.commonSuite}
+	s.gt = &groupGT{commonSuite: s.commonSuite}
+	return s
+}
+
+// NewSuiteG1 returns a suite with G1 as its default group.
+func NewSuiteG1() *Suite {
+	s := NewSuite()
+	s.commonSuite.Group = &groupG1{commonSuite: &commonSuite{}}
+	return s
+}
+
+// NewSuiteG2 returns a suite with G2 as its default group.
+func NewSuiteG2() *Suite {
+	s := NewSuite()
+	s.commonSuite.Group = &groupG2{commonSuite: &commonSuite{}}
+	return s
+}
+
+// NewSuiteGT returns a suite with GT as its default group.
+func NewSuiteGT() *Suite {
+	s := NewSuite()
+	s.commonSuite.Group = &groupGT{commonSuite: &commonSuite{}}
+	return s
+}
+
+// G1 returns the group G1 of the pairing.
+func (s *Suite) G1() kyber.Group {
+	return s.g1
+}
+
+// G2 returns the group G2 of the pairing.
+func (s *Suite) G2() kyber.Group {
+	return s.g2
+}
+
+// GT returns the target group of the pairing.
+func (s *Suite) GT() kyber.Group {
+	return s.gt
+}
+
+// Pair computes the pairing of p1 from G1 and p2 from G2.
+func (s *Suite) Pair(p1, p2 kyber.Point) kyber.Point {
+	return newPointGT().Pair(p1, p2)
+}
+
+var (
+	tScalar  = reflect.TypeOf((*kyber.Scalar)(nil)).Elem()
+	tPoint   = reflect.TypeOf((*kyber.Point)(nil)).Elem()
+	tPointG1 = reflect.TypeOf(pointG1{})
+	tPointG2 = reflect.TypeOf(pointG2{})
+	tPointGT = reflect.TypeOf(pointGT{})
+)
+
+type commonSuite struct {
+	// Group is only set for the suites with a default group.
+	kyber.Group
+}
+
+// New implements kyber.Encoding.
+func (c *commonSuite) New(t reflect.Type) interface{} {
+	switch t {
+	case tScalar:
+		return newScalar()
+	case tPoint:
+		if c.Group == nil {
+			panic("bls12381: the suite has no default group")
+		}
+		return c.Point()
+	case tPointG1:
+		return newPointG1()
+	case tPointG2:
+		return newPointG2()
+	case tPointGT:
+		return newPointGT()
+	}
+	return nil
+}
+
+// Read implements kyber.Encoding.
+func (c *commonSuite) Read(r io.Reader, objs ...interface{}) error {
+	return fixbuf.Read(r, c, objs...)
+}
+
+// Write implements kyber.Encoding.
+func (c *commonSuite) Write(w io.Writer, objs ...interface{}) error {
+	return fixbuf.Write(w, objs)
+}
+
+// Hash returns a new sha256 hash.
+func (c *commonSuite) Hash() hash.Hash {
+	return sha256.New()
+}
+
+// XOF returns a new blake2xb XOF seeded with seed.
+func (c *commonSuite) XOF(seed []byte) kyber.XOF {
+	return blake2xb.New(seed)
+}
+
+// RandomStream returns a stream backed by crypto/rand.
+func (c *commonSuite) RandomStream() cipher.Stream {
+	return random.New()
+}
+
+// String returns the name of the default group, or of the curve.
+func (c *commonSuite) String() string {
+	if c.Group != nil {
+		return c.Group.String()
+	}
+	return "bls12-381"
+}
@@ -0,0 +1,203 @@
+package bls12381
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// fieldModulus is the prime of the base field.
+var fieldModulus, _ = new(big.Int).SetString("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab", 16)
+
+func TestScalarEncoding(t *testing.T) {
+	suite := NewSuite()
+	for i := 0; i < 10; i++ {
+		s := suite.G1().Scalar().Pick(suite.RandomStream())
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != suite.G1().ScalarLen() {
+			t.Fatalf("%d bytes, want %d", len(data), suite.G1().ScalarLen())
+		}
+		out := suite.G2().Scalar()
+		if err := out.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !out.Equal(s) {
+			t.Fatal("the scalar changed in a round trip")
+		}
+	}
+}
+
+func TestPointEncoding(t *testing.T) {
+	suite := NewSuite()
+	random := func(g kyber.Group) kyber.Point { return g.Point().Pick(suite.RandomStream()) }
+	gt := func() kyber.Point { return suite.Pair(random(suite.G1()), random(suite.G2())) }
+	hash := func() kyber.Point { return newPointG1().Hash([]byte("message")) }
+
+	for name, tc := range map[string]struct {
+		group kyber.Group
+		point func() kyber.Point
+	}{
+		"G1":      {suite.G1(), func() kyber.Point { return random(suite.G1()) }},
+		"G2":      {suite.G2(), func() kyber.Point { return random(suite.G2()) }},
+		"GT":      {suite.GT(), gt},
+		"G1 null": {suite.G1(), func() kyber.Point { return suite.G1().Point().Null() }},
+		"G2 null": {suite.G2(), func() kyber.Point { return suite.G2().Point().Null() }},
+		"GT null": {suite.GT(), func() kyber.Point { return suite.GT().Point().Null() }},
+		"G1 hash": {suite.G1(), hash},
+	} {
+		p := tc.point()
+		data, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(data) != tc.group.PointLen() {
+			t.Fatalf("%s: %d bytes, want %d", name, len(data), tc.group.PointLen())
+		}
+		out := tc.group.Point()
+		if err := out.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !out.Equal(p) {
+			t.Fatalf("%s: the point changed in a round trip", name)
+		}
+		if err := out.UnmarshalBinary(data[1:]); err == nil {
+			t.Fatalf("%s: a short encoding was accepted", name)
+		}
+	}
+}
+
+// onCurve returns the compressed encoding of an x coordinate for which the
+// curve equation has a solution: the point is on the curve, but almost surely
+// out of the prime order subgroup. With residue false, it returns one for
+// which there is no solution.
+func onCurve(t *testing.T, g2, residue bool) []byte {
+	t.Helper()
+	p := fieldModulus
+	for {
+		x, err := rand.Int(rand.Reader, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// y^2 = x^3 + 4 on G1. On G2 the twist is y^2 = x^3 + 4(1+i), taken at
+		// x = a + ai to make the order of the coordinates irrelevant; an
+		// element of Fp2 is a square if and only if its norm is one in Fp.
+		var rhs *big.Int
+		if !g2 {
+			rhs = new(big.Int).Exp(x, big.NewInt(3), p)
+			rhs.Add(rhs, big.NewInt(4))
+		} else {
+			// (a + ai)^3 = 2a^3(-1 + i)
+			c := new(big.Int).Exp(x, big.NewInt(3), p)
+			c.Lsh(c, 1)
+			re := new(big.Int).Sub(big.NewInt(4), c)
+			im := new(big.Int).Add(big.NewInt(4), c)
+			rhs = new(big.Int).Add(re.Mul(re, re), im.Mul(im, im))
+		}
+		rhs.Mod(rhs, p)
+		if (big.Jacobi(rhs, p) == 1) != residue {
+			continue
+		}
+
+		coord := fieldBytes(x)
+		out := coord
+		if g2 {
+			out = append(append([]byte(nil), coord...), coord...)
+		}
+		out[0] |= 0x80 // Compressed.
+		return out
+	}
+}
+
+// fieldBytes encodes an element of the base field in 48 bytes.
+func fieldBytes(x *big.Int) []byte {
+	out := make([]byte, 48)
+	b := x.Bytes()
+	copy(out[len(out)-len(b):], b)
+	return out
+}
+
+func TestSubgroupRejection(t *testing.T) {
+	suite := NewSuite()
+	for name, group := range map[string]kyber.Group{"G1": suite.G1(), "G2": suite.G2()} {
+		g2 := name == "G2"
+		for i := 0; i < 5; i++ {
+			err := group.Point().UnmarshalBinary(onCurve(t, g2, true))
+			if err == nil || !strings.Contains(err.Error(), "subgroup") {
+				t.Fatalf("%s: expected a subgroup error, got %v", name, err)
+			}
+			err = group.Point().UnmarshalBinary(onCurve(t, g2, false))
+			if err == nil || !strings.Contains(err.Error(), "not on curve") {
+				t.Fatalf("%s: expected a curve error, got %v", name, err)
+			}
+		}
+	}
+}
+
+func TestGTFromBytes(t *testing.T) {
+	suite := NewSuite()
+	size := suite.GT().PointLen()
+
+	if err := suite.GT().Point().UnmarshalBinary(make([]byte, size-1)); err == nil {
+		t.Fatal("a short element was accepted")
+	}
+
+	// Random elements of Fp12 are not in the subgroup of order r.
+	data := make([]byte, 0, size)
+	for len(data) < size {
+		coeff, err := rand.Int(rand.Reader, fieldModulus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, fieldBytes(coeff)...)
+	}
+	if err := suite.GT().Point().UnmarshalBinary(data); err == nil {
+		t.Fatal("an element out of the subgroup was accepted")
+	}
+
+	// Nor are coefficients out of the field.
+	for i := range data {
+		data[i] = 0xff
+	}
+	if err := suite.GT().Point().UnmarshalBinary(data); err == nil {
+		t.Fatal("an element out of the field was accepted")
+	}
+}
+
+func TestPairing(t *testing.T) {
+	suite := NewSuite()
+	a := suite.G1().Scalar().Pick(suite.RandomStream())
+	b := suite.G1().Scalar().Pick(suite.RandomStream())
+	p := suite.G1().Point().Pick(suite.RandomStream())
+	q := suite.G2().Point().Pick(suite.RandomStream())
+
+	// e(aP, bQ) = e(P, Q)^ab = e(abP, Q)
+	left := suite.Pair(suite.G1().Point().Mul(a, p), suite.G2().Point().Mul(b, q))
+	ab := suite.G1().Scalar().Mul(a, b)
+	right := suite.GT().Point().Mul(ab, suite.Pair(p, q))
+	if !left.Equal(right) {
+		t.Fatal("e(aP, bQ) != e(P, Q)^ab")
+	}
+	if !left.Equal(suite.Pair(suite.G1().Point().Mul(ab, p), q)) {
+		t.Fatal("e(aP, bQ) != e(abP, Q)")
+	}
+
+	// e(P1 + P2, Q) = e(P1, Q) e(P2, Q)
+	p2 := suite.G1().Point().Pick(suite.RandomStream())
+	sum := suite.Pair(suite.G1().Point().Add(p, p2), q)
+	if !sum.Equal(suite.GT().Point().Add(suite.Pair(p, q), suite.Pair(p2, q))) {
+		t.Fatal("the pairing is not linear in G1")
+	}
+
+	if suite.Pair(p, q).Equal(suite.GT().Point().Null()) {
+		t.Fatal("the pairing is degenerate")
+	}
+	if !suite.Pair(suite.G1().Point().Null(), q).Equal(suite.GT().Point().Null()) {
+		t.Fatal("e(0, Q) != 1")
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"sync"
 
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/sign/bls"
 	"go.dedis.ch/kyber/v3/sign/tbls"
@@ -51,6 +50,7 @@ var TestnetShares = map[int]*BLSShareJSON{
 }
 
 type BLSKeyring struct {
+	Suite        *Suite            // Pairing suite the keys live on
 	T            int               // Threshold
 	N            int               // Number of shares
 	Shares       map[int]*BLSShare // mapping from share ID to share
@@ -59,6 +59,11 @@ type BLSKeyring struct {
 
 // NewBLSKeyring generates a tbls keyring (master key, t-of-n shares).
 func NewBLSKeyring(t, n int) (*BLSKeyring, error) {
+	return NewSuiteBLSKeyring(BN256, t, n)
+}
+
+// NewSuiteBLSKeyring generates a tbls keyring on the given suite.
+func NewSuiteBLSKeyring(suite *Suite, t, n int) (*BLSKeyring, error) {
 	if t > n {
 		return nil, fmt.Errorf("threshold can not be greater that number of holders")
 	}
@@ -69,11 +74,11 @@ func NewBLSKeyring(t, n int) (*BLSKeyring, error) {
 		return nil, fmt.Errorf("number of holders can not be < 1")
 	}
 	var (
-		suite   = bn256.NewSuite()
-		secret  = suite.G1().Scalar().Pick(suite.RandomStream())
-		priPoly = share.NewPriPoly(suite.G2(), t, secret, suite.RandomStream())
-		pubPoly = priPoly.Commit(suite.G2().Point().Base())
+		secret  = suite.G2.Scalar().Pick(suite.G2.RandomStream())
+		priPoly = share.NewPriPoly(suite.G2, t, secret, suite.G2.RandomStream())
+		pubPoly = priPoly.Commit(suite.G2.Point().Base())
 		keyring = &BLSKeyring{
+			Suite:        suite,
 			N:            n,
 			T:            t,
 			MasterPubKey: pubPoly,
@@ -99,11 +104,17 @@ type BLSShare struct {
 }
 
 type BLSShareJSON struct {
-	Pub  string `json:"pub"`
-	Priv string `json:"priv"`
+	// Suite is the name of the suite of the keys, bn256 if empty.
+	Suite string `json:"suite,omitempty"`
+	Pub   string `json:"pub"`
+	Priv  string `json:"priv"`
 }
 
 func NewBLSShareJSON(keypair *BLSShare) (*BLSShareJSON, error) {
+	return NewSuiteBLSShareJSON(BN256, keypair)
+}
+
+func NewSuiteBLSShareJSON(suite *Suite, keypair *BLSShare) (*BLSShareJSON, error) {
 	pubBuf := bytes.NewBuffer(nil)
 	pubEnc := gob.NewEncoder(pubBuf)
 	if err := pubEnc.Encode(keypair.Pub); err != nil {
@@ -117,17 +128,23 @@ func NewBLSShareJSON(keypair *BLSShare) (*BLSShareJSON, error) {
 	}
 
 	return &BLSShareJSON{
-		Pub:  base64.StdEncoding.EncodeToString(pubBuf.Bytes()),
-		Priv: base64.StdEncoding.EncodeToString(privBuf.Bytes()),
+		Suite: suite.Name,
+		Pub:   base64.StdEncoding.EncodeToString(pubBuf.Bytes()),
+		Priv:  base64.StdEncoding.EncodeToString(privBuf.Bytes()),
 	}, nil
 }
 
 func (m *BLSShareJSON) Deserialize() (*BLSShare, error) {
+	suite, err := SuiteByName(m.Suite)
+	if err != nil {
+		return nil, err
+	}
+
 	pubBytes, err := base64.StdEncoding.DecodeString(m.Pub)
 	if err != nil {
 		return nil, fmt.Errorf("failed to base64-decode public key: %v", err)
 	}
-	pubKey, pubDec := &share.PubShare{V: bn256.NewSuite().G2().Point()}, gob.NewDecoder(bytes.NewBuffer(pubBytes))
+	pubKey, pubDec := &share.PubShare{V: suite.G2.Point()}, gob.NewDecoder(bytes.NewBuffer(pubBytes))
 	if err := pubDec.Decode(pubKey); err != nil {
 		return nil, fmt.Errorf("failed to decode public key: %v", err)
 	}
@@ -136,7 +153,7 @@ func (m *BLSShareJSON) Deserialize() (*BLSShare, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to base64-decode private key: %v", err)
 	}
-	privKey, privDec := &share.PriShare{V: bn256.NewSuite().G1().Scalar()}, gob.NewDecoder(bytes.NewBuffer(privBytes))
+	privKey, privDec := &share.PriShare{V: suite.G1.Scalar()}, gob.NewDecoder(bytes.NewBuffer(privBytes))
 	if err := privDec.Decode(privKey); err != nil {
 		return nil, fmt.Errorf("failed to decode private key: %v", err)
 	}
@@ -160,14 +177,21 @@ func LoadBLSShareJSON(path string) (*BLSShareJSON, error) {
 	return &sh, err
 }
 
+// DumpMasterPubKey serializes a bn256 master public key.
 func DumpMasterPubKey(poly *share.PubPoly) (string, error) {
+	return DumpSuiteMasterPubKey(BN256, poly)
+}
+
+// DumpSuiteMasterPubKey serializes the master public key, tagged with the name
+// of its suite.
+func DumpSuiteMasterPubKey(suite *Suite, poly *share.PubPoly) (string, error) {
 	pubBuf := bytes.NewBuffer(nil)
 	pubEnc := gob.NewEncoder(pubBuf)
 	_, pubKeyCommits := poly.Info()
 	if err := pubEnc.Encode(pubKeyCommits); err != nil {
 		return "", fmt.Errorf("failed to encode master public key: %v", err)
 	}
-	return base64.StdEncoding.EncodeToString(pubBuf.Bytes()), nil
+	return tag(suite, base64.StdEncoding.EncodeToString(pubBuf.Bytes())), nil
 }
 
 func DumpBLSKeyring(keyring *BLSKeyring, targetDir string) error {
@@ -175,19 +199,20 @@ func DumpBLSKeyring(keyring *BLSKeyring, targetDir string) error {
 		return fmt.Errorf("failed to dump keyring, directory does not exist")
 	}
 
-	pubBuf := bytes.NewBuffer(nil)
-	pubEnc := gob.NewEncoder(pubBuf)
-	_, pubKeyCommits := keyring.MasterPubKey.Info()
-	if err := pubEnc.Encode(pubKeyCommits); err != nil {
-		return fmt.Errorf("failed to encode master public key: %v", err)
+	suite := keyring.Suite
+	if suite == nil {
+		suite = BN256
+	}
+	base64PubKeyCommits, err := DumpSuiteMasterPubKey(suite, keyring.MasterPubKey)
+	if err != nil {
+		return err
 	}
-	base64PubKeyCommits := base64.StdEncoding.EncodeToString(pubBuf.Bytes())
 	if err := ioutil.WriteFile(filepath.Join(targetDir, storeMasterKey), []byte(base64PubKeyCommits), 0644); err != nil {
 		return fmt.Errorf("failed to write master public key to disk: %v", err)
 	}
 
 	for id, keypair := range keyring.Shares {
-		skp, err := NewBLSShareJSON(keypair)
+		skp, err := NewSuiteBLSShareJSON(suite, keypair)
 		if err != nil {
 			return fmt.Errorf("failed to serialize keypair #%d: %v", id, err)
 		}
@@ -205,70 +230,173 @@ func DumpBLSKeyring(keyring *BLSKeyring, targetDir string) error {
 	return nil
 }
 
+// LoadPubKey deserializes a master public key; keys without a suite tag are
+// bn256 ones.
 func LoadPubKey(base64Key string, numHolders int) (*share.PubPoly, error) {
-	suite := bn256.NewSuite()
+	_, pubKey, err := LoadSuitePubKey(base64Key, numHolders)
+	return pubKey, err
+}
+
+// LoadSuitePubKey deserializes a master public key and returns its suite.
+func LoadSuitePubKey(base64Key string, numHolders int) (*Suite, *share.PubPoly, error) {
+	suite, base64Key, err := untag(base64Key)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	keyBytes, err := base64.StdEncoding.DecodeString(base64Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to base64-decode master public key: %v", err)
+		return nil, nil, fmt.Errorf("failed to base64-decode master public key: %v", err)
 	}
 
 	var commits = make([]kyber.Point, numHolders)
 	for idx := range commits {
-		commits[idx] = suite.G2().Point()
+		commits[idx] = suite.G2.Point()
 	}
 	dec := gob.NewDecoder(bytes.NewBuffer(keyBytes))
 	if err := dec.Decode(&commits); err != nil {
-		return nil, fmt.Errorf("failed to decode public key: %v", err)
+		return nil, nil, fmt.Errorf("failed to decode public key: %v", err)
 	}
 
-	return share.NewPubPoly(bn256.NewSuite().G2(), nil, commits), nil
+	return suite, share.NewPubPoly(suite.G2, nil, commits), nil
 }
 
 type BLSVerifier struct {
-	Keypair      *BLSShare // This verifier's BLSShare.
+	Keypair      *BLSShare   // This verifier's BLSShare.
+	shares       []*BLSShare // All shares held by this verifier, Keypair is the first one.
 	masterPubKey *share.PubPoly
-	suiteG1      *bn256.Suite
-	suiteG2      *bn256.Suite
+	suite        *Suite
 	t            int
 	n            int
+	holders      []string // Addresses of the share holders, by share index.
 }
 
 func NewBLSVerifier(masterPubKey *share.PubPoly, sh *BLSShare, t, n int) *BLSVerifier {
+	return NewWeightedBLSVerifier(masterPubKey, []*BLSShare{sh}, t, n)
+}
+
+// NewWeightedBLSVerifier creates a verifier holding several shares of the
+// same key; such a verifier produces one signature share per key share.
+func NewWeightedBLSVerifier(masterPubKey *share.PubPoly, shares []*BLSShare, t, n int) *BLSVerifier {
+	return NewSuiteBLSVerifier(BN256, masterPubKey, shares, t, n)
+}
+
+// NewSuiteBLSVerifier creates a verifier of the keys on the given suite.
+func NewSuiteBLSVerifier(suite *Suite, masterPubKey *share.PubPoly, shares []*BLSShare, t, n int) *BLSVerifier {
 	return &BLSVerifier{
 		masterPubKey: masterPubKey,
-		Keypair:      sh,
-		suiteG1:      bn256.NewSuiteG1(),
-		suiteG2:      bn256.NewSuiteG2(),
+		Keypair:      shares[0],
+		shares:       shares,
+		suite:        suite,
 		t:            t,
 		n:            n,
 	}
 }
 
+// CombineBLSVerifiers merges the shares of verifiers produced by the same
+// DKG round into a single verifier.
+func CombineBLSVerifiers(verifiers []*BLSVerifier) (*BLSVerifier, error) {
+	if len(verifiers) == 0 {
+		return nil, fmt.Errorf("no verifiers to combine")
+	}
+	var (
+		first  = verifiers[0]
+		shares []*BLSShare
+	)
+	for _, verifier := range verifiers {
+		if !verifier.masterPubKey.Commit().Equal(first.masterPubKey.Commit()) {
+			return nil, fmt.Errorf("verifiers have different master public keys")
+		}
+		if verifier.suite.Name != first.suite.Name {
+			return nil, fmt.Errorf("verifiers have different suites")
+		}
+		if verifier.t != first.t || verifier.n != first.n {
+			return nil, fmt.Errorf("verifiers have different thresholds")
+		}
+		shares = append(shares, verifier.shares...)
+	}
+
+	return NewSuiteBLSVerifier(first.suite, first.masterPubKey, shares, first.t, first.n), nil
+}
+
+// MasterPubKey returns the public polynomial of the group key.
+func (m *BLSVerifier) MasterPubKey() *share.PubPoly {
+	return m.masterPubKey
+}
+
+// Suite returns the pairing suite of the keys.
+func (m *BLSVerifier) Suite() *Suite {
+	return m.suite
+}
+
+// Holders returns the addresses of the share holders in the order of their
+// share indexes, as the round that produced the key ordered them; nil if
+// unknown.
+func (m *BLSVerifier) Holders() []string {
+	return m.holders
+}
+
+// SetHolders sets the addresses of the share holders, see Holders.
+func (m *BLSVerifier) SetHolders(holders []string) {
+	m.holders = holders
+}
+
+// Shares returns all the key shares held by the verifier.
+func (m *BLSVerifier) Shares() []*BLSShare {
+	return m.shares
+}
+
 func (m *BLSVerifier) IsNil() bool {
 	return m == nil
 }
 
+// Sign returns the concatenated signature shares of all the key shares.
 func (m *BLSVerifier) Sign(data []byte) ([]byte, error) {
-	sig, err := tbls.Sign(m.suiteG1, m.Keypair.Priv, data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to sing random data with key %v %v with error %v", m.Keypair.Pub, data, err)
+	var out []byte
+	for _, sh := range m.shares {
+		sig, err := tbls.Sign(m.suite.G1, sh.Priv, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sing random data with key %v %v with error %v", sh.Pub, data, err)
+		}
+		out = append(out, sig...)
 	}
 
-	return sig, nil
+	return out, nil
 }
 
 func (m *BLSVerifier) VerifyRandomShare(addr string, prevRandomData, currRandomData []byte) error {
+	sigShares, err := m.splitSigShares(currRandomData)
+	if err != nil {
+		return err
+	}
 	// Check that the signature itself is correct for this validator.
-	if err := tbls.Verify(m.suiteG1, m.masterPubKey, prevRandomData, currRandomData); err != nil {
-		return fmt.Errorf("signature of share is corrupt: %v. prev random: %v; current random: %v", err, prevRandomData, currRandomData)
+	for _, sigShare := range sigShares {
+		if err := tbls.Verify(m.suite.G1, m.masterPubKey, prevRandomData, sigShare); err != nil {
+			return fmt.Errorf("signature of share is corrupt: %v. prev random: %v; current random: %v", err, prevRandomData, currRandomData)
+		}
 	}
 
 	return nil
 }
 
+// splitSigShares splits the output of Sign into separate signature shares.
+func (m *BLSVerifier) splitSigShares(sig []byte) ([][]byte, error) {
+	// A signature share is a 2-byte share index followed by a BLS signature.
+	size := 2 + m.suite.G1.PointLen()
+	if len(sig) == 0 || len(sig)%size != 0 {
+		return nil, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	var out [][]byte
+	for len(sig) > 0 {
+		out = append(out, sig[:size])
+		sig = sig[size:]
+	}
+
+	return out, nil
+}
+
 func (m *BLSVerifier) VerifyRandomData(prevRandomData, currRandomData []byte) error {
-	if err := bls.Verify(m.suiteG1, m.masterPubKey.Commit(), prevRandomData, currRandomData); err != nil {
+	if err := bls.Verify(m.suite.G1, m.masterPubKey.Commit(), prevRandomData, currRandomData); err != nil {
 		return fmt.Errorf("signature is corrupt: %v. prev random: %v; current random: %v", err, prevRandomData, currRandomData)
 	}
 
@@ -281,17 +409,33 @@ type BLSSigner interface {
 }
 
 func (m *BLSVerifier) Recover(msg []byte, precommits []BLSSigner) ([]byte, error) {
-	var sigs [][]byte
+	var (
+		sigs  [][]byte
+		known = make(map[int]bool)
+	)
 	for _, precommit := range precommits {
 		// Nil votes do exist, keep that in mind.
 		if precommit == nil || reflect.ValueOf(precommit).IsNil() || len(precommit.GetHash()) == 0 || len(precommit.GetBLSSignature()) == 0 {
 			continue
 		}
 
-		sigs = append(sigs, precommit.GetBLSSignature())
+		sigShares, err := m.splitSigShares(precommit.GetBLSSignature())
+		if err != nil {
+			continue
+		}
+		// Signature shares are aggregated by share index, every index is
+		// taken once.
+		for _, sigShare := range sigShares {
+			idx, err := tbls.SigShare(sigShare).Index()
+			if err != nil || known[idx] {
+				continue
+			}
+			known[idx] = true
+			sigs = append(sigs, sigShare)
+		}
 	}
 
-	aggrSig, err := tbls.Recover(m.suiteG1, m.masterPubKey, msg, sigs, m.t, m.n)
+	aggrSig, err := tbls.Recover(m.suite.G1, m.masterPubKey, msg, sigs, m.t, m.n)
 	if err != nil {
 		return nil, fmt.Errorf("failed to recover aggregate signature: %v", err)
 	}
@@ -299,8 +443,8 @@ func (m *BLSVerifier) Recover(msg []byte, precommits []BLSSigner) ([]byte, error
 	return aggrSig, nil
 }
 
-// NewTestBLSVerifier creates a BLSVerifier with a 1-of-2 key set that doesn't require any
-// other signatures but his own.
+// NewTestBLSVerifier creates a BLSVerifier with a 1-of-4 key set that doesn't require any
+// other signatures but his own (the hardcoded master key has a single commitment).
 // Keys are hardcoded to make tests output more deterministic.
 func NewTestBLSVerifier(addr string) *BLSVerifier {
 	t, n := 1, 4
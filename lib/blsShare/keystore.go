@@ -0,0 +1,204 @@
+package blsShare
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tendermint/tendermint/crypto/xsalsa20symmetric"
+)
+
+const (
+	keystoreVersion = 3
+	keystoreFile    = "verifier_%020d.json"
+	keystorePrefix  = "verifier_"
+)
+
+// VerifierRecord is a BLSVerifier produced by a finished DKG round together
+// with the height it becomes active at.
+type VerifierRecord struct {
+	Verifier         *BLSVerifier
+	RoundID          int
+	ActivationHeight int64
+}
+
+// Keystore keeps the verifiers produced by finished rounds on disk, one file
+// per activation height, so that a node keeps its key share across restarts.
+// The private shares are encrypted with a key derived from a passphrase.
+type Keystore struct {
+	dir string
+	key []byte
+}
+
+type keystoreRecordJSON struct {
+	Version          int                  `json:"version"`
+	RoundID          int                  `json:"round_id"`
+	ActivationHeight int64                `json:"activation_height"`
+	T                int                  `json:"t"`
+	N                int                  `json:"n"`
+	Shares           []*keystoreShareJSON `json:"shares,omitempty"`
+	EncryptedShares  []byte               `json:"encrypted_shares,omitempty"`
+	MasterPubKey     string               `json:"master_pub_key"`
+	NumCommits       int                  `json:"num_commits"`
+	Holders          []string             `json:"holders,omitempty"`
+
+	// Version 1 and 2 records hold the shares in the clear, version 1 records
+	// a single one.
+	ShareID int           `json:"share_id,omitempty"`
+	Share   *BLSShareJSON `json:"share,omitempty"`
+}
+
+type keystoreShareJSON struct {
+	ID    int           `json:"id"`
+	Share *BLSShareJSON `json:"share"`
+}
+
+func NewKeystore(dir string, passphrase []byte) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keystore directory: %v", err)
+	}
+	key := sha256.Sum256(passphrase)
+
+	return &Keystore{dir: dir, key: key[:]}, nil
+}
+
+func (ks *Keystore) Save(record *VerifierRecord) error {
+	var shares []*keystoreShareJSON
+	for _, sh := range record.Verifier.shares {
+		shareJSON, err := NewSuiteBLSShareJSON(record.Verifier.suite, sh)
+		if err != nil {
+			return fmt.Errorf("failed to serialize share: %v", err)
+		}
+		shares = append(shares, &keystoreShareJSON{ID: sh.ID, Share: shareJSON})
+	}
+	masterPubKey, err := DumpSuiteMasterPubKey(record.Verifier.suite, record.Verifier.masterPubKey)
+	if err != nil {
+		return err
+	}
+	_, commits := record.Verifier.masterPubKey.Info()
+	sharesData, err := json.Marshal(shares)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shares: %v", err)
+	}
+
+	data, err := json.Marshal(&keystoreRecordJSON{
+		Version:          keystoreVersion,
+		RoundID:          record.RoundID,
+		ActivationHeight: record.ActivationHeight,
+		T:                record.Verifier.t,
+		N:                record.Verifier.n,
+		EncryptedShares:  xsalsa20symmetric.EncryptSymmetric(sharesData, ks.key),
+		MasterPubKey:     masterPubKey,
+		NumCommits:       len(commits),
+		Holders:          record.Verifier.holders,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal verifier: %v", err)
+	}
+
+	path := filepath.Join(ks.dir, fmt.Sprintf(keystoreFile, record.ActivationHeight))
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write verifier to disk: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to rename verifier file: %v", err)
+	}
+
+	return nil
+}
+
+// Latest returns up to k most recent records, the most recent one first.
+func (ks *Keystore) Latest(k int) ([]*VerifierRecord, error) {
+	files, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore directory: %v", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), keystorePrefix) && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	// Heights are zero-padded, so the lexicographical order is the height order.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	var out []*VerifierRecord
+	for _, name := range names {
+		if len(out) == k {
+			break
+		}
+		record, err := ks.load(filepath.Join(ks.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", name, err)
+		}
+		out = append(out, record)
+	}
+
+	return out, nil
+}
+
+func (ks *Keystore) load(path string) (*VerifierRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recordJSON keystoreRecordJSON
+	if err := json.Unmarshal(data, &recordJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal verifier: %v", err)
+	}
+	switch recordJSON.Version {
+	case 1:
+		if recordJSON.Share != nil {
+			recordJSON.Shares = []*keystoreShareJSON{{ID: recordJSON.ShareID, Share: recordJSON.Share}}
+		}
+	case 2:
+	case keystoreVersion:
+		sharesData, err := xsalsa20symmetric.DecryptSymmetric(recordJSON.EncryptedShares, ks.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt shares: %v", err)
+		}
+		if err := json.Unmarshal(sharesData, &recordJSON.Shares); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shares: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported keystore version %d", recordJSON.Version)
+	}
+
+	if len(recordJSON.Shares) == 0 {
+		return nil, fmt.Errorf("no shares in keystore record")
+	}
+	suite, masterPubKey, err := LoadSuitePubKey(recordJSON.MasterPubKey, recordJSON.NumCommits)
+	if err != nil {
+		return nil, err
+	}
+	var shares []*BLSShare
+	for _, shareJSON := range recordJSON.Shares {
+		if name := shareJSON.Share.Suite; name != suite.Name && (name != "" || suite != BN256) {
+			return nil, fmt.Errorf("share #%d and the master public key are on different suites", shareJSON.ID)
+		}
+		sh, err := shareJSON.Share.Deserialize()
+		if err != nil {
+			return nil, err
+		}
+		sh.ID = shareJSON.ID
+		shares = append(shares, sh)
+	}
+	if err := CheckThreshold(masterPubKey, recordJSON.T, recordJSON.N); err != nil {
+		return nil, err
+	}
+
+	verifier := NewSuiteBLSVerifier(suite, masterPubKey, shares, recordJSON.T, recordJSON.N)
+	verifier.holders = recordJSON.Holders
+
+	return &VerifierRecord{
+		Verifier:         verifier,
+		RoundID:          recordJSON.RoundID,
+		ActivationHeight: recordJSON.ActivationHeight,
+	}, nil
+}
@@ -0,0 +1,180 @@
+package blsShare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKeystore(t *testing.T, passphrase string) (*Keystore, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewKeystore(dir, []byte(passphrase))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return ks, func() { os.RemoveAll(dir) }
+}
+
+func newTestRecord(t *testing.T, height int64) *VerifierRecord {
+	t.Helper()
+	keyring, err := NewBLSKeyring(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewWeightedBLSVerifier(keyring.MasterPubKey, []*BLSShare{keyring.Shares[1], keyring.Shares[2]}, 3, 4)
+	verifier.SetHolders([]string{"a", "b", "b", "c"})
+	return &VerifierRecord{Verifier: verifier, RoundID: 7, ActivationHeight: height}
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	ks, cleanup := newTestKeystore(t, "passphrase")
+	defer cleanup()
+
+	older, newer := newTestRecord(t, 100), newTestRecord(t, 200)
+	for _, record := range []*VerifierRecord{older, newer} {
+		if err := ks.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := ks.Latest(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ActivationHeight != 200 || records[1].ActivationHeight != 100 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	got, want := records[0], newer
+	if got.RoundID != want.RoundID {
+		t.Fatalf("round %d, want %d", got.RoundID, want.RoundID)
+	}
+	if got.Verifier.t != want.Verifier.t || got.Verifier.n != want.Verifier.n {
+		t.Fatalf("threshold %d-of-%d, want %d-of-%d", got.Verifier.t, got.Verifier.n, want.Verifier.t, want.Verifier.n)
+	}
+	if !got.Verifier.MasterPubKey().Equal(want.Verifier.MasterPubKey()) {
+		t.Fatal("master public keys differ")
+	}
+	if strings.Join(got.Verifier.Holders(), ",") != "a,b,b,c" {
+		t.Fatalf("holders %v", got.Verifier.Holders())
+	}
+	if len(got.Verifier.Shares()) != 2 {
+		t.Fatalf("%d shares, want 2", len(got.Verifier.Shares()))
+	}
+	for i, sh := range got.Verifier.Shares() {
+		wantShare := want.Verifier.Shares()[i]
+		if sh.ID != wantShare.ID || !sh.Priv.V.Equal(wantShare.Priv.V) || !sh.Pub.V.Equal(wantShare.Pub.V) {
+			t.Fatalf("share #%d differs", i)
+		}
+	}
+
+	// The loaded verifier signs like the saved one.
+	sig, err := got.Verifier.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSig, err := want.Verifier.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sig) != string(wantSig) {
+		t.Fatal("signatures differ")
+	}
+
+	if records, err = ks.Latest(1); err != nil || len(records) != 1 || records[0].ActivationHeight != 200 {
+		t.Fatalf("Latest(1) = %v, %v", records, err)
+	}
+}
+
+func TestKeystoreEncryptsShares(t *testing.T) {
+	ks, cleanup := newTestKeystore(t, "passphrase")
+	defer cleanup()
+
+	record := newTestRecord(t, 100)
+	if err := ks.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	data := readRecordFile(t, ks, 100)
+	for _, sh := range record.Verifier.Shares() {
+		shareJSON, err := NewBLSShareJSON(sh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), shareJSON.Priv) {
+			t.Fatal("the private share is stored in the clear")
+		}
+	}
+}
+
+func TestKeystoreWrongPassphrase(t *testing.T) {
+	ks, cleanup := newTestKeystore(t, "passphrase")
+	defer cleanup()
+	if err := ks.Save(newTestRecord(t, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewKeystore(ks.dir, []byte("another passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Latest(1); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Fatalf("expected a decryption error, got %v", err)
+	}
+}
+
+func TestKeystoreCorruptedFile(t *testing.T) {
+	for name, corrupt := range map[string]func(*testing.T, []byte) []byte{
+		"truncated": func(_ *testing.T, data []byte) []byte { return data[:len(data)/2] },
+		"shares": func(t *testing.T, data []byte) []byte {
+			var record keystoreRecordJSON
+			if err := json.Unmarshal(data, &record); err != nil {
+				t.Fatal(err)
+			}
+			record.EncryptedShares[len(record.EncryptedShares)/2] ^= 1
+			out, err := json.Marshal(&record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return out
+		},
+		"version": func(_ *testing.T, data []byte) []byte {
+			return []byte(strings.Replace(string(data), `"version":3`, `"version":9`, 1))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ks, cleanup := newTestKeystore(t, "passphrase")
+			defer cleanup()
+			if err := ks.Save(newTestRecord(t, 100)); err != nil {
+				t.Fatal(err)
+			}
+			data := corrupt(t, readRecordFile(t, ks, 100))
+			if err := ioutil.WriteFile(recordPath(ks, 100), data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ks.Latest(1); err == nil {
+				t.Fatal("a corrupted record was loaded")
+			}
+		})
+	}
+}
+
+func recordPath(ks *Keystore, height int64) string {
+	return filepath.Join(ks.dir, fmt.Sprintf(keystoreFile, height))
+}
+
+func readRecordFile(t *testing.T, ks *Keystore, height int64) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(recordPath(ks, height))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
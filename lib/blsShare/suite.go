@@ -0,0 +1,99 @@
+package blsShare
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/corestario/dkglib/lib/bls12381"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+const (
+	SuiteBN256    = "bn256"
+	SuiteBLS12381 = "bls12-381"
+)
+
+// Group is a pairing suite with one of its groups as the default one.
+type Group interface {
+	pairing.Suite
+	kyber.Group
+}
+
+// Suite is the pairing-friendly curve the keys live on. The DKG and the
+// master public key use G2, the signatures use G1.
+type Suite struct {
+	Name string
+	G1   Group
+	G2   Group
+}
+
+// BN256 is the suite used unless another one is chosen.
+var BN256 = &Suite{Name: SuiteBN256, G1: bn256.NewSuiteG1(), G2: bn256.NewSuiteG2()}
+
+// BLS12381 is the suite of the BLS12-381 curve, the one of the Ethereum
+// beacon chain and drand.
+var BLS12381 = &Suite{Name: SuiteBLS12381, G1: bls12381.NewSuiteG1(), G2: bls12381.NewSuiteG2()}
+
+var (
+	suitesLock sync.RWMutex
+	suites     = map[string]*Suite{SuiteBN256: BN256, SuiteBLS12381: BLS12381}
+)
+
+// RegisterSuite makes the suite available by its name.
+func RegisterSuite(suite *Suite) error {
+	if suite == nil || suite.Name == "" || suite.G1 == nil || suite.G2 == nil {
+		return fmt.Errorf("incomplete suite")
+	}
+	if strings.Contains(suite.Name, ":") {
+		return fmt.Errorf("invalid suite name %q", suite.Name)
+	}
+
+	suitesLock.Lock()
+	defer suitesLock.Unlock()
+	if _, ok := suites[suite.Name]; ok {
+		return fmt.Errorf("suite %s is already registered", suite.Name)
+	}
+	suites[suite.Name] = suite
+
+	return nil
+}
+
+// SuiteByName returns the registered suite; the empty name stands for bn256,
+// the suite of the formats that carry no tag.
+func SuiteByName(name string) (*Suite, error) {
+	if name == "" {
+		return BN256, nil
+	}
+
+	suitesLock.RLock()
+	defer suitesLock.RUnlock()
+	suite, ok := suites[name]
+	if !ok {
+		return nil, fmt.Errorf("suite %s is not registered", name)
+	}
+
+	return suite, nil
+}
+
+// tag prefixes the serialized value with the name of its suite.
+func tag(suite *Suite, value string) string {
+	return suite.Name + ":" + value
+}
+
+// untag splits a serialized value into its suite and the value itself.
+func untag(value string) (*Suite, string, error) {
+	// Base64 has no colons, so an untagged value is a legacy bn256 one.
+	idx := strings.Index(value, ":")
+	if idx < 0 {
+		return BN256, value, nil
+	}
+	suite, err := SuiteByName(value[:idx])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return suite, value[idx+1:], nil
+}
@@ -0,0 +1,53 @@
+package blsShare
+
+import (
+	"fmt"
+
+	"go.dedis.ch/kyber/v3/share"
+)
+
+// ThresholdPolicy defines how many of n shares are needed to recover the
+// group signature: more than Numerator/Denominator of them. The same number
+// is used as the degree (plus one) of the DKG polynomial and as the recovery
+// threshold of the verifier.
+type ThresholdPolicy struct {
+	Numerator   int
+	Denominator int
+}
+
+// DefaultThresholdPolicy requires more than two thirds of the shares, which
+// matches the BFT assumption of Tendermint.
+var DefaultThresholdPolicy = ThresholdPolicy{Numerator: 2, Denominator: 3}
+
+// Threshold returns the number of shares (out of n) needed for recovery.
+func (p ThresholdPolicy) Threshold(n int) int {
+	return n*p.Numerator/p.Denominator + 1
+}
+
+// Validate checks that the policy gives a threshold which is both reachable
+// and safe for the DKG, i.e. the fraction is in [1/2, 1).
+func (p ThresholdPolicy) Validate() error {
+	if p.Denominator <= 0 || p.Numerator <= 0 {
+		return fmt.Errorf("invalid threshold policy %d/%d", p.Numerator, p.Denominator)
+	}
+	if p.Numerator >= p.Denominator {
+		return fmt.Errorf("threshold policy %d/%d requires more shares than there are", p.Numerator, p.Denominator)
+	}
+	if 2*p.Numerator < p.Denominator {
+		return fmt.Errorf("threshold policy %d/%d is less than a half", p.Numerator, p.Denominator)
+	}
+	return nil
+}
+
+// CheckThreshold makes sure that exactly t of n shares are needed to recover
+// the secret behind masterPubKey, i.e. that the degree of the polynomial
+// agrees with the recovery threshold.
+func CheckThreshold(masterPubKey *share.PubPoly, t, n int) error {
+	if t < 1 || t > n {
+		return fmt.Errorf("threshold %d is out of range for %d shares", t, n)
+	}
+	if masterPubKey.Threshold() != t {
+		return fmt.Errorf("polynomial of degree %d does not match threshold %d", masterPubKey.Threshold()-1, t)
+	}
+	return nil
+}
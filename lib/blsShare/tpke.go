@@ -0,0 +1,325 @@
+package blsShare
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+	"go.dedis.ch/kyber/v3/share"
+)
+
+// Ciphertext is a message encrypted to the group key with TDH2 (Shoup and
+// Gennaro): U = rG is the ephemeral key and Data is the message sealed with
+// the key derived from rP, P being the group key. UBar = rḠ, E and F prove
+// the knowledge of r; the proof covers Data and the label, so a ciphertext
+// can be neither mauled nor given another label.
+type Ciphertext struct {
+	U    kyber.Point
+	UBar kyber.Point
+	E    kyber.Scalar
+	F    kyber.Scalar
+	Data []byte
+}
+
+// DecryptionShare is the share xU of a validator, x being its key share,
+// together with the proof that it is computed with the same x as the public
+// share xG.
+type DecryptionShare struct {
+	Index int
+	D     kyber.Point
+	Proof *dleq.Proof
+}
+
+// ThresholdDecrypter decrypts the messages encrypted to the group key of a DKG
+// round; t decryption shares are needed to open a ciphertext.
+//
+// Shares are only released for valid ciphertexts under the given label.
+// Still, any such ciphertext gets decrypted, so the applications must only
+// request shares for ciphertexts that are meant to be opened, e.g. the ones
+// included in a committed block.
+type ThresholdDecrypter struct {
+	shares       []*BLSShare
+	masterPubKey *share.PubPoly
+	suite        *Suite
+	t            int
+	n            int
+}
+
+func NewThresholdDecrypter(suite *Suite, masterPubKey *share.PubPoly, shares []*BLSShare, t, n int) *ThresholdDecrypter {
+	return &ThresholdDecrypter{
+		shares:       shares,
+		masterPubKey: masterPubKey,
+		suite:        suite,
+		t:            t,
+		n:            n,
+	}
+}
+
+// Decrypter returns the decrypter holding the same key shares as the verifier.
+func (m *BLSVerifier) Decrypter() *ThresholdDecrypter {
+	return NewThresholdDecrypter(m.suite, m.masterPubKey, m.shares, m.t, m.n)
+}
+
+// Encrypt encrypts the message to the group key; label is authenticated but
+// not encrypted and must be the same on decryption.
+func (m *ThresholdDecrypter) Encrypt(msg, label []byte) (*Ciphertext, error) {
+	return Encrypt(m.suite, m.masterPubKey, msg, label)
+}
+
+// Encrypt encrypts the message to the group key; it needs no key share.
+func Encrypt(suite *Suite, masterPubKey *share.PubPoly, msg, label []byte) (*Ciphertext, error) {
+	var (
+		g    = suite.G2
+		gBar = secondGenerator(g)
+		r    = g.Scalar().Pick(g.RandomStream())
+		s    = g.Scalar().Pick(g.RandomStream())
+		c    = &Ciphertext{
+			U:    g.Point().Mul(r, nil),
+			UBar: g.Point().Mul(r, gBar),
+		}
+	)
+	aead, err := newAEAD(g.Point().Mul(r, masterPubKey.Commit()))
+	if err != nil {
+		return nil, err
+	}
+	ad, err := additionalData(c.U, label)
+	if err != nil {
+		return nil, err
+	}
+	c.Data = aead.Seal(nil, make([]byte, aead.NonceSize()), msg, ad)
+
+	// Proof of knowledge of r: E = H(Data, label, U, sG, UBar, sḠ),
+	// F = s + rE.
+	if c.E, err = challenge(g, c, label, g.Point().Mul(s, nil), g.Point().Mul(s, gBar)); err != nil {
+		return nil, err
+	}
+	c.F = g.Scalar().Add(s, g.Scalar().Mul(r, c.E))
+
+	return c, nil
+}
+
+// Verify checks that the ciphertext is well-formed and bound to the label.
+func (m *ThresholdDecrypter) Verify(c *Ciphertext, label []byte) error {
+	if c == nil || c.U == nil || c.UBar == nil || c.E == nil || c.F == nil {
+		return errors.New("ciphertext is incomplete")
+	}
+	var (
+		g    = m.suite.G2
+		gBar = secondGenerator(g)
+		// W = FG - EU and WBar = FḠ - EUBar are sG and sḠ for a valid proof.
+		w    = g.Point().Sub(g.Point().Mul(c.F, nil), g.Point().Mul(c.E, c.U))
+		wBar = g.Point().Sub(g.Point().Mul(c.F, gBar), g.Point().Mul(c.E, c.UBar))
+	)
+	e, err := challenge(g, c, label, w, wBar)
+	if err != nil {
+		return err
+	}
+	if !e.Equal(c.E) {
+		return errors.New("invalid ciphertext proof")
+	}
+
+	return nil
+}
+
+// DecryptionShares returns one decryption share per key share, provided that
+// the ciphertext is valid under the label.
+func (m *ThresholdDecrypter) DecryptionShares(c *Ciphertext, label []byte) ([]*DecryptionShare, error) {
+	if err := m.Verify(c, label); err != nil {
+		return nil, err
+	}
+	var out []*DecryptionShare
+	for _, sh := range m.shares {
+		proof, _, d, err := dleq.NewDLEQProof(m.suite.G2, m.suite.G2.Point().Base(), c.U, sh.Priv.V)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prove decryption share #%d: %v", sh.Priv.I, err)
+		}
+		out = append(out, &DecryptionShare{Index: sh.Priv.I, D: d, Proof: proof})
+	}
+
+	return out, nil
+}
+
+// VerifyShare checks the share against the public share of its index.
+func (m *ThresholdDecrypter) VerifyShare(c *Ciphertext, ds *DecryptionShare) error {
+	if ds == nil || ds.D == nil || ds.Proof == nil {
+		return errors.New("decryption share is incomplete")
+	}
+	if ds.Index < 0 || ds.Index >= m.n {
+		return fmt.Errorf("decryption share index %d is out of range", ds.Index)
+	}
+	pub := m.masterPubKey.Eval(ds.Index)
+	if err := ds.Proof.Verify(m.suite.G2, m.suite.G2.Point().Base(), c.U, pub.V, ds.D); err != nil {
+		return fmt.Errorf("decryption share #%d is corrupt: %v", ds.Index, err)
+	}
+
+	return nil
+}
+
+// Combine checks the ciphertext and the shares, recovers the key from t
+// valid shares and opens the ciphertext. Invalid and duplicate shares are
+// skipped.
+func (m *ThresholdDecrypter) Combine(c *Ciphertext, shares []*DecryptionShare, label []byte) ([]byte, error) {
+	if err := m.Verify(c, label); err != nil {
+		return nil, err
+	}
+	var (
+		pubShares []*share.PubShare
+		known     = make(map[int]bool)
+	)
+	for _, ds := range shares {
+		if ds == nil || known[ds.Index] || m.VerifyShare(c, ds) != nil {
+			continue
+		}
+		known[ds.Index] = true
+		pubShares = append(pubShares, &share.PubShare{I: ds.Index, V: ds.D})
+	}
+	if len(pubShares) < m.t {
+		return nil, fmt.Errorf("not enough valid decryption shares: have %d, want %d", len(pubShares), m.t)
+	}
+
+	key, err := share.RecoverCommit(m.suite.G2, pubShares, m.t, m.n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover decryption key: %v", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	ad, err := additionalData(c.U, label)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := aead.Open(nil, make([]byte, aead.NonceSize()), c.Data, ad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+
+	return msg, nil
+}
+
+// newAEAD derives the symmetric key from rP. Every key seals a single
+// message, so a constant nonce is fine.
+func newAEAD(k kyber.Point) (cipher.AEAD, error) {
+	kBytes, err := k.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %v", err)
+	}
+	key := sha256.Sum256(kBytes)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// secondGenerator returns Ḡ, the generator UBar is computed with; its
+// discrete logarithm to the base G is known to no one.
+func secondGenerator(g Group) kyber.Point {
+	return g.Point().Pick(g.XOF([]byte("dkglib/tpke: second generator")))
+}
+
+// challenge hashes the ciphertext, the label and the commitments of the proof
+// to a scalar.
+func challenge(g Group, c *Ciphertext, label []byte, w, wBar kyber.Point) (kyber.Scalar, error) {
+	h := g.Hash()
+	for _, p := range []kyber.Point{c.U, c.UBar, w, wBar} {
+		if _, err := p.MarshalTo(h); err != nil {
+			return nil, fmt.Errorf("failed to hash ciphertext: %v", err)
+		}
+	}
+	for _, b := range [][]byte{label, c.Data} {
+		if err := binary.Write(h, binary.BigEndian, uint32(len(b))); err != nil {
+			return nil, err
+		}
+		h.Write(b)
+	}
+
+	return g.Scalar().SetBytes(h.Sum(nil)), nil
+}
+
+func additionalData(u kyber.Point, label []byte) ([]byte, error) {
+	uBytes, err := u.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ephemeral key: %v", err)
+	}
+
+	return append(uBytes, label...), nil
+}
+
+// MarshalBinary encodes the ciphertext as U, UBar, E and F followed by the
+// sealed message.
+func (c *Ciphertext) MarshalBinary() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	for _, m := range []kyber.Marshaling{c.U, c.UBar, c.E, c.F} {
+		if _, err := m.MarshalTo(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(buf.Bytes(), c.Data...), nil
+}
+
+func UnmarshalCiphertext(suite *Suite, data []byte) (*Ciphertext, error) {
+	g := suite.G2
+	c := &Ciphertext{U: g.Point(), UBar: g.Point(), E: g.Scalar(), F: g.Scalar()}
+	buf := bytes.NewReader(data)
+	for _, m := range []kyber.Marshaling{c.U, c.UBar, c.E, c.F} {
+		if _, err := m.UnmarshalFrom(buf); err != nil {
+			return nil, fmt.Errorf("failed to decode ciphertext: %v", err)
+		}
+	}
+	c.Data = data[len(data)-buf.Len():]
+
+	return c, nil
+}
+
+// MarshalBinary encodes the share as a 2-byte index followed by D and the
+// proof (C, R, VG, VH).
+func (ds *DecryptionShare) MarshalBinary() ([]byte, error) {
+	if ds.Index < 0 || ds.Index > math.MaxUint16 {
+		return nil, fmt.Errorf("decryption share index %d does not fit in 2 bytes", ds.Index)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf, binary.BigEndian, uint16(ds.Index)); err != nil {
+		return nil, err
+	}
+	for _, m := range []kyber.Marshaling{ds.D, ds.Proof.C, ds.Proof.R, ds.Proof.VG, ds.Proof.VH} {
+		if _, err := m.MarshalTo(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func UnmarshalDecryptionShare(suite *Suite, data []byte) (*DecryptionShare, error) {
+	g := suite.G2
+	ds := &DecryptionShare{
+		D:     g.Point(),
+		Proof: &dleq.Proof{C: g.Scalar(), R: g.Scalar(), VG: g.Point(), VH: g.Point()},
+	}
+	buf := bytes.NewReader(data)
+	var idx uint16
+	if err := binary.Read(buf, binary.BigEndian, &idx); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %v", err)
+	}
+	ds.Index = int(idx)
+	for _, m := range []kyber.Marshaling{ds.D, ds.Proof.C, ds.Proof.R, ds.Proof.VG, ds.Proof.VH} {
+		if _, err := m.UnmarshalFrom(buf); err != nil {
+			return nil, fmt.Errorf("failed to decode decryption share: %v", err)
+		}
+	}
+	if buf.Len() != 0 {
+		return nil, errors.New("trailing bytes after decryption share")
+	}
+
+	return ds, nil
+}
@@ -0,0 +1,176 @@
+package blsShare
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestDecrypters returns the decrypters of n validators, one key share
+// each.
+func newTestDecrypters(t *testing.T, threshold, n int) []*ThresholdDecrypter {
+	t.Helper()
+	keyring, err := NewBLSKeyring(threshold, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []*ThresholdDecrypter
+	for i := 0; i < n; i++ {
+		out = append(out, NewThresholdDecrypter(BN256, keyring.MasterPubKey, []*BLSShare{keyring.Shares[i]}, threshold, n))
+	}
+	return out
+}
+
+// decryptionShares collects the shares of the given validators.
+func decryptionShares(t *testing.T, decrypters []*ThresholdDecrypter, c *Ciphertext, label []byte) []*DecryptionShare {
+	t.Helper()
+	var out []*DecryptionShare
+	for _, d := range decrypters {
+		shares, err := d.DecryptionShares(c, label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, shares...)
+	}
+	return out
+}
+
+func TestThresholdDecryption(t *testing.T) {
+	decrypters := newTestDecrypters(t, 3, 4)
+	msg, label := []byte("message"), []byte("label")
+	c, err := decrypters[0].Encrypt(msg, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ciphertext and the shares go over the wire.
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err = UnmarshalCiphertext(BN256, data); err != nil {
+		t.Fatal(err)
+	}
+	var shares []*DecryptionShare
+	for _, ds := range decryptionShares(t, decrypters[1:], c, label) {
+		data, err := ds.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err = UnmarshalDecryptionShare(BN256, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := decrypters[0].VerifyShare(c, ds); err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, ds)
+	}
+
+	out, err := decrypters[0].Combine(c, shares, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(msg) {
+		t.Fatalf("decrypted %q, want %q", out, msg)
+	}
+}
+
+func TestThresholdDecryptionTooFewShares(t *testing.T) {
+	decrypters := newTestDecrypters(t, 3, 4)
+	c, err := Encrypt(BN256, decrypters[0].masterPubKey, []byte("message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := decryptionShares(t, decrypters[:2], c, nil)
+	// Duplicates do not count.
+	shares = append(shares, shares...)
+	if _, err := decrypters[0].Combine(c, shares, nil); err == nil || !strings.Contains(err.Error(), "not enough") {
+		t.Fatalf("expected a share count error, got %v", err)
+	}
+}
+
+func TestThresholdDecryptionInvalidCiphertext(t *testing.T) {
+	decrypters := newTestDecrypters(t, 3, 4)
+	g := BN256.G2
+	encrypt := func() *Ciphertext {
+		c, err := Encrypt(BN256, decrypters[0].masterPubKey, []byte("message"), []byte("label"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	for name, tamper := range map[string]func(c *Ciphertext) []byte{
+		"label":      func(c *Ciphertext) []byte { return []byte("another label") },
+		"data":       func(c *Ciphertext) []byte { c.Data[0] ^= 1; return []byte("label") },
+		"U":          func(c *Ciphertext) []byte { c.U = g.Point().Pick(g.RandomStream()); return []byte("label") },
+		"UBar":       func(c *Ciphertext) []byte { c.UBar = g.Point().Pick(g.RandomStream()); return []byte("label") },
+		"proof":      func(c *Ciphertext) []byte { c.F = g.Scalar().Pick(g.RandomStream()); return []byte("label") },
+		"incomplete": func(c *Ciphertext) []byte { c.E = nil; return []byte("label") },
+	} {
+		c := encrypt()
+		label := tamper(c)
+		if err := decrypters[0].Verify(c, label); err == nil {
+			t.Fatalf("%s: the ciphertext verifies", name)
+		}
+		if _, err := decrypters[0].DecryptionShares(c, label); err == nil {
+			t.Fatalf("%s: decryption shares were released", name)
+		}
+	}
+
+	// Shares of a valid ciphertext do not open a mauled one.
+	c := encrypt()
+	shares := decryptionShares(t, decrypters, c, []byte("label"))
+	c.Data[0] ^= 1
+	if _, err := decrypters[0].Combine(c, shares, []byte("label")); err == nil {
+		t.Fatal("a mauled ciphertext was decrypted")
+	}
+
+	if _, err := UnmarshalCiphertext(BN256, []byte("short")); err == nil {
+		t.Fatal("a truncated ciphertext was decoded")
+	}
+}
+
+func TestThresholdDecryptionInvalidShares(t *testing.T) {
+	decrypters := newTestDecrypters(t, 3, 4)
+	g := BN256.G2
+	c, err := Encrypt(BN256, decrypters[0].masterPubKey, []byte("message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := decryptionShares(t, decrypters, c, nil)
+
+	// Corrupt two shares: two valid ones remain, fewer than the threshold.
+	shares[0].D = g.Point().Pick(g.RandomStream())
+	shares[1].Index = shares[2].Index
+	for _, ds := range shares[:2] {
+		if err := decrypters[3].VerifyShare(c, ds); err == nil {
+			t.Fatalf("corrupt share #%d verifies", ds.Index)
+		}
+	}
+	if _, err := decrypters[3].Combine(c, shares, nil); err == nil {
+		t.Fatal("decrypted with corrupt shares")
+	}
+
+	for _, index := range []int{-1, 4} {
+		ds := *shares[3]
+		ds.Index = index
+		if err := decrypters[3].VerifyShare(c, &ds); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Fatalf("index %d: expected a range error, got %v", index, err)
+		}
+	}
+
+	// Indexes that do not fit the encoding are not truncated.
+	ds := *shares[3]
+	ds.Index = 1<<16 + shares[3].Index
+	if _, err := ds.MarshalBinary(); err == nil {
+		t.Fatal("an index above 65535 was encoded")
+	}
+	data, err := shares[3].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalDecryptionShare(BN256, append(data, 0)); err == nil {
+		t.Fatal("a share with trailing bytes was decoded")
+	}
+}
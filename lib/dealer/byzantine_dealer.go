@@ -0,0 +1,253 @@
+package dealer
+
+import (
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// ByzantineAction is what a ByzantineDealer does to a message matched by a
+// rule instead of sending it.
+type ByzantineAction int
+
+const (
+	// ByzantineDrop does not send the message.
+	ByzantineDrop ByzantineAction = iota
+	// ByzantineDuplicate sends the message twice.
+	ByzantineDuplicate
+	// ByzantineCorrupt sends the message with a corrupted payload.
+	ByzantineCorrupt
+	// ByzantineWrongIndex sends the message to the next participant.
+	ByzantineWrongIndex
+	// ByzantineEquivocate sends the message and a conflicting one with a
+	// corrupted payload.
+	ByzantineEquivocate
+	// ByzantineDelay holds the message back for a number of blocks.
+	ByzantineDelay
+)
+
+func (a ByzantineAction) String() string {
+	switch a {
+	case ByzantineDrop:
+		return "drop"
+	case ByzantineDuplicate:
+		return "duplicate"
+	case ByzantineCorrupt:
+		return "corrupt"
+	case ByzantineWrongIndex:
+		return "wrong index"
+	case ByzantineEquivocate:
+		return "equivocate"
+	case ByzantineDelay:
+		return "delay"
+	default:
+		return fmt.Sprintf("ByzantineAction(%d)", int(a))
+	}
+}
+
+// ByzantineRule describes a fault of a ByzantineDealer. A rule applies to the
+// messages of the given types, recipients and rounds; empty lists match
+// everything. Only deals have a recipient, so a rule with Recipients matches
+// deals only.
+type ByzantineRule struct {
+	Action     ByzantineAction
+	Types      []alias.DKGDataType
+	Recipients []int // Participant indices, as in DKGData.ToIndex.
+	Rounds     []int
+	// Limit is the number of messages the rule applies to; zero means all
+	// of them.
+	Limit int
+	// Delay is the number of blocks ByzantineDelay holds a message for,
+	// counted in the NewBlock calls the dealer sees.
+	Delay int64
+	// Corrupt changes the payload for ByzantineCorrupt and
+	// ByzantineEquivocate; suite is the one the dealer runs on. By default
+	// the last byte is flipped.
+	Corrupt func(suite *blsShare.Suite, msg *alias.DKGData) error
+
+	applied int
+}
+
+func (r *ByzantineRule) matches(msg *alias.DKGData) bool {
+	if r.Limit > 0 && r.applied >= r.Limit {
+		return false
+	}
+	if len(r.Types) > 0 && !containsType(r.Types, msg.Type) {
+		return false
+	}
+	if len(r.Recipients) > 0 && (msg.Type != alias.DKGDeal || !containsInt(r.Recipients, msg.ToIndex)) {
+		return false
+	}
+	if len(r.Rounds) > 0 && !containsInt(r.Rounds, msg.RoundID) {
+		return false
+	}
+	return true
+}
+
+func (r *ByzantineRule) corrupt(suite *blsShare.Suite, msg *alias.DKGData) (*alias.DKGData, error) {
+	out := *msg
+	out.Data = append([]byte(nil), msg.Data...)
+	if r.Corrupt != nil {
+		if err := r.Corrupt(suite, &out); err != nil {
+			return nil, fmt.Errorf("failed to corrupt message: %v", err)
+		}
+		return &out, nil
+	}
+	if len(out.Data) == 0 {
+		out.Data = []byte{0xff}
+	} else {
+		out.Data[len(out.Data)-1] ^= 0xff
+	}
+	return &out, nil
+}
+
+type delayedMsg struct {
+	msg       *alias.DKGData
+	releaseAt int64 // In NewBlock calls.
+}
+
+// ByzantineDealer wraps a dealer and tampers with the messages it sends as
+// its rules say. The wrapped dealer runs the protocol as usual, so any fault
+// scenario can be built from rules without a dealer type of its own.
+type ByzantineDealer struct {
+	Dealer
+
+	rules      []*ByzantineRule
+	sendMsgCb  func([]*alias.DKGData) error
+	logger     log.Logger
+	validators int
+	suite      *blsShare.Suite
+
+	blocks  int64 // Number of NewBlock calls so far.
+	delayed []delayedMsg
+}
+
+// NewByzantineDealerConstructor returns a constructor of dealers built with
+// newDealer that break the protocol according to the rules. For every
+// message the first matching rule applies. It can be combined with
+// NewDealerConstructor to make only some of the participants faulty.
+func NewByzantineDealerConstructor(newDealer DKGDealerConstructor, rules ...ByzantineRule) DKGDealerConstructor {
+	return func(validators *tmtypes.ValidatorSet, pv tmtypes.PrivValidator, sendMsgCb func([]*alias.DKGData) error, eventFirer events.Fireable, logger log.Logger, startRound int) Dealer {
+		d := &ByzantineDealer{
+			sendMsgCb:  sendMsgCb,
+			logger:     logger,
+			validators: validators.Size(),
+		}
+		for _, rule := range rules {
+			rule := rule
+			rule.applied = 0
+			d.rules = append(d.rules, &rule)
+		}
+		d.Dealer = newDealer(validators, pv, d.send, eventFirer, logger, startRound)
+
+		return d
+	}
+}
+
+// SetSuite remembers the suite for the rules that corrupt payloads.
+func (d *ByzantineDealer) SetSuite(suite *blsShare.Suite) {
+	d.suite = suite
+	d.Dealer.SetSuite(suite)
+}
+
+// NewBlock releases the delayed messages that are due.
+func (d *ByzantineDealer) NewBlock(height int64) error {
+	d.blocks++
+
+	var due []*alias.DKGData
+	var rest []delayedMsg
+	for _, item := range d.delayed {
+		if item.releaseAt <= d.blocks {
+			due = append(due, item.msg)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	d.delayed = rest
+	if len(due) > 0 {
+		d.logger.Info("ByzantineDealer: releasing delayed messages", "messages", len(due))
+		if err := d.sendMsgCb(due); err != nil {
+			return fmt.Errorf("failed to send delayed messages: %v", err)
+		}
+	}
+
+	return d.Dealer.NewBlock(height)
+}
+
+func (d *ByzantineDealer) send(messages []*alias.DKGData) error {
+	var out []*alias.DKGData
+	for _, msg := range messages {
+		rule := d.match(msg)
+		if rule == nil {
+			out = append(out, msg)
+			continue
+		}
+		rule.applied++
+		d.logger.Info("ByzantineDealer: tampering with message", "action", rule.Action, "type", msg.Type, "to", msg.ToIndex)
+
+		switch rule.Action {
+		case ByzantineDrop:
+		case ByzantineDuplicate:
+			dup := *msg
+			out = append(out, msg, &dup)
+		case ByzantineCorrupt:
+			bad, err := rule.corrupt(d.suite, msg)
+			if err != nil {
+				return err
+			}
+			out = append(out, bad)
+		case ByzantineWrongIndex:
+			wrong := *msg
+			if d.validators > 0 {
+				wrong.ToIndex = (msg.ToIndex + 1) % d.validators
+			}
+			out = append(out, &wrong)
+		case ByzantineEquivocate:
+			bad, err := rule.corrupt(d.suite, msg)
+			if err != nil {
+				return err
+			}
+			out = append(out, msg, bad)
+		case ByzantineDelay:
+			d.delayed = append(d.delayed, delayedMsg{msg: msg, releaseAt: d.blocks + rule.Delay})
+		default:
+			return fmt.Errorf("unknown byzantine action %v", rule.Action)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+
+	return d.sendMsgCb(out)
+}
+
+func (d *ByzantineDealer) match(msg *alias.DKGData) *ByzantineRule {
+	for _, rule := range d.rules {
+		if rule.matches(msg) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func containsType(types []alias.DKGDataType, t alias.DKGDataType) bool {
+	for _, item := range types {
+		if item == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, item := range values {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package dealer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+// idleDealer sends nothing on its own.
+type idleDealer struct {
+	Dealer
+}
+
+func (d *idleDealer) NewBlock(int64) error { return nil }
+
+// newTestByzantine returns a byzantine dealer of a round of 4 validators,
+// along with the messages it sends.
+func newTestByzantine(t *testing.T, rules ...ByzantineRule) (*ByzantineDealer, *[]*alias.DKGData) {
+	t.Helper()
+	var validators []*tmtypes.Validator
+	for i := 0; i < 4; i++ {
+		validators = append(validators, tm.NewValidator(tm.NewMockPV().GetPubKey(), 1))
+	}
+	var sent []*alias.DKGData
+	ctor := NewByzantineDealerConstructor(func(*tmtypes.ValidatorSet, tmtypes.PrivValidator, func([]*alias.DKGData) error, events.Fireable, log.Logger, int) Dealer {
+		return &idleDealer{}
+	}, rules...)
+	d := ctor(tmtypes.NewValidatorSet(validators), tm.NewMockPV(), func(messages []*alias.DKGData) error {
+		sent = append(sent, messages...)
+		return nil
+	}, events.NewEventSwitch(), log.NewNopLogger(), 0)
+	return d.(*ByzantineDealer), &sent
+}
+
+func testDeal(to int) *alias.DKGData {
+	return &alias.DKGData{Type: alias.DKGDeal, RoundID: 1, ToIndex: to, Data: []byte{1, 2, 3}}
+}
+
+func TestByzantineActions(t *testing.T) {
+	for _, tc := range []struct {
+		action ByzantineAction
+		check  func(out []*alias.DKGData) bool
+	}{
+		{ByzantineDrop, func(out []*alias.DKGData) bool { return len(out) == 0 }},
+		{ByzantineDuplicate, func(out []*alias.DKGData) bool {
+			return len(out) == 2 && bytes.Equal(out[0].Data, out[1].Data) && out[1].ToIndex == 1
+		}},
+		{ByzantineCorrupt, func(out []*alias.DKGData) bool {
+			return len(out) == 1 && bytes.Equal(out[0].Data, []byte{1, 2, 0xfc})
+		}},
+		{ByzantineWrongIndex, func(out []*alias.DKGData) bool {
+			return len(out) == 1 && out[0].ToIndex == 2 && bytes.Equal(out[0].Data, []byte{1, 2, 3})
+		}},
+		{ByzantineEquivocate, func(out []*alias.DKGData) bool {
+			return len(out) == 2 && bytes.Equal(out[0].Data, []byte{1, 2, 3}) && bytes.Equal(out[1].Data, []byte{1, 2, 0xfc})
+		}},
+	} {
+		d, sent := newTestByzantine(t, ByzantineRule{Action: tc.action})
+		msg := testDeal(1)
+		if err := d.send([]*alias.DKGData{msg}); err != nil {
+			t.Fatalf("%s: %v", tc.action, err)
+		}
+		if !tc.check(*sent) {
+			t.Fatalf("%s: unexpected messages %+v", tc.action, *sent)
+		}
+		if !bytes.Equal(msg.Data, []byte{1, 2, 3}) || msg.ToIndex != 1 {
+			t.Fatalf("%s: the original message was changed", tc.action)
+		}
+	}
+
+	// The wrong index wraps around.
+	d, sent := newTestByzantine(t, ByzantineRule{Action: ByzantineWrongIndex})
+	if err := d.send([]*alias.DKGData{testDeal(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if (*sent)[0].ToIndex != 0 {
+		t.Fatalf("deal to 3 sent to %d", (*sent)[0].ToIndex)
+	}
+}
+
+func TestByzantineRules(t *testing.T) {
+	d, sent := newTestByzantine(t,
+		// Deals to 2 are dropped, but only the first one.
+		ByzantineRule{Action: ByzantineDrop, Recipients: []int{2}, Limit: 1},
+		// Responses of round 2 are duplicated.
+		ByzantineRule{Action: ByzantineDuplicate, Types: []alias.DKGDataType{alias.DKGResponse}, Rounds: []int{2}},
+	)
+	response := func(round int) *alias.DKGData {
+		return &alias.DKGData{Type: alias.DKGResponse, RoundID: round, Data: []byte{1}}
+	}
+	for _, tc := range []struct {
+		msg  *alias.DKGData
+		sent int
+	}{
+		{testDeal(1), 1},
+		{testDeal(2), 0},
+		{testDeal(2), 1}, // The limit is reached.
+		{response(1), 1},
+		{response(2), 2},
+		// Recipients match deals only.
+		{&alias.DKGData{Type: alias.DKGComplaint, RoundID: 1, ToIndex: 2}, 1},
+	} {
+		*sent = nil
+		if err := d.send([]*alias.DKGData{tc.msg}); err != nil {
+			t.Fatal(err)
+		}
+		if len(*sent) != tc.sent {
+			t.Fatalf("type %d to %d of round %d: sent %d messages, want %d",
+				tc.msg.Type, tc.msg.ToIndex, tc.msg.RoundID, len(*sent), tc.sent)
+		}
+	}
+
+	// The limit counts the messages of a batch one by one.
+	d, sent = newTestByzantine(t, ByzantineRule{Action: ByzantineDrop, Limit: 1})
+	if err := d.send([]*alias.DKGData{testDeal(1), testDeal(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 || (*sent)[0].ToIndex != 2 {
+		t.Fatalf("unexpected messages %+v", *sent)
+	}
+}
+
+// Delays are counted in the NewBlock calls the dealer sees, whatever the
+// heights are and whether any block has been seen before the message.
+func TestByzantineDelay(t *testing.T) {
+	d, sent := newTestByzantine(t, ByzantineRule{Action: ByzantineDelay, Delay: 2})
+	if err := d.send([]*alias.DKGData{testDeal(1)}); err != nil {
+		t.Fatal(err)
+	}
+	for i, height := range []int64{100, 101} {
+		if len(*sent) != 0 {
+			t.Fatalf("the message was released after %d blocks", i)
+		}
+		if err := d.NewBlock(height); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("%d messages released after 2 blocks, want 1", len(*sent))
+	}
+
+	if err := d.send([]*alias.DKGData{testDeal(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.NewBlock(500); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Fatal("the message was released after 1 block")
+	}
+	if err := d.NewBlock(501); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 2 || (*sent)[1].ToIndex != 2 {
+		t.Fatalf("unexpected messages %+v", *sent)
+	}
+}
@@ -0,0 +1,181 @@
+package dealer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/tendermint/tendermint/crypto/xsalsa20symmetric"
+)
+
+const storeRoundFile = "round_%d.jsonl"
+
+// FileDealerStore is a DealerStore that keeps every round in its own file in
+// the JSON lines format: the first line holds the encrypted seed, every next
+// line holds a received message or a phase timeout. Lines are appended and synced one by one, so
+// a crash can damage only the last line, which is then skipped on load.
+type FileDealerStore struct {
+	mtx sync.Mutex
+	dir string
+	key []byte
+}
+
+type fileStoreHeader struct {
+	RoundID       int    `json:"round_id"`
+	EncryptedSeed []byte `json:"encrypted_seed"`
+}
+
+// fileStoreLine tells the lines of phase timeouts, which have the Timeout
+// field set, from the lines of messages.
+type fileStoreLine struct {
+	Timeout *fileStoreTimeout `json:"timeout"`
+}
+
+type fileStoreTimeout struct {
+	Share  int   `json:"share"`
+	Height int64 `json:"height"`
+}
+
+var _ DealerStore = &FileDealerStore{}
+
+// NewFileDealerStore creates a store in dir; seeds are encrypted with a key
+// derived from secret.
+func NewFileDealerStore(dir string, secret []byte) (*FileDealerStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %v", err)
+	}
+	key := sha256.Sum256(secret)
+
+	return &FileDealerStore{dir: dir, key: key[:]}, nil
+}
+
+func (s *FileDealerStore) SaveSeed(roundID int, seed []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data, err := json.Marshal(&fileStoreHeader{
+		RoundID:       roundID,
+		EncryptedSeed: xsalsa20symmetric.EncryptSymmetric(seed, s.key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal header: %v", err)
+	}
+
+	// The seed starts a new round file; write it to a temporary file first so
+	// that an existing file is never left half-written.
+	tmp := s.path(roundID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write round file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path(roundID)); err != nil {
+		return fmt.Errorf("failed to rename round file: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FileDealerStore) AddMessage(roundID int, msg *alias.DKGData) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return s.appendLine(roundID, data)
+}
+
+func (s *FileDealerStore) AddTimeout(roundID int, timeout TimeoutEvent) error {
+	line := fileStoreLine{Timeout: &fileStoreTimeout{Share: timeout.Share, Height: timeout.Height}}
+	data, err := json.Marshal(&line)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeout: %v", err)
+	}
+	return s.appendLine(roundID, data)
+}
+
+func (s *FileDealerStore) appendLine(roundID int, data []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	f, err := os.OpenFile(s.path(roundID), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open round file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write line: %v", err)
+	}
+
+	return f.Sync()
+}
+
+func (s *FileDealerStore) Load(roundID int) (*DealerSnapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data, err := ioutil.ReadFile(s.path(roundID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read round file: %v", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("round file %d is empty", roundID)
+	}
+	var header fileStoreHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal header: %v", err)
+	}
+	seed, err := xsalsa20symmetric.DecryptSymmetric(header.EncryptedSeed, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt seed: %v", err)
+	}
+
+	snapshot := &DealerSnapshot{RoundID: header.RoundID, Seed: seed}
+	for scanner.Scan() {
+		// Only the last line can be damaged by a crash.
+		var line fileStoreLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			break
+		}
+		if line.Timeout != nil {
+			snapshot.Timeouts = append(snapshot.Timeouts, TimeoutEvent{
+				Share:  line.Timeout.Share,
+				Height: line.Timeout.Height,
+				After:  len(snapshot.Messages),
+			})
+			continue
+		}
+		var msg alias.DKGData
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			break
+		}
+		snapshot.Messages = append(snapshot.Messages, &msg)
+	}
+
+	return snapshot, nil
+}
+
+func (s *FileDealerStore) Delete(roundID int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := os.Remove(s.path(roundID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove round file: %v", err)
+	}
+	return nil
+}
+
+func (s *FileDealerStore) path(roundID int) string {
+	return filepath.Join(s.dir, fmt.Sprintf(storeRoundFile, roundID))
+}
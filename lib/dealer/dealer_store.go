@@ -0,0 +1,184 @@
+package dealer
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/tendermint/tendermint/libs/log"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+)
+
+const seedSize = 32
+
+// DealerStore keeps the state of in-progress rounds, so that a node can
+// resume a round after a restart instead of starting it over with a new
+// secret.
+//
+// The state is checkpointed as the dealer's inputs: the secret seed all the
+// randomness of the round is drawn from, every message received and the phase
+// timeouts, in the order they happened. Replaying them on a dealer with the
+// same seed brings it to the same state.
+type DealerStore interface {
+	SaveSeed(roundID int, seed []byte) error
+	AddMessage(roundID int, msg *alias.DKGData) error
+	AddTimeout(roundID int, timeout TimeoutEvent) error
+	// Load returns nil if there is nothing stored for the round.
+	Load(roundID int) (*DealerSnapshot, error)
+	Delete(roundID int) error
+}
+
+type DealerSnapshot struct {
+	RoundID  int
+	Seed     []byte
+	Messages []*alias.DKGData
+	Timeouts []TimeoutEvent
+}
+
+// TimeoutEvent is a phase timeout fired by NewBlock.
+type TimeoutEvent struct {
+	Share  int   // Index of the dealer within a weighted dealer.
+	Height int64 // Height of the block that fired the timeout.
+	After  int   // Number of messages received before, set by Load.
+}
+
+func (d *DKGDealer) SetStore(store DealerStore) {
+	d.store = store
+}
+
+// ReplayTimeout expires the current phase the way NewBlock did before a
+// restart.
+func (d *DKGDealer) ReplayTimeout(timeout TimeoutEvent) error {
+	d.height = timeout.Height
+	return d.expirePhase()
+}
+
+func (d *DKGDealer) SetSeed(seed []byte) {
+	d.seed = seed
+}
+
+// initSecret generates the dealer's long-term key pair. All the randomness
+// used by the dealer comes from the seed, which is saved to the store first.
+func (d *DKGDealer) initSecret() error {
+	if d.seed == nil {
+		d.seed = make([]byte, seedSize)
+		if _, err := rand.Read(d.seed); err != nil {
+			return fmt.Errorf("failed to generate seed: %v", err)
+		}
+	}
+	if d.store != nil {
+		if err := d.store.SaveSeed(d.roundID, d.seed); err != nil {
+			return fmt.Errorf("failed to save seed: %v", err)
+		}
+	}
+	d.stream = blake2xb.New(d.seed)
+
+	d.secKey = d.suiteG2.Scalar().Pick(d.stream)
+	d.pubKey = d.suiteG2.Point().Mul(d.secKey, nil)
+
+	return nil
+}
+
+// persist checkpoints a received message.
+func (d *DKGDealer) persist(msg *alias.DKGData) {
+	if d.store == nil {
+		return
+	}
+	if err := d.store.AddMessage(d.roundID, msg); err != nil {
+		d.logger.Error("DKGDealer: failed to persist message", "round", d.roundID, "type", msg.Type, "error", err)
+	}
+}
+
+// persistTimeout checkpoints a phase timeout fired at height.
+func (d *DKGDealer) persistTimeout(height int64) {
+	if d.store == nil {
+		return
+	}
+	if err := d.store.AddTimeout(d.roundID, TimeoutEvent{Height: height}); err != nil {
+		d.logger.Error("DKGDealer: failed to persist timeout", "round", d.roundID, "height", height, "error", err)
+	}
+}
+
+// randomSuite returns the suite to be passed to kyber's DKG implementations.
+func (d *DKGDealer) randomSuite() *seededSuite {
+	return &seededSuite{Suite: d.suiteG2, stream: d.stream}
+}
+
+// seededSuite makes the randomness of a suite reproducible.
+type seededSuite struct {
+	Suite
+	stream cipher.Stream
+}
+
+func (s *seededSuite) RandomStream() cipher.Stream {
+	return s.stream
+}
+
+// Suite is the set of functionalities the dealers need from a pairing suite.
+type Suite interface {
+	kyber.Group
+	kyber.HashFactory
+	kyber.XOFFactory
+	kyber.Random
+}
+
+// Resume restarts the dealer from a snapshot: the dealer gets the same secret
+// and the messages and timeouts seen before the restart are replayed in their
+// order, so the dealer sends the same messages again. The store is attached
+// after the replay, so that nothing is stored twice.
+//
+// Messages are checkpointed before their payload is checked, so a message
+// that fails now failed before the restart too; it is skipped rather than
+// making the round impossible to resume.
+func Resume(d Dealer, store DealerStore, snapshot *DealerSnapshot, logger log.Logger) error {
+	d.SetSeed(snapshot.Seed)
+	if err := d.Start(); err != nil {
+		return fmt.Errorf("failed to start dealer: %v", err)
+	}
+	timeouts := snapshot.Timeouts
+	replayTimeouts := func(received int) error {
+		for ; len(timeouts) > 0 && timeouts[0].After <= received; timeouts = timeouts[1:] {
+			if err := d.ReplayTimeout(timeouts[0]); err != nil {
+				return fmt.Errorf("failed to replay timeout at height %d: %v", timeouts[0].Height, err)
+			}
+		}
+		return nil
+	}
+	for i, msg := range snapshot.Messages {
+		if err := replayTimeouts(i); err != nil {
+			return err
+		}
+		if err := HandleMessage(d, msg); err != nil {
+			logger.Info("DKGDealer: skipping message on replay", "round", snapshot.RoundID, "type", msg.Type, "from", msg.GetAddrString(), "error", err)
+		}
+	}
+	if err := replayTimeouts(len(snapshot.Messages)); err != nil {
+		return err
+	}
+	d.SetStore(store)
+
+	return nil
+}
+
+// HandleMessage passes the message to the dealer's handler for its type.
+func HandleMessage(d Dealer, msg *alias.DKGData) error {
+	switch msg.Type {
+	case alias.DKGPubKey:
+		return d.HandleDKGPubKey(msg)
+	case alias.DKGDeal:
+		return d.HandleDKGDeal(msg)
+	case alias.DKGResponse:
+		return d.HandleDKGResponse(msg)
+	case alias.DKGJustification:
+		return d.HandleDKGJustification(msg)
+	case alias.DKGCommits:
+		return d.HandleDKGCommit(msg)
+	case alias.DKGComplaint:
+		return d.HandleDKGComplaint(msg)
+	case alias.DKGReconstructCommit:
+		return d.HandleDKGReconstructCommit(msg)
+	}
+	return fmt.Errorf("unknown message type %d", msg.Type)
+}
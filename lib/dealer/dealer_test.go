@@ -0,0 +1,309 @@
+package dealer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+// testRound runs dealers that broadcast signed messages to each other, one
+// block at a time.
+type testRound struct {
+	t          *testing.T
+	validators *tmtypes.ValidatorSet
+	pvs        []tmtypes.PrivValidator
+	dealers    []Dealer
+	inboxes    [][]*alias.DKGData
+	sent       [][]*alias.DKGData
+	errs       [][]error
+	height     int64
+
+	// hold, if set, keeps a message in the inbox of dealer to until the
+	// next block.
+	hold func(to int, msg *alias.DKGData) bool
+}
+
+func newTestRound(t *testing.T, n int, ctor DKGDealerConstructor) *testRound {
+	t.Helper()
+	r := &testRound{
+		t:       t,
+		inboxes: make([][]*alias.DKGData, n),
+		sent:    make([][]*alias.DKGData, n),
+		errs:    make([][]error, n),
+	}
+	var validators []*tmtypes.Validator
+	for i := 0; i < n; i++ {
+		pv := tm.NewMockPV()
+		r.pvs = append(r.pvs, pv)
+		validators = append(validators, tm.NewValidator(pv.GetPubKey(), 1))
+	}
+	r.validators = tmtypes.NewValidatorSet(validators)
+	for i, pv := range r.pvs {
+		r.dealers = append(r.dealers, ctor(r.validators, pv, r.sender(i), events.NewEventSwitch(), log.NewNopLogger(), 0))
+	}
+	return r
+}
+
+// replace rebuilds dealer i with ctor.
+func (r *testRound) replace(i int, ctor DKGDealerConstructor) {
+	r.dealers[i] = ctor(r.validators, r.pvs[i], r.sender(i), events.NewEventSwitch(), log.NewNopLogger(), 0)
+}
+
+func (r *testRound) sender(from int) func([]*alias.DKGData) error {
+	return func(messages []*alias.DKGData) error {
+		for _, msg := range messages {
+			if err := r.pvs[from].SignData("", msg); err != nil {
+				return err
+			}
+			r.sent[from] = append(r.sent[from], msg)
+			for to := range r.inboxes {
+				r.inboxes[to] = append(r.inboxes[to], msg)
+			}
+		}
+		return nil
+	}
+}
+
+// setTimeouts sets the phase timeouts of every dealer.
+func (r *testRound) setTimeouts(timeouts PhaseTimeouts) {
+	for _, d := range r.dealers {
+		d.SetPhaseTimeouts(timeouts)
+	}
+}
+
+func (r *testRound) start() {
+	r.t.Helper()
+	for _, d := range r.dealers {
+		if err := d.Start(); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// step delivers the messages in the inboxes and then tells every dealer
+// about a new block.
+func (r *testRound) step() {
+	r.height++
+	for i, d := range r.dealers {
+		inbox := r.inboxes[i]
+		r.inboxes[i] = nil
+		var held []*alias.DKGData
+		for _, msg := range inbox {
+			if r.hold != nil && r.hold(i, msg) {
+				held = append(held, msg)
+				continue
+			}
+			if err := HandleMessage(d, msg); err != nil {
+				r.errs[i] = append(r.errs[i], err)
+			}
+		}
+		r.inboxes[i] = append(held, r.inboxes[i]...)
+	}
+	for i, d := range r.dealers {
+		if err := d.NewBlock(r.height); err != nil {
+			r.errs[i] = append(r.errs[i], err)
+		}
+	}
+}
+
+// run steps until done holds or maxBlocks have passed.
+func (r *testRound) run(maxBlocks int, done func() bool) bool {
+	for i := 0; i < maxBlocks; i++ {
+		if done() {
+			return true
+		}
+		r.step()
+	}
+	return done()
+}
+
+func (r *testRound) verifier(i int) types.Verifier {
+	verifier, err := r.dealers[i].GetVerifier()
+	if err != nil {
+		return nil
+	}
+	return verifier
+}
+
+// ready is true once the listed dealers have a verifier.
+func (r *testRound) ready(dealers ...int) func() bool {
+	return func() bool {
+		for _, i := range dealers {
+			if r.verifier(i) == nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// sameKey checks that the listed dealers share a master key.
+func (r *testRound) sameKey(dealers ...int) {
+	r.t.Helper()
+	var keys []string
+	for _, i := range dealers {
+		key, err := blsShare.DumpMasterPubKey(r.verifier(i).(*blsShare.BLSVerifier).MasterPubKey())
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	for j, key := range keys {
+		if key != keys[0] {
+			r.t.Fatalf("dealer %d has another master key than dealer %d", dealers[j], dealers[0])
+		}
+	}
+}
+
+func (r *testRound) noErrors(dealers ...int) {
+	r.t.Helper()
+	for _, i := range dealers {
+		for _, err := range r.errs[i] {
+			r.t.Errorf("dealer %d: %v", i, err)
+		}
+	}
+}
+
+func (r *testRound) index(i int) int {
+	return r.dealers[i].GetState().participantID
+}
+
+func all(n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}
+
+func TestRound(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.start()
+	if !r.run(20, r.ready(all(4)...)) {
+		t.Fatalf("no verifiers after %d blocks", r.height)
+	}
+	r.noErrors(all(4)...)
+	r.sameKey(all(4)...)
+}
+
+// A node that misses a public key at the timeout must not deal to the
+// participants it happens to know: the others would index them differently.
+func TestPubKeyTimeout(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.setTimeouts(PhaseTimeouts{Blocks: 3})
+	late := string(r.pvs[3].GetPubKey().Address())
+	r.hold = func(to int, msg *alias.DKGData) bool {
+		return to == 1 && msg.Type == alias.DKGPubKey && string(msg.Addr) == late
+	}
+	r.start()
+	r.run(5, func() bool { return len(r.errs[1]) > 0 })
+	if len(r.errs[1]) == 0 || !strings.Contains(r.errs[1][0].Error(), "public key phase timed out") {
+		t.Fatalf("dealer 1 did not fail the round: %v", r.errs[1])
+	}
+	for _, msg := range r.sent[1] {
+		if msg.Type == alias.DKGDeal {
+			t.Fatal("dealer 1 sent deals")
+		}
+	}
+}
+
+// Regression: after a response phase timeout SetTimeout ran before the
+// responses received in time were processed, and filled in complaints in
+// their place.
+func TestResponseTimeout(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.setTimeouts(PhaseTimeouts{Blocks: 3})
+	from := string(r.pvs[3].GetPubKey().Address())
+	r.hold = func(to int, msg *alias.DKGData) bool {
+		if to != 1 || msg.Type != alias.DKGResponse || string(msg.Addr) != from {
+			return false
+		}
+		resp, err := wire.DecodeResponse(msg.Data)
+		return err == nil && int(resp.Index) == r.index(0)
+	}
+	r.start()
+	if !r.run(30, r.ready(all(4)...)) {
+		t.Fatalf("no verifiers after %d blocks: %v", r.height, r.errs)
+	}
+	r.noErrors(all(4)...)
+	r.sameKey(all(4)...)
+}
+
+// A dealer resumed from its store sends the very messages it sent before the
+// restart, timeouts included.
+func TestResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dealer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileDealerStore(dir, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dealer 1 misses a response and times out, as in TestResponseTimeout.
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.setTimeouts(PhaseTimeouts{Blocks: 3})
+	r.dealers[1].SetStore(store)
+	from := string(r.pvs[3].GetPubKey().Address())
+	r.hold = func(to int, msg *alias.DKGData) bool {
+		if to != 1 || msg.Type != alias.DKGResponse || string(msg.Addr) != from {
+			return false
+		}
+		resp, err := wire.DecodeResponse(msg.Data)
+		return err == nil && int(resp.Index) == r.index(0)
+	}
+	r.start()
+	if !r.run(30, r.ready(all(4)...)) {
+		t.Fatalf("no verifiers after %d blocks: %v", r.height, r.errs)
+	}
+
+	snapshot, err := store.Load(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Timeouts) == 0 {
+		t.Fatal("no timeout stored")
+	}
+	var resent []*alias.DKGData
+	resumed := NewDKGDealer(r.validators, r.pvs[1], func(messages []*alias.DKGData) error {
+		for _, msg := range messages {
+			if err := r.pvs[1].SignData("", msg); err != nil {
+				return err
+			}
+		}
+		resent = append(resent, messages...)
+		return nil
+	}, events.NewEventSwitch(), log.NewNopLogger(), 0)
+	resumed.SetPhaseTimeouts(PhaseTimeouts{Blocks: 3})
+	if err := Resume(resumed, store, snapshot, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resent) != len(r.sent[1]) {
+		t.Fatalf("sent %d messages, %d after the restart", len(r.sent[1]), len(resent))
+	}
+	for i, msg := range r.sent[1] {
+		before, _ := json.Marshal(msg)
+		after, _ := json.Marshal(resent[i])
+		if !bytes.Equal(before, after) {
+			t.Fatalf("message %d differs after the restart:\n%s\n%s", i, before, after)
+		}
+	}
+	if _, err := resumed.GetVerifier(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,23 +1,23 @@
 package dealer
 
 import (
-	"bytes"
-	"encoding/gob"
+	"crypto/cipher"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/corestario/dkglib/lib/alias"
 	"github.com/corestario/dkglib/lib/blsShare"
 	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
 	tmtypes "github.com/tendermint/tendermint/alias"
 	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/libs/events"
 	"github.com/tendermint/tendermint/libs/log"
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/share"
 	dkg "go.dedis.ch/kyber/v3/share/dkg/rabin"
 	vss "go.dedis.ch/kyber/v3/share/vss/rabin"
@@ -30,6 +30,15 @@ type Dealer interface {
 	GenerateTransitions()
 	GetLosers() []*tmtypes.Validator
 	PopLosers() []*tmtypes.Validator
+	GetLoserProofs() []*types.LoserProof
+	GetEvidence() []*types.EquivocationEvidence
+	SetPhaseTimeouts(timeouts PhaseTimeouts)
+	SetThresholdPolicy(policy blsShare.ThresholdPolicy)
+	SetSuite(suite *blsShare.Suite)
+	NewBlock(height int64) error
+	ReplayTimeout(timeout TimeoutEvent) error
+	SetStore(store DealerStore)
+	SetSeed(seed []byte)
 	HandleDKGPubKey(msg *alias.DKGData) error
 	SetTransitions(t []transition)
 	SendDeals() (err error, ready bool)
@@ -67,20 +76,46 @@ type DKGDealer struct {
 
 	pubKey      kyber.Point
 	secKey      kyber.Scalar
-	suiteG1     *bn256.Suite
-	suiteG2     *bn256.Suite
+	suite       *blsShare.Suite
+	suiteG1     blsShare.Group
+	suiteG2     blsShare.Group
 	instance    *dkg.DistKeyGenerator
 	transitions []transition
 
 	pubKeys            PKStore
 	deals              map[string]*dkg.Deal
+	dealMsgs           map[string]*alias.DKGData
+	earlyDeals         []*alias.DKGData // Received before the own index is known.
 	responses          *messageStore
 	justifications     *messageStore
+	justificationAcks  map[string]int // Number of justifications announced, by sender.
 	commits            *messageStore
 	complaints         *messageStore
 	reconstructCommits *messageStore
 
-	losers []crypto.Address
+	losers   []*types.LoserProof
+	popped   int // Number of losers returned by PopLosers so far.
+	filter   *messageFilter
+	evidence []*types.EquivocationEvidence
+
+	// dealProofs are the complaints about the deals received, kept in case
+	// the dealers end up excluded from QUAL.
+	dealProofs map[string]*types.LoserProof
+
+	policy blsShare.ThresholdPolicy
+
+	timeouts         PhaseTimeouts
+	height           int64
+	phaseStartHeight int64
+	phaseStartTime   time.Time
+	phaseTimedOut    bool
+
+	// participantsFixed is set once the round instance is built from pubKeys.
+	participantsFixed bool
+
+	store  DealerStore
+	seed   []byte
+	stream cipher.Stream
 }
 
 type DealerState struct {
@@ -112,39 +147,47 @@ func NewDKGDealer(validators *tmtypes.ValidatorSet, pv tmtypes.PrivValidator, se
 		sendMsgCb:  sendMsgCb,
 		eventFirer: eventFirer,
 		logger:     logger,
-		suiteG1:    bn256.NewSuiteG1(),
-		suiteG2:    bn256.NewSuiteG2(),
+		suite:      blsShare.BN256,
+		suiteG1:    blsShare.BN256.G1,
+		suiteG2:    blsShare.BN256.G2,
+		policy:     blsShare.DefaultThresholdPolicy,
 
 		responses:          newMessageStore(validators.Size() - 1),
-		justifications:     newMessageStore(int(math.Pow(float64(validators.Size()-1), 2))),
+		justifications:     newMessageStore(validators.Size() - 1),
 		commits:            newMessageStore(1),
-		complaints:         newMessageStore(1),
-		reconstructCommits: newMessageStore(1),
+		complaints:         newMessageStore(validators.Size()),
+		reconstructCommits: newMessageStore(validators.Size()),
 
-		deals: make(map[string]*dkg.Deal),
+		deals:             make(map[string]*dkg.Deal),
+		dealMsgs:          make(map[string]*alias.DKGData),
+		justificationAcks: make(map[string]int),
+		dealProofs:        make(map[string]*types.LoserProof),
+		filter:            newMessageFilter(),
 	}
 }
 
 func (d *DKGDealer) Start() error {
-	d.secKey = d.suiteG2.Scalar().Pick(d.suiteG2.RandomStream())
-	d.pubKey = d.suiteG2.Point().Mul(d.secKey, nil)
+	if err := d.policy.Validate(); err != nil {
+		return err
+	}
+	if err := d.initSecret(); err != nil {
+		return err
+	}
 
 	d.GenerateTransitions()
+	d.resetPhase()
 
-	var (
-		buf = bytes.NewBuffer(nil)
-		enc = gob.NewEncoder(buf)
-	)
-	if err := enc.Encode(d.pubKey); err != nil {
+	data, err := wire.EncodePoint(d.pubKey)
+	if err != nil {
 		return fmt.Errorf("failed to encode public key: %v", err)
 	}
 
 	d.logger.Info("dkgState: sending pub key", "key", d.pubKey.String())
-	err := d.SendMsgCb([]*alias.DKGData{{
+	err = d.SendMsgCb([]*alias.DKGData{{
 		Type:    alias.DKGPubKey,
 		RoundID: d.roundID,
 		Addr:    d.addrBytes,
-		Data:    buf.Bytes(),
+		Data:    data,
 	}})
 	if err != nil {
 		return fmt.Errorf("failed to sign message: %v", err)
@@ -170,6 +213,7 @@ func (d *DKGDealer) Transit() error {
 			return err
 		}
 		d.transitions = d.transitions[1:]
+		d.resetPhase()
 	}
 
 	return nil
@@ -189,6 +233,17 @@ func (d *DKGDealer) GenerateTransitions() {
 	}
 }
 
+func (d *DKGDealer) SetThresholdPolicy(policy blsShare.ThresholdPolicy) {
+	d.policy = policy
+}
+
+// SetSuite sets the pairing suite the keys are generated on. It must be called
+// before Start.
+func (d *DKGDealer) SetSuite(suite *blsShare.Suite) {
+	d.suite = suite
+	d.suiteG1, d.suiteG2 = suite.G1, suite.G2
+}
+
 func (d *DKGDealer) SetTransitions(t []transition) {
 	d.transitions = t
 }
@@ -196,17 +251,19 @@ func (d *DKGDealer) SetTransitions(t []transition) {
 func (d *DKGDealer) GetLosers() []*tmtypes.Validator {
 	var out []*tmtypes.Validator
 	for _, loser := range d.losers {
-		_, validator := d.validators.GetByAddress(loser)
-		d.logger.Debug("got looser", "address", loser, "validator", validator.String())
+		_, validator := d.validators.GetByAddress(loser.Addr)
+		d.logger.Debug("got looser", "address", loser.Addr, "reason", loser.Reason, "validator", validator.String())
 		out = append(out, validator)
 	}
 
 	return out
 }
 
+// PopLosers returns the losers not returned by the previous calls. The losers
+// and their proofs are kept for GetLosers and GetLoserProofs.
 func (d *DKGDealer) PopLosers() []*tmtypes.Validator {
-	out := d.GetLosers()
-	d.losers = nil
+	out := d.GetLosers()[d.popped:]
+	d.popped = len(d.losers)
 	return out
 }
 
@@ -217,17 +274,22 @@ func (d *DKGDealer) PopLosers() []*tmtypes.Validator {
 //////////////////////////////////////////////////////////////////////////////
 
 func (d *DKGDealer) HandleDKGPubKey(msg *alias.DKGData) error {
-	var (
-		dec    = gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		pubKey = d.suiteG2.Point()
-	)
-	if err := dec.Decode(pubKey); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("dkgState: failed to decode public key from %s: %v", msg.Addr, err)
+	if !d.admit(msg) {
+		return nil
 	}
-	// TODO: check if we want to slash validators who send duplicate keys
-	// (we probably do).
-	d.pubKeys.Add(&PK2Addr{PK: pubKey, Addr: crypto.Address(msg.Addr)})
+
+	pubKey, err := wire.DecodePoint(d.suiteG2, msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
+	}
+	// The participants are fixed once the generator exists; a late key would
+	// only shift the indexes of the others.
+	if d.participantsFixed {
+		d.logger.Info("DKGDealer: public key received after dealing started", "from", crypto.Address(msg.Addr), "round", d.roundID)
+		return nil
+	}
+	d.pubKeys.Add(&PK2Addr{PK: pubKey, Addr: crypto.Address(msg.Addr), Msg: msg})
 
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
@@ -241,6 +303,13 @@ func (d *DKGDealer) SendDeals() (error, bool) {
 		d.logger.Debug("DKG send deals: dealer is not ready")
 		return nil, false
 	}
+	// Every node must build its generator from the same participants, and
+	// a timeout only tells which keys this node happened to receive. So the
+	// round fails rather than dealing to a subset.
+	if len(d.pubKeys) < d.validators.Size() {
+		d.addMissingLosers(d.pubKeysSenders())
+		return fmt.Errorf("public key phase timed out: have %d of %d keys", len(d.pubKeys), d.validators.Size()), true
+	}
 	d.eventFirer.FireEvent(types.EventDKGPubKeyReceived, nil)
 
 	messages, err := d.GetDeals()
@@ -258,18 +327,19 @@ func (d *DKGDealer) SendDeals() (error, bool) {
 }
 
 func (d *DKGDealer) IsPubKeysReady() bool {
-	return len(d.pubKeys) == d.validators.Size()
+	return len(d.pubKeys) == d.validators.Size() || d.phaseTimedOut
 }
 
 func (d *DKGDealer) GetDeals() ([]*alias.DKGData, error) {
 	d.logger.Debug("DKGDealer get deals start")
 	// It's needed for DistKeyGenerator and for binary search in array
 	sort.Sort(d.pubKeys)
-	dkgInstance, err := dkg.NewDistKeyGenerator(d.suiteG2, d.secKey, d.pubKeys.GetPKs(), (d.validators.Size()*2)/3)
+	dkgInstance, err := dkg.NewDistKeyGenerator(d.randomSuite(), d.secKey, d.pubKeys.GetPKs(), d.threshold())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dkgState instance: %v", err)
 	}
 	d.instance = dkgInstance
+	d.participantsFixed = true
 
 	// We have N - 1 deals produced here (here and below N stands for the number of validators).
 	deals, err := d.instance.Deals()
@@ -280,15 +350,35 @@ func (d *DKGDealer) GetDeals() ([]*alias.DKGData, error) {
 		d.participantID = int(deal.Index) // Same for each deal.
 		break
 	}
+	for _, msg := range d.earlyDeals {
+		if msg.ToIndex != d.participantID {
+			continue
+		}
+		if _, exists := d.deals[msg.GetAddrString()]; exists {
+			continue
+		}
+		deal, err := wire.DecodeDeal(d.suiteG2, msg.Data)
+		if err != nil {
+			continue
+		}
+		d.deals[msg.GetAddrString()] = deal
+		d.dealMsgs[msg.GetAddrString()] = msg
+	}
+	d.earlyDeals = nil
 
-	var dealMessages []*alias.DKGData
-	for toIndex, deal := range deals {
-		var (
-			buf = bytes.NewBuffer(nil)
-			enc = gob.NewEncoder(buf)
-		)
+	// Deals go out in the order of their indexes, so that a resumed dealer
+	// sends the same messages.
+	indexes := make([]int, 0, len(deals))
+	for toIndex := range deals {
+		indexes = append(indexes, toIndex)
+	}
+	sort.Ints(indexes)
 
-		if err := enc.Encode(deal); err != nil {
+	var dealMessages []*alias.DKGData
+	for _, toIndex := range indexes {
+		deal := deals[toIndex]
+		data, err := wire.EncodeDeal(deal)
+		if err != nil {
 			return dealMessages, fmt.Errorf("failed to encode deal #%d: %v", deal.Index, err)
 		}
 
@@ -296,7 +386,7 @@ func (d *DKGDealer) GetDeals() ([]*alias.DKGData, error) {
 			Type:    alias.DKGDeal,
 			RoundID: d.roundID,
 			Addr:    d.addrBytes,
-			Data:    buf.Bytes(),
+			Data:    data,
 			ToIndex: toIndex,
 		}
 
@@ -308,17 +398,21 @@ func (d *DKGDealer) GetDeals() ([]*alias.DKGData, error) {
 }
 
 func (d *DKGDealer) HandleDKGDeal(msg *alias.DKGData) error {
-	var (
-		dec  = gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		deal = &dkg.Deal{ // We need to initialize everything down to the kyber.Point to avoid nil panics.
-			Deal: &vss.EncryptedDeal{
-				DHKey: d.suiteG2.Point(),
-			},
-		}
-	)
-	if err := dec.Decode(deal); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("failed to decode deal: %v", err)
+	if !d.admit(msg) {
+		return nil
+	}
+
+	deal, err := wire.DecodeDeal(d.suiteG2, msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
+	}
+
+	// The own index is known once the dealer has created its deals, the
+	// deals received before that are sorted out then.
+	if d.instance == nil {
+		d.earlyDeals = append(d.earlyDeals, msg)
+		return nil
 	}
 
 	// We expect to keep N - 1 deals (we don't care about the deals sent to other participants).
@@ -334,6 +428,7 @@ func (d *DKGDealer) HandleDKGDeal(msg *alias.DKGData) error {
 	}
 
 	d.deals[msg.GetAddrString()] = deal
+	d.dealMsgs[msg.GetAddrString()] = msg
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
 	}
@@ -347,6 +442,14 @@ func (d *DKGDealer) ProcessDeals() (error, bool) {
 		return nil, false
 	}
 
+	if d.phaseTimedOut {
+		senders := map[string]bool{crypto.Address(d.addrBytes).String(): true}
+		for addr := range d.deals {
+			senders[addr] = true
+		}
+		d.addMissingLosers(senders)
+	}
+
 	d.logger.Info("dkgState: processing deals")
 	responseMessages, err := d.GetResponses()
 	if err != nil {
@@ -362,23 +465,55 @@ func (d *DKGDealer) ProcessDeals() (error, bool) {
 }
 
 func (d *DKGDealer) IsDealsReady() bool {
-	return len(d.deals) >= d.validators.Size()-1
+	return len(d.deals) >= d.validators.Size()-1 || d.phaseTimedOut
+}
+
+// checkDeal opens the deal with a verifier of its own. The instance must not
+// see a deal it fails to open: kyber keeps the verifier of such a deal, which
+// then panics on the responses to it.
+func (d *DKGDealer) checkDeal(deal *dkg.Deal) error {
+	pks := d.pubKeys.GetPKs()
+	if int(deal.Index) >= len(pks) {
+		return fmt.Errorf("dealer index %d is out of bounds", deal.Index)
+	}
+	verifier, err := vss.NewVerifier(d.suiteG2, d.secKey, pks[deal.Index], pks)
+	if err != nil {
+		return err
+	}
+	_, err = verifier.ProcessEncryptedDeal(deal.Deal)
+	return err
 }
 
 func (d *DKGDealer) GetResponses() ([]*alias.DKGData, error) {
 	var messages []*alias.DKGData
 	d.logger.Debug("DKGDealer get responses start")
 	// Each deal produces a response for the deal's issuer (that makes N - 1 responses).
-	for _, deal := range d.deals {
+	// The deals and the messages of every later phase are processed in the
+	// order of their senders, so that a resumed dealer sends the same
+	// messages.
+	addrs := make([]string, 0, len(d.deals))
+	for addr := range d.deals {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		deal := d.deals[addr]
+		if err := d.checkDeal(deal); err != nil {
+			// The deal can not be opened or is not ours; no response is
+			// sent and the dealer is left out of QUAL.
+			d.logger.Info("DKGDealer: rejecting deal", "from", addr, "error", err)
+			d.dealProofs[addr] = d.dealProof(types.LoserInvalidDeal, addr, deal.Deal.DHKey)
+			continue
+		}
 		resp, err := d.instance.ProcessDeal(deal)
 		if err != nil {
 			return messages, fmt.Errorf("failed to ProcessDeal: %v", err)
 		}
-		var (
-			buf = bytes.NewBuffer(nil)
-			enc = gob.NewEncoder(buf)
-		)
-		if err := enc.Encode(resp); err != nil {
+		if !resp.Response.Approved {
+			d.dealProofs[addr] = d.dealProof(types.LoserInvalidDeal, addr, deal.Deal.DHKey)
+		}
+		data, err := wire.EncodeResponse(resp)
+		if err != nil {
 			return messages, fmt.Errorf("failed to encode response: %v", err)
 		}
 
@@ -386,7 +521,7 @@ func (d *DKGDealer) GetResponses() ([]*alias.DKGData, error) {
 			Type:    alias.DKGResponse,
 			RoundID: d.roundID,
 			Addr:    d.addrBytes,
-			Data:    buf.Bytes(),
+			Data:    data,
 		})
 	}
 	d.eventFirer.FireEvent(types.EventDKGDealsProcessed, d.roundID)
@@ -396,13 +531,14 @@ func (d *DKGDealer) GetResponses() ([]*alias.DKGData, error) {
 }
 
 func (d *DKGDealer) HandleDKGResponse(msg *alias.DKGData) error {
-	var (
-		dec  = gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		resp = &dkg.Response{}
-	)
-	if err := dec.Decode(resp); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("failed to response deal: %v", err)
+	if !d.admit(msg) {
+		return nil
+	}
+
+	resp, err := wire.DecodeResponse(msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
 	}
 
 	// Unlike the procedure for deals, with responses we do care about other
@@ -430,11 +566,16 @@ func (d *DKGDealer) ProcessResponses() (error, bool) {
 		d.logger.Debug("DKGDealer process responses: responses are not ready")
 		return nil, false
 	}
-
 	messages, err := d.GetJustifications()
 	if err != nil {
 		return fmt.Errorf("failed to get justifications: %v", err), true
 	}
+	if d.phaseTimedOut {
+		d.addMissingLosers(d.responses.senders(d.addrBytes))
+		// Verifiers that did not respond in time are treated as complaints;
+		// this must follow the responses received, or they would clash.
+		d.instance.SetTimeout()
+	}
 
 	if err = d.SendMsgCb(messages); err != nil {
 		return fmt.Errorf("failed to sign message: %v", err), true
@@ -445,7 +586,7 @@ func (d *DKGDealer) ProcessResponses() (error, bool) {
 }
 
 func (d *DKGDealer) IsResponsesReady() bool {
-	return d.responses.messagesCount >= int(math.Pow(float64(d.validators.Size()-1), 2))
+	return d.responses.messagesCount >= int(math.Pow(float64(d.validators.Size()-1), 2)) || d.phaseTimedOut
 }
 
 func (d *DKGDealer) processResponse(resp *dkg.Response) ([]byte, error) {
@@ -455,50 +596,53 @@ func (d *DKGDealer) processResponse(resp *dkg.Response) ([]byte, error) {
 
 	justification, err := d.instance.ProcessResponse(resp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to ProcessResponse: %v", err)
+		// E.g. a response to a deal we have not received; it does not
+		// count towards the dealer's approvals.
+		d.logger.Info("dkgState: rejecting response", "dealer", resp.Index, "from", resp.Response.Index, "error", err)
+		return nil, nil
 	}
 	if justification == nil {
 		d.logger.Debug("justification is nil")
 		return nil, nil
 	}
 
-	var (
-		buf = bytes.NewBuffer(nil)
-		enc = gob.NewEncoder(buf)
-	)
-	if err := enc.Encode(justification); err != nil {
-		return nil, fmt.Errorf("failed to encode response: %v", err)
+	data, err := wire.EncodeJustification(justification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode justification: %v", err)
 	}
 
-	return buf.Bytes(), nil
+	return data, nil
 }
 
+// GetJustifications answers the complaints about our own deal. Only the
+// actual justifications are sent, followed by an acknowledgement: an empty
+// justification that tells the others how many to expect from us.
 func (d *DKGDealer) GetJustifications() ([]*alias.DKGData, error) {
 	var messages []*alias.DKGData
-	d.logger.Debug("DKG delaer get justification start")
-	for _, peerResponses := range d.responses.addrToData {
-		for _, response := range peerResponses {
-			resp := response.(*dkg.Response)
-			var msg = &alias.DKGData{
-				Type:    alias.DKGJustification,
-				RoundID: d.roundID,
-				Addr:    d.addrBytes,
-			}
-
-			// Each of (N - 1) ^ 2 received response generates a (possibly nil) justification.
-			// Nil justifications (and other nil messages) are used to avoid having timeouts
-			// (i.e., this allows us to know exactly how many messages should be received to
-			// proceed). This might be changed in the future.
-			justificationBytes, err := d.processResponse(resp)
+	d.logger.Debug("DKG dealer get justification start")
+	for _, addr := range d.responses.addrs() {
+		for _, response := range d.responses.addrToData[addr] {
+			justificationBytes, err := d.processResponse(response.(*dkg.Response))
 			if err != nil {
 				return messages, err
 			}
-
-			msg.Data = justificationBytes
-			// We will nave N * (N - 1) ^ 2 justifications. This looks rather bad, actually
-			messages = append(messages, msg)
+			if justificationBytes == nil {
+				continue
+			}
+			messages = append(messages, &alias.DKGData{
+				Type:    alias.DKGJustification,
+				RoundID: d.roundID,
+				Addr:    d.addrBytes,
+				Data:    justificationBytes,
+			})
 		}
 	}
+	messages = append(messages, &alias.DKGData{
+		Type:        alias.DKGJustification,
+		RoundID:     d.roundID,
+		Addr:        d.addrBytes,
+		NumEntities: len(messages),
+	})
 
 	d.logger.Debug("DKG dealer get justification finish")
 	d.eventFirer.FireEvent(types.EventDKGResponsesProcessed, d.roundID)
@@ -506,17 +650,20 @@ func (d *DKGDealer) GetJustifications() ([]*alias.DKGData, error) {
 }
 
 func (d *DKGDealer) HandleDKGJustification(msg *alias.DKGData) error {
-	var justification *dkg.Justification
-	if msg.Data != nil {
-		dec := gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		justification = &dkg.Justification{}
-		if err := dec.Decode(justification); err != nil {
-			d.losers = append(d.losers, crypto.Address(msg.Addr))
-			return fmt.Errorf("failed to decode justification: %v", err)
-		}
+	if !d.admit(msg) {
+		return nil
 	}
 
-	d.justifications.add(msg.GetAddrString(), 0, justification)
+	if len(msg.Data) == 0 {
+		d.justificationAcks[msg.GetAddrString()] = msg.NumEntities
+	} else {
+		justification, err := wire.DecodeJustification(d.suiteG2, msg.Data)
+		if err != nil {
+			d.addMalformed(msg, err)
+			return nil
+		}
+		d.justifications.add(msg.GetAddrString(), 0, justification)
+	}
 
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
@@ -531,6 +678,14 @@ func (d *DKGDealer) ProcessJustifications() (error, bool) {
 
 		return nil, false
 	}
+	if d.phaseTimedOut {
+		senders := map[string]bool{crypto.Address(d.addrBytes).String(): true}
+		for addr := range d.justificationAcks {
+			senders[addr] = true
+		}
+		d.addMissingLosers(senders)
+		d.instance.SetTimeout()
+	}
 	d.logger.Info("dkgState: processing justifications")
 
 	commits, err := d.GetCommits()
@@ -539,19 +694,16 @@ func (d *DKGDealer) ProcessJustifications() (error, bool) {
 		return err, true
 	}
 
-	var (
-		buf = bytes.NewBuffer(nil)
-		enc = gob.NewEncoder(buf)
-	)
-	if err = enc.Encode(commits); err != nil {
-		return fmt.Errorf("failed to encode response: %v", err), true
+	data, err := wire.EncodeSecretCommits(commits)
+	if err != nil {
+		return fmt.Errorf("failed to encode commits: %v", err), true
 	}
 
 	message := &alias.DKGData{
 		Type:        alias.DKGCommits,
 		RoundID:     d.roundID,
 		Addr:        d.addrBytes,
-		Data:        buf.Bytes(),
+		Data:        data,
 		NumEntities: len(commits.Commitments),
 	}
 
@@ -564,22 +716,30 @@ func (d *DKGDealer) ProcessJustifications() (error, bool) {
 	return nil, true
 }
 
+// IsJustificationsReady reports whether every participant has acknowledged
+// the phase and all the justifications announced have been received.
 func (d *DKGDealer) IsJustificationsReady() bool {
-	// N * (N - 1) ^ 2.
-	return d.justifications.messagesCount >= d.validators.Size()*int(math.Pow(float64(d.validators.Size()-1), 2))
+	if d.phaseTimedOut {
+		return true
+	}
+	if len(d.justificationAcks) < d.validators.Size() {
+		return false
+	}
+	for addr, count := range d.justificationAcks {
+		if len(d.justifications.addrToData[addr]) < count {
+			return false
+		}
+	}
+	return true
 }
 
-func (d DKGDealer) GetCommits() (*dkg.SecretCommits, error) {
-	for _, peerJustifications := range d.justifications.addrToData {
-		for _, just := range peerJustifications {
+func (d *DKGDealer) GetCommits() (*dkg.SecretCommits, error) {
+	for _, addr := range d.justifications.addrs() {
+		for _, just := range d.justifications.addrToData[addr] {
 			justification := just.(*dkg.Justification)
-			if justification != nil {
-				d.logger.Info("dkgState: processing non-empty justification", "from", justification.Index)
-				if err := d.instance.ProcessJustification(justification); err != nil {
-					return nil, fmt.Errorf("failed to ProcessJustification: %v", err)
-				}
-			} else {
-				d.logger.Info("dkgState: empty justification, everything is o.k.")
+			d.logger.Info("dkgState: processing justification", "from", justification.Index)
+			if err := d.instance.ProcessJustification(justification); err != nil {
+				d.logger.Info("dkgState: rejecting justification", "from", justification.Index, "error", err)
 			}
 		}
 	}
@@ -599,12 +759,16 @@ func (d DKGDealer) GetCommits() (*dkg.SecretCommits, error) {
 		}
 
 		for idx, pk2addr := range d.pubKeys {
-			if !qualSet[idx] {
-				d.losers = append(d.losers, pk2addr.Addr)
+			if !qualSet[idx] && !d.isLoser(pk2addr.Addr) {
+				d.addLoser(d.qualProof(pk2addr.Addr))
 			}
 		}
 
-		return nil, errors.New("some of participants failed to complete phase I")
+		// With phase timeouts enabled the round goes on as long as the instance
+		// is certified, i.e. QUAL holds at least a threshold of participants.
+		if d.timeouts.IsZero() {
+			return nil, errors.New("some of participants failed to complete phase I")
+		}
 	}
 
 	commits, err := d.instance.SecretCommits()
@@ -622,14 +786,14 @@ func (d DKGDealer) GetCommits() (*dkg.SecretCommits, error) {
 //////////////////////////////////////////////////////////////////////////////
 
 func (d *DKGDealer) HandleDKGCommit(msg *alias.DKGData) error {
-	dec := gob.NewDecoder(bytes.NewBuffer(msg.Data))
-	commits := &dkg.SecretCommits{}
-	for i := 0; i < msg.NumEntities; i++ {
-		commits.Commitments = append(commits.Commitments, d.suiteG2.Point())
+	if !d.admit(msg) {
+		return nil
 	}
-	if err := dec.Decode(commits); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("failed to decode commit: %v", err)
+
+	commits, err := wire.DecodeSecretCommits(d.suiteG2, msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
 	}
 	d.commits.add(msg.GetAddrString(), 0, commits)
 
@@ -641,37 +805,39 @@ func (d *DKGDealer) HandleDKGCommit(msg *alias.DKGData) error {
 }
 
 func (d *DKGDealer) ProcessCommits() (error, bool) {
-	if d.commits.messagesCount < len(d.instance.QUAL()) {
+	if d.commits.messagesCount < len(d.instance.QUAL()) && !d.phaseTimedOut {
 		d.logger.Debug("commits messages count is not enough", "commits", d.commits.messagesCount, "qual len", len(d.instance.QUAL()))
 		return nil, false
 	}
+	if d.phaseTimedOut {
+		d.addMissingLosers(d.commits.senders(nil))
+	}
 	d.logger.Info("dkgState: processing commits")
 
 	var alreadyFinished = true
 	var messages []*alias.DKGData
-	for _, commitsFromAddr := range d.commits.addrToData {
-		for _, c := range commitsFromAddr {
+	for _, addr := range d.commits.addrs() {
+		for _, c := range d.commits.addrToData[addr] {
 			commits := c.(*dkg.SecretCommits)
 			var msg = &alias.DKGData{
 				Type:    alias.DKGComplaint,
 				RoundID: d.roundID,
 				Addr:    d.addrBytes,
+				ToIndex: int(commits.Index),
 			}
 			complaint, err := d.instance.ProcessSecretCommits(commits)
 			if err != nil {
-				return fmt.Errorf("failed to ProcessSecretCommits: %v", err), true
+				d.logger.Info("dkgState: rejecting commits", "dealer", commits.Index, "error", err)
+				continue
 			}
 			// TODO: check if we *really* need to add the complained dealer to losers.
 			if complaint != nil {
 				alreadyFinished = false
-				var (
-					buf = bytes.NewBuffer(nil)
-					enc = gob.NewEncoder(buf)
-				)
-				if err := enc.Encode(complaint); err != nil {
-					return fmt.Errorf("failed to encode response: %v", err), true
+				data, err := wire.EncodeComplaintCommits(complaint)
+				if err != nil {
+					return fmt.Errorf("failed to encode complaint: %v", err), true
 				}
-				msg.Data = buf.Bytes()
+				msg.Data = data
 				msg.NumEntities = len(complaint.Deal.Commitments)
 			}
 			messages = append(messages, msg)
@@ -693,22 +859,20 @@ func (d *DKGDealer) ProcessCommits() (error, bool) {
 }
 
 func (d *DKGDealer) HandleDKGComplaint(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
 	var complaint *dkg.ComplaintCommits
-	if msg.Data != nil {
-		dec := gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		complaint = &dkg.ComplaintCommits{
-			Deal: &vss.Deal{},
-		}
-		for i := 0; i < msg.NumEntities; i++ {
-			complaint.Deal.Commitments = append(complaint.Deal.Commitments, d.suiteG2.Point())
-		}
-		if err := dec.Decode(complaint); err != nil {
-			d.losers = append(d.losers, crypto.Address(msg.Addr))
-			return fmt.Errorf("failed to decode complaint: %v", err)
+	if len(msg.Data) != 0 {
+		var err error
+		if complaint, err = wire.DecodeComplaintCommits(d.suiteG2, msg.Data); err != nil {
+			d.addMalformed(msg, err)
+			return nil
 		}
 	}
 
-	d.complaints.add(msg.GetAddrString(), 0, complaint)
+	d.complaints.add(msg.GetAddrString(), msg.ToIndex, complaint)
 
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
@@ -718,41 +882,48 @@ func (d *DKGDealer) HandleDKGComplaint(msg *alias.DKGData) error {
 }
 
 func (d *DKGDealer) ProcessComplaints() (error, bool) {
-	if d.complaints.messagesCount < len(d.instance.QUAL())-1 {
+	if d.complaints.messagesCount < len(d.instance.QUAL())-1 && !d.phaseTimedOut {
 		d.logger.Debug("complaints messages count is not enough", "commits", d.complaints.messagesCount, "qual len", len(d.instance.QUAL())-1)
 		return nil, false
 	}
 	d.logger.Info("dkgState: processing commits")
 
-	for _, peerComplaints := range d.complaints.addrToData {
-		for _, c := range peerComplaints {
+	// A message per dealer, with the reconstruction of the dealer's
+	// commitments if someone has complained about them.
+	var dealers []int
+	for idx := range d.complaints.indexToData {
+		dealers = append(dealers, idx)
+	}
+	sort.Ints(dealers)
+	for _, idx := range dealers {
+		var msg = &alias.DKGData{
+			Type:    alias.DKGReconstructCommit,
+			RoundID: d.roundID,
+			Addr:    d.addrBytes,
+			ToIndex: idx,
+		}
+		for _, c := range d.complaints.indexToData[idx] {
 			complaint := c.(*dkg.ComplaintCommits)
-			var msg = &alias.DKGData{
-				Type:    alias.DKGReconstructCommit,
-				RoundID: d.roundID,
-				Addr:    d.addrBytes,
+			if complaint == nil {
+				continue
 			}
-			if complaint != nil {
-				reconstructionMsg, err := d.instance.ProcessComplaintCommits(complaint)
+			reconstructionMsg, err := d.instance.ProcessComplaintCommits(complaint)
+			if err != nil {
+				d.logger.Info("dkgState: rejecting complaint", "dealer", idx, "from", complaint.Index, "error", err)
+				continue
+			}
+			if reconstructionMsg != nil {
+				data, err := wire.EncodeReconstructCommits(reconstructionMsg)
 				if err != nil {
-					return fmt.Errorf("failed to ProcessComplaintCommits: %v", err), true
-				}
-				if reconstructionMsg != nil {
-					var (
-						buf = bytes.NewBuffer(nil)
-						enc = gob.NewEncoder(buf)
-					)
-					if err = enc.Encode(complaint); err != nil {
-						return fmt.Errorf("failed to encode response: %v", err), true
-					}
-					msg.Data = buf.Bytes()
+					return fmt.Errorf("failed to encode reconstruct commits: %v", err), true
 				}
+				msg.Data = data
 			}
+			break
+		}
 
-			if err := d.SendMsgCb([]*alias.DKGData{msg}); err != nil {
-				return fmt.Errorf("failed to sign message: %v", err), true
-			}
-
+		if err := d.SendMsgCb([]*alias.DKGData{msg}); err != nil {
+			return fmt.Errorf("failed to sign message: %v", err), true
 		}
 	}
 	d.logger.Debug("DKG process complaints success")
@@ -761,17 +932,20 @@ func (d *DKGDealer) ProcessComplaints() (error, bool) {
 }
 
 func (d *DKGDealer) HandleDKGReconstructCommit(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
 	var rc *dkg.ReconstructCommits
-	if msg.Data != nil {
-		dec := gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		rc = &dkg.ReconstructCommits{}
-		if err := dec.Decode(rc); err != nil {
-			d.losers = append(d.losers, crypto.Address(msg.Addr))
-			return fmt.Errorf("failed to decode complaint: %v", err)
+	if len(msg.Data) != 0 {
+		var err error
+		if rc, err = wire.DecodeReconstructCommits(d.suiteG2, msg.Data); err != nil {
+			d.addMalformed(msg, err)
+			return nil
 		}
 	}
 
-	d.reconstructCommits.add(msg.GetAddrString(), 0, rc)
+	d.reconstructCommits.add(msg.GetAddrString(), msg.ToIndex, rc)
 
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
@@ -781,20 +955,20 @@ func (d *DKGDealer) HandleDKGReconstructCommit(msg *alias.DKGData) error {
 }
 
 func (d *DKGDealer) ProcessReconstructCommits() (error, bool) {
-	if d.reconstructCommits.messagesCount < len(d.instance.QUAL())-1 {
+	if d.reconstructCommits.messagesCount < len(d.instance.QUAL())-1 && !d.phaseTimedOut {
 		d.logger.Debug("reconstruct commits low messages count", "messages count", d.reconstructCommits.messagesCount,
 			"QUAL - 1", len(d.instance.QUAL())-1)
 		return nil, false
 	}
 
-	for _, peerReconstructCommits := range d.reconstructCommits.addrToData {
-		for _, reconstructCommit := range peerReconstructCommits {
+	for _, addr := range d.reconstructCommits.addrs() {
+		for _, reconstructCommit := range d.reconstructCommits.addrToData[addr] {
 			rc := reconstructCommit.(*dkg.ReconstructCommits)
 			if rc == nil {
 				continue
 			}
 			if err := d.instance.ProcessReconstructCommits(rc); err != nil {
-				return fmt.Errorf("failed to ProcessReconstructCommits: %v", err), true
+				d.logger.Info("dkgState: rejecting reconstruct commits", "dealer", rc.DealerIndex, "from", rc.Index, "error", err)
 			}
 		}
 	}
@@ -818,16 +992,21 @@ func (d *DKGDealer) GetVerifier() (types.Verifier, error) {
 	}
 
 	var (
-		masterPubKey = share.NewPubPoly(bn256.NewSuiteG2(), nil, distKeyShare.Commitments())
+		masterPubKey = share.NewPubPoly(d.suiteG2, nil, distKeyShare.Commitments())
 		newShare     = &blsShare.BLSShare{
 			ID:   d.participantID,
 			Pub:  &share.PubShare{I: d.participantID, V: d.pubKey},
 			Priv: distKeyShare.PriShare(),
 		}
-		t, n = (d.validators.Size() / 3) * 2 + 1, d.validators.Size()
+		t, n = d.threshold(), len(d.pubKeys)
 	)
+	if err := blsShare.CheckThreshold(masterPubKey, t, n); err != nil {
+		return nil, err
+	}
 
-	return blsShare.NewBLSVerifier(masterPubKey, newShare, t, n), nil
+	verifier := blsShare.NewSuiteBLSVerifier(d.suite, masterPubKey, []*blsShare.BLSShare{newShare}, t, n)
+	verifier.SetHolders(d.pubKeys.addrs())
+	return verifier, nil
 }
 
 // VerifyMessage verify message by signature
@@ -854,6 +1033,7 @@ func (d *DKGDealer) SendMsgCb(msg []*alias.DKGData) error {
 type PK2Addr struct {
 	Addr crypto.Address
 	PK   kyber.Point
+	Msg  *alias.DKGData
 }
 
 type PKStore []*PK2Addr
@@ -872,6 +1052,17 @@ func (s *PKStore) Add(newPk *PK2Addr) bool {
 func (s PKStore) Len() int           { return len(s) }
 func (s PKStore) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s PKStore) Less(i, j int) bool { return s[i].Addr.String() < s[j].Addr.String() }
+
+// addrs returns the addresses of the keys; the share indexes of the round are
+// the positions in the sorted store.
+func (s PKStore) addrs() []string {
+	out := make([]string, len(s))
+	for idx, val := range s {
+		out[idx] = val.Addr.String()
+	}
+	return out
+}
+
 func (s PKStore) GetPKs() []kyber.Point {
 	var out = make([]kyber.Point, len(s))
 	for idx, val := range s {
@@ -924,3 +1115,26 @@ func (ms *messageStore) add(addr string, index int, val interface{}) {
 
 	ms.messagesCount++
 }
+
+// addrs returns the addresses of the senders in increasing order.
+func (ms *messageStore) addrs() []string {
+	out := make([]string, 0, len(ms.addrToData))
+	for addr := range ms.addrToData {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// senders returns the set of addresses that have sent at least one message;
+// own is added to the set if not nil.
+func (ms *messageStore) senders(own []byte) map[string]bool {
+	out := make(map[string]bool, len(ms.addrToData)+1)
+	for addr := range ms.addrToData {
+		out[addr] = true
+	}
+	if own != nil {
+		out[crypto.Address(own).String()] = true
+	}
+	return out
+}
@@ -1,5 +1,14 @@
 package dealer
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/wire"
+)
+
 func NewDealerConstructor(indexToConstructor map[int]DKGDealerConstructor) func(i int) DKGDealerConstructor {
 	return func(i int) DKGDealerConstructor {
 		if constructor, ok := indexToConstructor[i]; ok {
@@ -8,3 +17,49 @@ func NewDealerConstructor(indexToConstructor map[int]DKGDealerConstructor) func(
 		return NewDKGDealer
 	}
 }
+
+// Faulty dealers that skip one or all of the messages of a phase.
+var (
+	NewDKGMockDealerNoDeal            = newDropDealer(alias.DKGDeal, 1)
+	NewDKGMockDealerAnyDeal           = newDropDealer(alias.DKGDeal, 0)
+	NewDKGMockDealerNoResponse        = newDropDealer(alias.DKGResponse, 1)
+	NewDKGMockDealerAnyResponses      = newDropDealer(alias.DKGResponse, 0)
+	NewDKGMockDealerNoJustification   = newDropDealer(alias.DKGJustification, 1)
+	NewDKGMockDealerAnyJustifications = newDropDealer(alias.DKGJustification, 0)
+	NewDKGMockDealerAnyCommits        = newDropDealer(alias.DKGCommits, 0)
+
+	// NewDKGMockDealerNoCommit sends its commits without the last
+	// commitment.
+	NewDKGMockDealerNoCommit = NewByzantineDealerConstructor(NewDKGDealer, ByzantineRule{
+		Action:  ByzantineCorrupt,
+		Types:   []alias.DKGDataType{alias.DKGCommits},
+		Corrupt: dropLastCommitment,
+	})
+)
+
+func newDropDealer(dataType alias.DKGDataType, limit int) DKGDealerConstructor {
+	return NewByzantineDealerConstructor(NewDKGDealer, ByzantineRule{
+		Action: ByzantineDrop,
+		Types:  []alias.DKGDataType{dataType},
+		Limit:  limit,
+	})
+}
+
+func dropLastCommitment(suite *blsShare.Suite, msg *alias.DKGData) error {
+	if suite == nil {
+		return errors.New("the suite of the dealer is not set")
+	}
+	commits, err := wire.DecodeSecretCommits(suite.G2, msg.Data)
+	if err != nil {
+		return err
+	}
+	if len(commits.Commitments) == 0 {
+		return errors.New("no commitments to drop")
+	}
+	commits.Commitments = commits.Commitments[:len(commits.Commitments)-1]
+	if msg.Data, err = wire.EncodeSecretCommits(commits); err != nil {
+		return fmt.Errorf("failed to encode commits: %v", err)
+	}
+	msg.NumEntities = len(commits.Commitments)
+	return nil
+}
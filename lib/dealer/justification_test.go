@@ -0,0 +1,89 @@
+package dealer
+
+import (
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+)
+
+// inject delivers a message signed by dealer from to dealer to only.
+func (r *testRound) inject(to, from int, msg *alias.DKGData) {
+	r.t.Helper()
+	msg.Addr = r.pvs[from].GetPubKey().Address()
+	if err := r.pvs[from].SignData("", msg); err != nil {
+		r.t.Fatal(err)
+	}
+	r.inboxes[to] = append(r.inboxes[to], msg)
+}
+
+func justificationAck(count int) *alias.DKGData {
+	return &alias.DKGData{Type: alias.DKGJustification, NumEntities: count}
+}
+
+// A round with no complaints costs one acknowledgement per participant.
+func TestJustificationAcks(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.start()
+	if !r.run(20, r.ready(all(4)...)) {
+		t.Fatalf("no verifiers after %d blocks", r.height)
+	}
+	r.noErrors(all(4)...)
+	for i := range r.dealers {
+		var acks []*alias.DKGData
+		for _, msg := range r.sent[i] {
+			if msg.Type == alias.DKGJustification {
+				acks = append(acks, msg)
+			}
+		}
+		if len(acks) != 1 || len(acks[0].Data) != 0 || acks[0].NumEntities != 0 {
+			t.Fatalf("dealer %d sent %d justifications, want one empty acknowledgement", i, len(acks))
+		}
+	}
+}
+
+// An acknowledgement announcing more justifications than there are
+// validators is malformed.
+func TestJustificationAckBound(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.start()
+	r.inject(0, 3, justificationAck(5))
+	proof := r.loserProof(0, 3, 1)
+	if proof.Reason != types.LoserMalformedMessage {
+		t.Fatalf("reason %s, want %s", proof.Reason, types.LoserMalformedMessage)
+	}
+	if err := types.VerifyLoserProof(blsShare.BN256, r.validators, proof); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bound is the size of the validator set.
+	r = newTestRound(t, 4, NewDKGDealer)
+	r.start()
+	r.inject(0, 3, justificationAck(4))
+	r.step()
+	if proofs := r.dealers[0].GetLoserProofs(); len(proofs) != 0 {
+		t.Fatalf("unexpected loser proofs %+v", proofs)
+	}
+}
+
+// A participant acknowledges once: a second acknowledgement is evidence
+// against it, and the justifications announced by the first one are waited
+// for.
+func TestJustificationAckEquivocation(t *testing.T) {
+	r := newTestRound(t, 4, NewDKGDealer)
+	r.start()
+	r.inject(0, 3, justificationAck(1))
+	proof := r.loserProof(0, 3, 20)
+	if proof.Reason != types.LoserEquivocation {
+		t.Fatalf("reason %s, want %s", proof.Reason, types.LoserEquivocation)
+	}
+	if err := types.VerifyLoserProof(blsShare.BN256, r.validators, proof); err != nil {
+		t.Fatal(err)
+	}
+
+	r.run(20, r.ready(1, 2, 3))
+	if r.verifier(0) != nil {
+		t.Fatal("dealer 0 did not wait for the announced justification")
+	}
+}
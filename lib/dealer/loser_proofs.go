@@ -0,0 +1,89 @@
+package dealer
+
+import (
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
+	"github.com/tendermint/tendermint/crypto"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+)
+
+// addLoser excludes the participant from the round for the reason given in
+// the proof.
+func (d *DKGDealer) addLoser(proof *types.LoserProof) {
+	d.logger.Info("DKGDealer: participant lost the round", "addr", proof.Addr, "reason", proof.Reason, "round", d.roundID)
+	d.losers = append(d.losers, proof)
+}
+
+// addMalformed excludes the sender of a message that can not be decoded. The
+// message is dropped, but the round goes on: one participant must not be able
+// to abort it for everyone.
+func (d *DKGDealer) addMalformed(msg *alias.DKGData, err error) {
+	d.logger.Info("DKGDealer: dropping malformed message", "from", msg.GetAddrString(), "type", msg.Type, "error", err)
+	if d.isLoser(crypto.Address(msg.Addr)) {
+		return
+	}
+	d.addLoser(&types.LoserProof{
+		Addr:    crypto.Address(msg.Addr),
+		Reason:  types.LoserMalformedMessage,
+		RoundID: d.roundID,
+		Message: msg,
+	})
+}
+
+// GetLoserProofs returns the losers along with the proofs of why they lost.
+func (d *DKGDealer) GetLoserProofs() []*types.LoserProof {
+	return d.losers
+}
+
+// dealProof builds the complaint about the deal received from dealerAddr: it
+// reveals the Diffie-Hellman key the deal is encrypted with, so that anyone
+// can open the deal and see that it is bad.
+func (d *DKGDealer) dealProof(reason types.LoserReason, dealerAddr string, dhKey kyber.Point) *types.LoserProof {
+	msg := d.dealMsgs[dealerAddr]
+	proof := &types.LoserProof{
+		Reason:     reason,
+		RoundID:    d.roundID,
+		Message:    msg,
+		Complainer: crypto.Address(d.addrBytes),
+	}
+	if msg != nil {
+		proof.Addr = crypto.Address(msg.Addr)
+	}
+	for _, pk := range d.pubKeys {
+		proof.PubKeys = append(proof.PubKeys, pk.Msg)
+	}
+
+	dlProof, _, xH, err := dleq.NewDLEQProof(d.suiteG2, d.suiteG2.Point().Base(), dhKey, d.secKey)
+	if err == nil {
+		proof.DHReveal, err = wire.EncodeDHReveal(&wire.DHReveal{Key: xH, Proof: dlProof})
+	}
+	if err != nil {
+		d.logger.Error("DKGDealer: failed to reveal DH key", "dealer", dealerAddr, "error", err)
+	}
+
+	return proof
+}
+
+// qualProof is the proof against a participant excluded from QUAL: our own
+// complaint about its deal, if any. Otherwise the exclusion rests on the
+// complaints of others and on justifications that never came, which can not
+// be proven.
+func (d *DKGDealer) qualProof(addr crypto.Address) *types.LoserProof {
+	if proof, ok := d.dealProofs[addr.String()]; ok {
+		return proof
+	}
+	return &types.LoserProof{Addr: addr, Reason: types.LoserNotQualified, RoundID: d.roundID}
+}
+
+// decodeDHKey decodes the ephemeral key of an on-chain deal.
+func (d *DKGDealer) decodeDHKey(data []byte) (kyber.Point, error) {
+	key := d.suiteG2.Point()
+	if err := key.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("failed to decode DH key: %v", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,160 @@
+package dealer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
+	"github.com/tendermint/tendermint/crypto"
+)
+
+// loserProof runs the round until dealer i has a proof against dealer loser.
+func (r *testRound) loserProof(i, loser int, maxBlocks int) *types.LoserProof {
+	r.t.Helper()
+	addr := r.pvs[loser].GetPubKey().Address()
+	var proof *types.LoserProof
+	r.run(maxBlocks, func() bool {
+		for _, p := range r.dealers[i].GetLoserProofs() {
+			if p.Addr.String() == addr.String() {
+				proof = p
+				return true
+			}
+		}
+		return false
+	})
+	if proof == nil {
+		r.t.Fatalf("dealer %d has no proof against dealer %d after %d blocks", i, loser, r.height)
+	}
+	return proof
+}
+
+// byzantine runs a round in which dealer 3 breaks the rule and returns the
+// proof dealer 0 has against it.
+func byzantine(t *testing.T, ctor DKGDealerConstructor, rule ByzantineRule) (*testRound, *types.LoserProof) {
+	r := newTestRound(t, 4, ctor)
+	r.replace(3, NewByzantineDealerConstructor(ctor, rule))
+	r.start()
+	return r, r.loserProof(0, 3, 20)
+}
+
+// randomPoint replaces the payload with another well-formed point.
+func randomPoint(_ *blsShare.Suite, msg *alias.DKGData) error {
+	group := blsShare.BN256.G2
+	var err error
+	msg.Data, err = wire.EncodePoint(group.Point().Pick(group.RandomStream()))
+	return err
+}
+
+func TestVerifyLoserProof(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ctor   DKGDealerConstructor
+		rule   ByzantineRule
+		reason types.LoserReason
+	}{
+		"malformed message": {
+			ctor: NewDKGDealer,
+			rule: ByzantineRule{Action: ByzantineCorrupt, Types: []alias.DKGDataType{alias.DKGResponse}, Limit: 1,
+				Corrupt: func(_ *blsShare.Suite, msg *alias.DKGData) error {
+					msg.Data = []byte("garbage")
+					return nil
+				}},
+			reason: types.LoserMalformedMessage,
+		},
+		"equivocation": {
+			ctor: NewDKGDealer,
+			rule: ByzantineRule{Action: ByzantineEquivocate, Types: []alias.DKGDataType{alias.DKGPubKey},
+				Corrupt: randomPoint},
+			reason: types.LoserEquivocation,
+		},
+		"invalid deal": {
+			ctor: NewOnChainDKGDealer,
+			rule: ByzantineRule{Action: ByzantineCorrupt, Types: []alias.DKGDataType{alias.DKGDeal},
+				Corrupt: func(_ *blsShare.Suite, msg *alias.DKGData) error {
+					deal, err := wire.DecodePedersenDeal(msg.Data)
+					if err != nil {
+						return err
+					}
+					deal.Deal.Cipher[0] ^= 0xff
+					msg.Data, err = wire.EncodePedersenDeal(deal)
+					return err
+				}},
+			reason: types.LoserInvalidDeal,
+		},
+		"commits mismatch": {
+			ctor: NewOnChainDKGDealer,
+			rule: ByzantineRule{Action: ByzantineCorrupt, Types: []alias.DKGDataType{alias.DKGCommits}, Limit: 1,
+				Corrupt: randomPoint},
+			reason: types.LoserCommitsMismatch,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, proof := byzantine(t, tc.ctor, tc.rule)
+			if proof.Reason != tc.reason {
+				t.Fatalf("reason %s, want %s", proof.Reason, tc.reason)
+			}
+			if err := types.VerifyLoserProof(blsShare.BN256, r.validators, proof); err != nil {
+				t.Fatal(err)
+			}
+
+			// The proof does not incriminate anybody else.
+			forged := *proof
+			forged.Addr = crypto.Address(r.pvs[2].GetPubKey().Address())
+			if err := types.VerifyLoserProof(blsShare.BN256, r.validators, &forged); err == nil {
+				t.Fatal("the proof verifies against another validator")
+			}
+			// Nor another round.
+			forged = *proof
+			forged.RoundID++
+			if err := types.VerifyLoserProof(blsShare.BN256, r.validators, &forged); err == nil {
+				t.Fatal("the proof verifies for another round")
+			}
+		})
+	}
+}
+
+// An honest message is no proof.
+func TestVerifyLoserProofHonest(t *testing.T) {
+	r := newTestRound(t, 4, NewOnChainDKGDealer)
+	r.start()
+	var pubKey, deal *alias.DKGData
+	r.run(10, func() bool {
+		for _, msg := range r.sent[3] {
+			switch msg.Type {
+			case alias.DKGPubKey:
+				pubKey = msg
+			case alias.DKGDeal:
+				deal = msg
+			}
+		}
+		return pubKey != nil && deal != nil
+	})
+	if pubKey == nil || deal == nil {
+		t.Fatal("dealer 3 sent no deal")
+	}
+	addr := crypto.Address(r.pvs[3].GetPubKey().Address())
+
+	err := types.VerifyLoserProof(blsShare.BN256, r.validators, &types.LoserProof{
+		Addr: addr, Reason: types.LoserMalformedMessage, Message: pubKey,
+	})
+	if err == nil || !strings.Contains(err.Error(), "well-formed") {
+		t.Fatalf("expected a well-formed message error, got %v", err)
+	}
+
+	err = types.VerifyLoserProof(blsShare.BN256, r.validators, &types.LoserProof{
+		Addr: addr, Reason: types.LoserInvalidDeal, Message: deal,
+		Complainer: crypto.Address(r.pvs[0].GetPubKey().Address()),
+	})
+	if err == nil {
+		t.Fatal("a deal with no DH reveal is proven invalid")
+	}
+
+	for _, reason := range []types.LoserReason{types.LoserMissingMessages, types.LoserNotQualified} {
+		err := types.VerifyLoserProof(blsShare.BN256, r.validators, &types.LoserProof{Addr: addr, Reason: reason})
+		if err != types.ErrLoserProofUnverifiable {
+			t.Fatalf("%s: expected ErrLoserProofUnverifiable, got %v", reason, err)
+		}
+	}
+}
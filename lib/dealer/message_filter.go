@@ -0,0 +1,86 @@
+package dealer
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/tendermint/tendermint/crypto"
+)
+
+// messageFilter makes message ingestion idempotent: a message delivered more
+// than once is handled only the first time. A message that takes a slot
+// already taken by a different message of the same sender is an
+// equivocation.
+type messageFilter struct {
+	seen  map[string]bool
+	slots map[string]*alias.DKGData
+}
+
+func newMessageFilter() *messageFilter {
+	return &messageFilter{
+		seen:  make(map[string]bool),
+		slots: make(map[string]*alias.DKGData),
+	}
+}
+
+// isDuplicate reports whether the very same message has been seen before,
+// and marks the message as seen otherwise.
+func (f *messageFilter) isDuplicate(msg *alias.DKGData) bool {
+	hash := sha256.Sum256(msg.Data)
+	key := fmt.Sprintf("%X/%d/%d/%d/%d/%X", msg.Addr, msg.Type, msg.RoundID, msg.ToIndex, msg.NumEntities, hash)
+	if f.seen[key] {
+		return true
+	}
+	f.seen[key] = true
+	return false
+}
+
+// takeSlot records the message as the one sent by its sender for the slot. If
+// the slot is already taken by another message, evidence is returned.
+func (f *messageFilter) takeSlot(msg *alias.DKGData, slot string) *types.EquivocationEvidence {
+	key := fmt.Sprintf("%X/%d/%d/%s", msg.Addr, msg.Type, msg.RoundID, slot)
+	first, ok := f.slots[key]
+	if !ok {
+		f.slots[key] = msg
+		return nil
+	}
+	return &types.EquivocationEvidence{Addr: crypto.Address(msg.Addr), First: first, Second: msg}
+}
+
+// admit reports whether the message is to be handled. Duplicates are dropped;
+// a malformed message is dropped and its sender excluded; a
+// message conflicting with an earlier one of the same sender is dropped too,
+// and the pair is kept as evidence. Only the messages that are not dropped as
+// duplicates are persisted, so that the evidence survives restarts.
+func (d *DKGDealer) admit(msg *alias.DKGData) bool {
+	if d.filter.isDuplicate(msg) {
+		return false
+	}
+	d.persist(msg)
+
+	if err := types.CheckMessage(d.suiteG2, d.validators, msg); err != nil {
+		d.addMalformed(msg, err)
+		return false
+	}
+
+	slot, ok := types.MessageSlot(msg)
+	if !ok {
+		return true
+	}
+	if ev := d.filter.takeSlot(msg, slot); ev != nil {
+		d.logger.Info("DKGDealer: conflicting messages from the same participant", "from", ev.Addr, "type", msg.Type)
+		d.evidence = append(d.evidence, ev)
+		if !d.isLoser(ev.Addr) {
+			d.addLoser(&types.LoserProof{Addr: ev.Addr, Reason: types.LoserEquivocation, RoundID: d.roundID, Evidence: ev})
+		}
+		return false
+	}
+	return true
+}
+
+// GetEvidence returns the equivocations detected so far.
+func (d *DKGDealer) GetEvidence() []*types.EquivocationEvidence {
+	return d.evidence
+}
@@ -1,23 +1,21 @@
 package dealer
 
 import (
-	"bytes"
-	"encoding/gob"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/corestario/dkglib/lib/blsShare"
 	"go.dedis.ch/kyber/v3/share"
 
 	"go.dedis.ch/kyber/v3"
 
-	"github.com/tendermint/tendermint/crypto"
-
 	"github.com/corestario/dkglib/lib/alias"
 	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
 	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/libs/events"
 	"github.com/tendermint/tendermint/libs/log"
 	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
@@ -26,8 +24,9 @@ import (
 
 type onChainDealer struct {
 	*DKGDealer
-	instance *dkg.DistKeyGenerator
-	deals    map[string]*dkg.Deal
+	instance   *dkg.DistKeyGenerator
+	deals      map[string]*dkg.Deal
+	commitMsgs map[string][]*alias.DKGData
 }
 
 func (d *onChainDealer) GenerateTransitions() {
@@ -47,32 +46,38 @@ func NewOnChainDKGDealer(
 	logger log.Logger,
 	startRound int,
 ) Dealer {
-	return &onChainDealer{
-		deals:     make(map[string]*dkg.Deal),
-		DKGDealer: NewDKGDealer(validators, pv, sendMsgCb, eventFirer, logger, startRound).(*DKGDealer),
+	d := &onChainDealer{
+		deals:      make(map[string]*dkg.Deal),
+		commitMsgs: make(map[string][]*alias.DKGData),
+		DKGDealer:  NewDKGDealer(validators, pv, sendMsgCb, eventFirer, logger, startRound).(*DKGDealer),
 	}
+	// Every participant sends a message per commitment, i.e. up to N messages.
+	d.commits = newMessageStore(validators.Size())
+
+	return d
 }
 
 func (d *onChainDealer) Start() error {
-	d.secKey = d.suiteG2.Scalar().Pick(d.suiteG2.RandomStream())
-	d.pubKey = d.suiteG2.Point().Mul(d.secKey, nil)
+	if err := d.policy.Validate(); err != nil {
+		return err
+	}
+	if err := d.initSecret(); err != nil {
+		return err
+	}
 
 	d.GenerateTransitions()
 
-	var (
-		buf = bytes.NewBuffer(nil)
-		enc = gob.NewEncoder(buf)
-	)
-	if err := enc.Encode(d.pubKey); err != nil {
+	data, err := wire.EncodePoint(d.pubKey)
+	if err != nil {
 		return fmt.Errorf("failed to encode public key: %v", err)
 	}
 
 	d.logger.Info("dkgState: sending pub key", "key", d.pubKey.String())
-	err := d.SendMsgCb([]*alias.DKGData{{
+	err = d.SendMsgCb([]*alias.DKGData{{
 		Type:    alias.DKGPubKey,
 		RoundID: d.roundID,
 		Addr:    d.addrBytes,
-		Data:    buf.Bytes(),
+		Data:    data,
 	}})
 	if err != nil {
 		return fmt.Errorf("failed to sign message: %v", err)
@@ -86,29 +91,39 @@ func (d *onChainDealer) SendCommits() (error, bool) {
 		d.logger.Debug("DKG send commits: dealer is not ready")
 		return nil, false
 	}
+	if d.phaseTimedOut {
+		d.addMissingLosers(d.pubKeysSenders())
+		if len(d.pubKeys) < d.threshold() {
+			return fmt.Errorf("not enough public keys after timeout: have %d, want %d", len(d.pubKeys), d.threshold()), true
+		}
+	}
 
 	// TODO: fire event.
 
-	instance, err := dkg.NewDistKeyGenerator(d.suiteG2, d.secKey, d.pubKeys.GetPKs(), d.validators.Size())
+	// Participants are ordered by address, as off-chain, rather than by the
+	// order their keys landed on chain, so that deals can be verified
+	// knowing nothing but the keys.
+	sort.Sort(d.pubKeys)
+	instance, err := dkg.NewDistKeyGenerator(d.randomSuite(), d.secKey, d.pubKeys.GetPKs(), d.threshold())
 	if err != nil {
 		return fmt.Errorf("failed to execute NewDistKeyGenerator: %w", err), false
 	}
 	d.instance = instance
+	d.participantsFixed = true
 
 	var commitMessages []*alias.DKGData
-	for _, commit := range d.instance.GetDealer().Commits() {
-		var (
-			buf = bytes.NewBuffer(nil)
-			enc = gob.NewEncoder(buf)
-		)
-		if err := enc.Encode(commit); err != nil {
-			return fmt.Errorf("failed to encode public key: %v", err), false
+	for idx, commit := range d.instance.GetDealer().Commits() {
+		data, err := wire.EncodePoint(commit)
+		if err != nil {
+			return fmt.Errorf("failed to encode commit: %v", err), false
 		}
+		// ToIndex is the position of the commitment in the polynomial.
 		commitMessages = append(commitMessages, &alias.DKGData{
 			Type:    alias.DKGCommits,
 			RoundID: d.roundID,
 			Addr:    d.addrBytes,
-			Data:    buf.Bytes(),
+			Data:    data,
+			ToIndex: idx,
 		})
 
 	}
@@ -124,14 +139,19 @@ func (d *onChainDealer) SendCommits() (error, bool) {
 }
 
 func (d *onChainDealer) HandleDKGCommit(msg *alias.DKGData) error {
-	dec := gob.NewDecoder(bytes.NewBuffer(msg.Data))
-	commit := d.suiteG2.Point()
+	// All the messages stored on chain are delivered on every block, so the
+	// ones already seen are dropped.
+	if !d.admit(msg) {
+		return nil
+	}
 
-	if err := dec.Decode(commit); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("failed to decode commit: %v", err)
+	commit, err := wire.DecodePoint(d.suiteG2, msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
 	}
 	d.commits.add(msg.GetAddrString(), 0, commit)
+	d.commitMsgs[msg.GetAddrString()] = append(d.commitMsgs[msg.GetAddrString()], msg)
 
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("failed to Transit: %v", err)
@@ -159,9 +179,15 @@ func (d *onChainDealer) SendDeals() (error, bool) {
 
 	d.logger.Debug("SendDeals, generated deals", "num_deals", len(deals))
 
+	indexes := make([]int, 0, len(deals))
+	for toIndex := range deals {
+		indexes = append(indexes, toIndex)
+	}
+	sort.Ints(indexes)
+
 	var dealMessages []*alias.DKGData
-	for toIndex, deal := range deals {
-		buf, err := deal.Encode()
+	for _, toIndex := range indexes {
+		buf, err := wire.EncodePedersenDeal(deals[toIndex])
 		if err != nil {
 			return fmt.Errorf("SendDeals: failed to encode deal: %w", err), false
 		}
@@ -189,11 +215,15 @@ func (d *onChainDealer) SendDeals() (error, bool) {
 }
 
 func (d *onChainDealer) HandleDKGDeal(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
 	d.logger.Info("HandleDKGDeal: received Deal message", "from", msg.GetAddrString())
-	var deal = &dkg.Deal{}
-	if err := deal.Decode(msg.Data); err != nil {
-		d.losers = append(d.losers, msg.Addr)
-		return fmt.Errorf("HandleDKGDeal: failed to decode deal: %v", err)
+	deal, err := wire.DecodePedersenDeal(msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
 	}
 
 	// We expect to keep N - 1 deals (we don't care about the deals sent to other participants).
@@ -209,6 +239,7 @@ func (d *onChainDealer) HandleDKGDeal(msg *alias.DKGData) error {
 	}
 
 	d.deals[msg.GetAddrString()] = deal
+	d.dealMsgs[msg.GetAddrString()] = msg
 	if err := d.Transit(); err != nil {
 		return fmt.Errorf("HandleDKGDeal: failed to Transit: %v", err)
 	}
@@ -217,7 +248,7 @@ func (d *onChainDealer) HandleDKGDeal(msg *alias.DKGData) error {
 }
 
 func (d *onChainDealer) IsDealsReady() bool {
-	return len(d.deals) >= d.validators.Size()-1
+	return len(d.deals) >= d.validators.Size()-1 || d.phaseTimedOut
 }
 
 func (d *onChainDealer) ProcessDeals() (error, bool) {
@@ -226,48 +257,65 @@ func (d *onChainDealer) ProcessDeals() (error, bool) {
 		d.logger.Debug("onChainDealer: ProcessDeals: process deals, deals are not ready")
 		return nil, false
 	}
+	if d.phaseTimedOut {
+		senders := map[string]bool{crypto.Address(d.addrBytes).String(): true}
+		for addr := range d.deals {
+			senders[addr] = true
+		}
+		d.addMissingLosers(senders)
+	}
+
+	dealerIDs := make([]string, 0, len(d.deals))
+	for dealerID := range d.deals {
+		dealerIDs = append(dealerIDs, dealerID)
+	}
+	sort.Strings(dealerIDs)
 
 	var responseMessages []*alias.DKGData
-	for dealerID, deal := range d.deals {
+	for _, dealerID := range dealerIDs {
+		deal := d.deals[dealerID]
 		// Party does not have to verify its own deal.
 		if deal.Index == uint32(d.participantID) {
 			continue
 		}
 		resp, err := d.instance.ProcessDeal(deal)
 		if err != nil {
-			return err, false
+			d.logger.Info("onChainDealer: rejecting deal", "from", dealerID, "error", err)
+			if dhKey, err := d.decodeDHKey(deal.Deal.DHKey); err == nil && !d.isLoser(crypto.Address(d.dealMsgs[dealerID].Addr)) {
+				d.addLoser(d.dealProof(types.LoserInvalidDeal, dealerID, dhKey))
+			}
+			continue
 		}
 
 		// Commits verification.
 		allVerifiers := d.instance.Verifiers()
 		verifier := allVerifiers[deal.Index]
-		commitsOK, _ := d.ProcessDealCommits(verifier, deal)
+		commitsOK, _ := d.ProcessDealCommits(verifier, deal, dealerID)
 
 		// If something goes wrong, party complains.
 		if !resp.Response.Status || !commitsOK {
-			loserAddress := crypto.Address{}
-			addrBytes, err := hex.DecodeString(dealerID)
+			dhKey, err := d.decodeDHKey(deal.Deal.DHKey)
 			if err != nil {
-				return fmt.Errorf("failed to decode loser address: %w", err), false
+				return err, false
 			}
-			if err := loserAddress.Unmarshal(addrBytes); err != nil {
-				return fmt.Errorf("failed to unmarshal loser address: %w", err), false
+			if !resp.Response.Status {
+				d.addLoser(d.dealProof(types.LoserInvalidDeal, dealerID, dhKey))
+			} else {
+				proof := d.dealProof(types.LoserCommitsMismatch, dealerID, dhKey)
+				proof.Commits = d.commitMsgs[dealerID]
+				d.addLoser(proof)
 			}
-			d.losers = append(d.losers, loserAddress)
 		}
 
-		var (
-			buf = bytes.NewBuffer(nil)
-			enc = gob.NewEncoder(buf)
-		)
-		if err := enc.Encode(resp); err != nil {
-			return fmt.Errorf("failed to encode public key: %v", err), false
+		data, err := wire.EncodePedersenResponse(resp)
+		if err != nil {
+			return fmt.Errorf("failed to encode response: %v", err), false
 		}
 		responseMessages = append(responseMessages, &alias.DKGData{
 			Type:    alias.DKGResponse,
 			RoundID: d.roundID,
 			Addr:    d.addrBytes,
-			Data:    buf.Bytes(),
+			Data:    data,
 		})
 	}
 
@@ -281,14 +329,14 @@ func (d *onChainDealer) ProcessDeals() (error, bool) {
 	return nil, true
 }
 
-func (d *onChainDealer) ProcessDealCommits(verifier *vss.Verifier, deal *dkg.Deal) (bool, error) {
+func (d *onChainDealer) ProcessDealCommits(verifier *vss.Verifier, deal *dkg.Deal, dealerAddr string) (bool, error) {
 	// Verifier decryptDeal.
 	decryptedDeal, err := verifier.DecryptDeal(deal.Deal)
 	if err != nil {
 		return false, err
 	}
 
-	commitsData, ok := d.commits.indexToData[int(deal.Index)]
+	commitsData, ok := d.commits.addrToData[dealerAddr]
 	if !ok {
 		return false, err
 	}
@@ -317,13 +365,14 @@ func (d *onChainDealer) ProcessDealCommits(verifier *vss.Verifier, deal *dkg.Dea
 }
 
 func (d *onChainDealer) HandleDKGResponse(msg *alias.DKGData) error {
-	var (
-		dec  = gob.NewDecoder(bytes.NewBuffer(msg.Data))
-		resp = &dkg.Response{}
-	)
-	if err := dec.Decode(resp); err != nil {
-		d.losers = append(d.losers, crypto.Address(msg.Addr))
-		return fmt.Errorf("failed to response deal: %v", err)
+	if !d.admit(msg) {
+		return nil
+	}
+
+	resp, err := wire.DecodePedersenResponse(msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
 	}
 
 	// Unlike the procedure for deals, with responses we do care about other
@@ -361,12 +410,17 @@ func (d *onChainDealer) ProcessResponses() (error, bool) {
 				continue
 			}
 
-			_, err := d.instance.ProcessResponse(resp)
-			if err != nil {
-				return fmt.Errorf("failed to ProcessResponse: %w", err), false
+			if _, err := d.instance.ProcessResponse(resp); err != nil {
+				d.logger.Info("onChainDealer: rejecting response", "dealer", resp.Index, "from", resp.Response.Index, "error", err)
 			}
 		}
 	}
+	if d.phaseTimedOut {
+		d.addMissingLosers(d.responses.senders(d.addrBytes))
+		// As off chain, the missing responses count as complaints once the
+		// received ones are in.
+		d.instance.SetTimeout()
+	}
 
 	if !d.instance.Certified() {
 		return fmt.Errorf("praticipant %v is not certified", d.participantID), false
@@ -392,12 +446,17 @@ func (d *onChainDealer) GetVerifier() (types.Verifier, error) {
 		Pub:  &share.PubShare{I: d.participantID, V: d.pubKey},
 		Priv: distKeyShare.PriShare(),
 	}
-	t, n := (d.validators.Size()/3)*2, d.validators.Size()
+	t, n := d.threshold(), d.validators.Size()
+	if err := blsShare.CheckThreshold(masterPubKey, t, n); err != nil {
+		return nil, err
+	}
 
 	verificationKey := masterPubKey.Eval(distKeyShare.PriShare().I)
 	if verificationKey == nil {
 		return nil, fmt.Errorf("can't get verification key for %v participant", d.participantID)
 	}
 
-	return blsShare.NewBLSVerifier(masterPubKey, newShare, t, n), nil
+	verifier := blsShare.NewSuiteBLSVerifier(d.suite, masterPubKey, []*blsShare.BLSShare{newShare}, t, n)
+	verifier.SetHolders(d.pubKeys.addrs())
+	return verifier, nil
 }
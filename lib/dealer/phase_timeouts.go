@@ -0,0 +1,99 @@
+package dealer
+
+import (
+	"time"
+
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/tendermint/tendermint/crypto"
+)
+
+// PhaseTimeouts limits the time a dealer waits for the messages of a single
+// phase (pub keys, deals, responses etc.). A phase expires as soon as any of
+// the non-zero limits is exceeded; zero values mean "wait forever".
+type PhaseTimeouts struct {
+	Blocks   int64         // Number of blocks since the phase started.
+	Duration time.Duration // Wall-clock time since the phase started.
+}
+
+func (t PhaseTimeouts) IsZero() bool {
+	return t.Blocks <= 0 && t.Duration <= 0
+}
+
+func (d *DKGDealer) SetPhaseTimeouts(timeouts PhaseTimeouts) {
+	d.timeouts = timeouts
+}
+
+// NewBlock informs the dealer about a new block. If the current phase has
+// expired, the dealer stops waiting for the missing messages and moves on with
+// what it has; participants that did not send anything are added to losers.
+// The pub-key phase is the exception: it fixes the participants, so its
+// timeout fails the round instead.
+func (d *DKGDealer) NewBlock(height int64) error {
+	d.height = height
+	if d.phaseStartHeight == 0 {
+		d.phaseStartHeight = height
+	}
+	if d.phaseTimedOut || len(d.transitions) == 0 || !d.isPhaseExpired() {
+		return nil
+	}
+
+	d.logger.Info("DKGDealer phase timed out, proceeding with received messages",
+		"round", d.roundID, "height", height, "transitions left", len(d.transitions))
+	d.persistTimeout(height)
+
+	return d.expirePhase()
+}
+
+func (d *DKGDealer) expirePhase() error {
+	d.phaseTimedOut = true
+	return d.Transit()
+}
+
+func (d *DKGDealer) isPhaseExpired() bool {
+	if d.timeouts.Blocks > 0 && d.height-d.phaseStartHeight >= d.timeouts.Blocks {
+		return true
+	}
+	if d.timeouts.Duration > 0 && time.Since(d.phaseStartTime) >= d.timeouts.Duration {
+		return true
+	}
+	return false
+}
+
+func (d *DKGDealer) resetPhase() {
+	d.phaseStartHeight = d.height
+	d.phaseStartTime = time.Now()
+	d.phaseTimedOut = false
+}
+
+// threshold is the minimal number of participants required to finish a round.
+func (d *DKGDealer) threshold() int {
+	return d.policy.Threshold(d.validators.Size())
+}
+
+func (d *DKGDealer) pubKeysSenders() map[string]bool {
+	out := make(map[string]bool, len(d.pubKeys))
+	for _, pk := range d.pubKeys {
+		out[pk.Addr.String()] = true
+	}
+	return out
+}
+
+// addMissingLosers adds every validator that is not in senders to losers.
+func (d *DKGDealer) addMissingLosers(senders map[string]bool) {
+	for _, validator := range d.validators.Validators {
+		if senders[validator.Address.String()] || d.isLoser(validator.Address) {
+			continue
+		}
+		d.logger.Info("DKGDealer: no messages received before timeout", "from", validator.Address, "round", d.roundID)
+		d.addLoser(&types.LoserProof{Addr: crypto.Address(validator.Address), Reason: types.LoserMissingMessages, RoundID: d.roundID})
+	}
+}
+
+func (d *DKGDealer) isLoser(addr crypto.Address) bool {
+	for _, loser := range d.losers {
+		if loser.Addr.String() == addr.String() {
+			return true
+		}
+	}
+	return false
+}
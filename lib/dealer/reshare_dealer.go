@@ -0,0 +1,663 @@
+package dealer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+	"github.com/corestario/dkglib/lib/wire"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+)
+
+// reshareDealer moves the shares of the group key from the validators that
+// hold them to a new validator set, keeping the group key (the constant term
+// of the master public polynomial) unchanged. Every holder deals its share to
+// the new validators, which combine the deals of a threshold of holders into
+// shares of the same secret.
+//
+// Both the old and the new validators take part in the round; the old ones
+// are identified by the index of their share, i.e. by their position in the
+// old validator set ordered by address, just as the DKG that produced the
+// shares has ordered them.
+type reshareDealer struct {
+	*DKGDealer
+	instance *dkg.DistKeyGenerator
+
+	oldAddrs     []crypto.Address
+	newAddrs     []crypto.Address
+	masterPubKey *share.PubPoly
+	share        *share.PriShare // Nil for the validators joining the group.
+
+	deals map[string]*dkg.Deal
+	// oldIdx and newIdx are the positions in the old and the new validator
+	// sets, -1 if not present there.
+	oldIdx, newIdx int
+
+	numDealers        int
+	expectedDeals     int
+	expectedResponses int
+	// complaints are the complaints to be answered by justifications, keyed
+	// by dealer and verifier index.
+	complaints map[string]bool
+	justified  map[string]bool
+	finished   bool
+
+	// renew is the share to be refreshed, set if the round reshares zero
+	// (see NewRefreshDealerConstructor).
+	renew *dkg.DistKeyShare
+}
+
+// NewReshareDealerConstructor returns a constructor of dealers that reshare
+// the group key held by oldValidators to the validators the round is run
+// with. The share indexes are the positions of the holders sorted by address,
+// so oldValidators must be the very holders of the shares (see
+// blsShare.BLSVerifier.Holders). masterPubKey is the group's public
+// polynomial and ownShare is the share of the node, nil if the node does not
+// hold one.
+func NewReshareDealerConstructor(oldValidators *tmtypes.ValidatorSet, masterPubKey *share.PubPoly, ownShare *share.PriShare) DKGDealerConstructor {
+	return func(validators *tmtypes.ValidatorSet, pv tmtypes.PrivValidator, sendMsgCb func([]*alias.DKGData) error, eventFirer events.Fireable, logger log.Logger, startRound int) Dealer {
+		return newReshareDealer(oldValidators, validators, sortedAddrs(oldValidators), sortedAddrs(validators), masterPubKey, ownShare, pv, sendMsgCb, eventFirer, logger, startRound)
+	}
+}
+
+// NewRefreshDealerConstructor returns a constructor of dealers that refresh
+// the shares of the group key held by the validators the round is run with.
+// The validators reshare zero and add the shares they get to their own, so
+// the group key stays the same while the shares stolen before the round
+// become useless. Each share keeps its index: holders are the addresses of
+// the share holders by share index (see blsShare.BLSVerifier.Holders), the
+// validators sorted by address if nil. masterPubKey is the group's public
+// polynomial and ownShare is the share of the node.
+func NewRefreshDealerConstructor(holders []crypto.Address, masterPubKey *share.PubPoly, ownShare *share.PriShare) DKGDealerConstructor {
+	return func(validators *tmtypes.ValidatorSet, pv tmtypes.PrivValidator, sendMsgCb func([]*alias.DKGData) error, eventFirer events.Fireable, logger log.Logger, startRound int) Dealer {
+		addrs := holders
+		if addrs == nil {
+			addrs = sortedAddrs(validators)
+		}
+		d := newReshareDealer(validators, validators, addrs, addrs, nil, nil, pv, sendMsgCb, eventFirer, logger, startRound)
+		_, commits := masterPubKey.Info()
+		d.renew = &dkg.DistKeyShare{Commits: commits, Share: ownShare}
+
+		return d
+	}
+}
+
+func newReshareDealer(
+	oldValidators, validators *tmtypes.ValidatorSet,
+	oldAddrs, newAddrs []crypto.Address,
+	masterPubKey *share.PubPoly,
+	ownShare *share.PriShare,
+	pv tmtypes.PrivValidator,
+	sendMsgCb func([]*alias.DKGData) error,
+	eventFirer events.Fireable,
+	logger log.Logger,
+	startRound int,
+) *reshareDealer {
+	participants := unionValidators(oldValidators, validators)
+	d := &reshareDealer{
+		DKGDealer:    NewDKGDealer(participants, pv, sendMsgCb, eventFirer, logger, startRound).(*DKGDealer),
+		oldAddrs:     oldAddrs,
+		newAddrs:     newAddrs,
+		masterPubKey: masterPubKey,
+		share:        ownShare,
+		deals:        make(map[string]*dkg.Deal),
+		complaints:   make(map[string]bool),
+		justified:    make(map[string]bool),
+	}
+	// Every participant responds to the deal of every holder and a holder
+	// answers every complaint about its deal.
+	d.responses = newMessageStore(len(d.oldAddrs))
+	d.justifications = newMessageStore(len(d.newAddrs))
+
+	own := crypto.Address(d.addrBytes).String()
+	d.oldIdx, d.newIdx = indexOf(d.oldAddrs, own), indexOf(d.newAddrs, own)
+
+	return d
+}
+
+func (d *reshareDealer) GenerateTransitions() {
+	d.transitions = []transition{
+		d.SendDeals,
+		d.ProcessDeals,
+		d.ProcessResponses,
+		d.ProcessJustifications,
+	}
+}
+
+func (d *reshareDealer) Start() error {
+	if err := d.policy.Validate(); err != nil {
+		return err
+	}
+	if d.renew != nil {
+		if d.renew.Share == nil {
+			return errors.New("refresh requires the share of the node")
+		}
+		// The refreshed polynomial is the sum of the old and the zero ones.
+		if t := d.threshold(); t != len(d.renew.Commits) {
+			return fmt.Errorf("threshold %d of the refresh differs from threshold %d of the key", t, len(d.renew.Commits))
+		}
+		d.masterPubKey, d.share = d.zeroKey()
+	}
+	if d.masterPubKey == nil {
+		return errors.New("resharing requires the master public key")
+	}
+	if d.share != nil && d.share.I != d.oldIdx {
+		return fmt.Errorf("share #%d does not match position %d in the old validator set", d.share.I, d.oldIdx)
+	}
+	if err := d.initSecret(); err != nil {
+		return err
+	}
+
+	d.GenerateTransitions()
+	d.resetPhase()
+
+	data, err := wire.EncodePoint(d.pubKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %v", err)
+	}
+
+	d.logger.Info("reshareDealer: sending pub key", "key", d.pubKey.String())
+	err = d.SendMsgCb([]*alias.DKGData{{
+		Type:    alias.DKGPubKey,
+		RoundID: d.roundID,
+		Addr:    d.addrBytes,
+		Data:    data,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to sign message: %v", err)
+	}
+
+	return nil
+}
+
+func (d *reshareDealer) SendDeals() (error, bool) {
+	if !d.IsPubKeysReady() {
+		d.logger.Debug("reshareDealer: send deals: pub keys are not ready")
+		return nil, false
+	}
+	if d.phaseTimedOut {
+		d.addMissingLosers(d.pubKeysSenders())
+	}
+	d.eventFirer.FireEvent(types.EventDKGPubKeyReceived, nil)
+
+	instance, err := dkg.NewDistKeyHandler(d.config())
+	if err != nil {
+		return fmt.Errorf("failed to create resharing instance: %v", err), true
+	}
+	d.instance = instance
+	d.participantsFixed = true
+	d.countExpected()
+	if d.numDealers < d.masterPubKey.Threshold() {
+		return fmt.Errorf("not enough share holders: have %d, want %d", d.numDealers, d.masterPubKey.Threshold()), true
+	}
+
+	// Validators joining the group have nothing to deal.
+	deals, err := d.instance.Deals()
+	if err != nil {
+		return fmt.Errorf("failed to get deals: %v", err), true
+	}
+	indexes := make([]int, 0, len(deals))
+	for toIndex := range deals {
+		indexes = append(indexes, toIndex)
+	}
+	sort.Ints(indexes)
+
+	var messages []*alias.DKGData
+	for _, toIndex := range indexes {
+		data, err := wire.EncodePedersenDeal(deals[toIndex])
+		if err != nil {
+			return fmt.Errorf("failed to encode deal: %v", err), true
+		}
+		messages = append(messages, &alias.DKGData{
+			Type:    alias.DKGDeal,
+			RoundID: d.roundID,
+			Addr:    d.addrBytes,
+			Data:    data,
+			ToIndex: toIndex,
+		})
+	}
+	if len(messages) > 0 {
+		if err = d.SendMsgCb(messages); err != nil {
+			return fmt.Errorf("failed to send deals: %v", err), true
+		}
+	}
+
+	d.logger.Info("reshareDealer: sent deals", "deals", len(messages))
+	return nil, true
+}
+
+// config lists the old and the new participants by their round keys. The
+// participants that have not sent their keys are given placeholder keys
+// nobody knows the secret of, so that the indexes are kept.
+func (d *reshareDealer) config() *dkg.Config {
+	keys := make(map[string]kyber.Point, len(d.pubKeys))
+	for _, pk := range d.pubKeys {
+		keys[pk.Addr.String()] = pk.PK
+	}
+	nodes := func(addrs []crypto.Address, list string) []kyber.Point {
+		out := make([]kyber.Point, len(addrs))
+		for i, addr := range addrs {
+			if key, ok := keys[addr.String()]; ok {
+				out[i] = key
+				continue
+			}
+			seed := fmt.Sprintf("dkglib/reshare/%d/%s/%d", d.roundID, list, i)
+			out[i] = d.suiteG2.Point().Pick(d.suiteG2.XOF([]byte(seed)))
+		}
+		return out
+	}
+
+	_, commits := d.masterPubKey.Info()
+	c := &dkg.Config{
+		Suite:        d.randomSuite(),
+		Longterm:     d.secKey,
+		OldNodes:     nodes(d.oldAddrs, "old"),
+		NewNodes:     nodes(d.newAddrs, "new"),
+		Threshold:    d.threshold(),
+		OldThreshold: d.masterPubKey.Threshold(),
+	}
+	if d.share != nil {
+		c.Share = &dkg.DistKeyShare{Commits: commits, Share: d.share}
+	} else {
+		c.PublicCoeffs = commits
+	}
+	return c
+}
+
+// countExpected computes the number of messages to wait for from the keys
+// received: every holder deals to every new validator but itself, and every
+// new validator responds to all of the deals it gets.
+func (d *reshareDealer) countExpected() {
+	present := d.pubKeysSenders()
+	dealers := make(map[string]bool)
+	for _, addr := range d.oldAddrs {
+		if present[addr.String()] {
+			dealers[addr.String()] = true
+		}
+	}
+	d.numDealers = len(dealers)
+
+	own := crypto.Address(d.addrBytes).String()
+	for _, addr := range d.newAddrs {
+		if !present[addr.String()] {
+			continue
+		}
+		expected := d.numDealers
+		if dealers[addr.String()] {
+			expected--
+		}
+		if addr.String() == own {
+			d.expectedDeals = expected
+			continue
+		}
+		d.expectedResponses += expected
+	}
+}
+
+// threshold is the threshold of the new group. A refresh keeps the one of the
+// key, which was computed from the size of the whole validator set.
+func (d *reshareDealer) threshold() int {
+	if d.renew != nil {
+		return d.policy.Threshold(d.validators.Size())
+	}
+	return d.policy.Threshold(len(d.newAddrs))
+}
+
+func (d *reshareDealer) HandleDKGDeal(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
+	deal, err := wire.DecodePedersenDeal(msg.Data)
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
+	}
+	if d.newIdx < 0 || msg.ToIndex != d.newIdx {
+		d.logger.Debug("reshareDealer: rejecting deal (intended for another participant)", "intended", msg.ToIndex, "own_index", d.newIdx)
+		return nil
+	}
+	if _, exists := d.deals[msg.GetAddrString()]; exists {
+		return nil
+	}
+
+	d.deals[msg.GetAddrString()] = deal
+	d.dealMsgs[msg.GetAddrString()] = msg
+	if err := d.Transit(); err != nil {
+		return fmt.Errorf("failed to Transit: %v", err)
+	}
+
+	return nil
+}
+
+func (d *reshareDealer) IsDealsReady() bool {
+	return len(d.deals) >= d.expectedDeals || d.phaseTimedOut
+}
+
+func (d *reshareDealer) ProcessDeals() (error, bool) {
+	if d.newIdx < 0 {
+		// Validators leaving the group only deal.
+		return nil, true
+	}
+	if !d.IsDealsReady() {
+		d.logger.Debug("reshareDealer: process deals: deals are not ready", "have", len(d.deals), "want", d.expectedDeals)
+		return nil, false
+	}
+
+	addrs := make([]string, 0, len(d.deals))
+	for addr := range d.deals {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var messages []*alias.DKGData
+	for _, addr := range addrs {
+		deal := d.deals[addr]
+		resp, err := d.instance.ProcessDeal(deal)
+		if err != nil {
+			// The deal can not be opened or is not ours: it gets no
+			// response, so the share holder does not make it into QUAL.
+			d.logger.Info("reshareDealer: rejecting deal", "from", addr, "error", err)
+			continue
+		}
+		if !resp.Response.Status {
+			d.logger.Info("reshareDealer: complaining about deal", "from", addr)
+			d.complaints[complaintKey(resp.Index, resp.Response.Index)] = true
+		}
+		data, err := wire.EncodePedersenResponse(resp)
+		if err != nil {
+			return fmt.Errorf("failed to encode response: %v", err), true
+		}
+		messages = append(messages, &alias.DKGData{
+			Type:    alias.DKGResponse,
+			RoundID: d.roundID,
+			Addr:    d.addrBytes,
+			Data:    data,
+		})
+	}
+	d.eventFirer.FireEvent(types.EventDKGDealsProcessed, d.roundID)
+
+	if len(messages) > 0 {
+		if err := d.SendMsgCb(messages); err != nil {
+			return fmt.Errorf("failed to send responses: %v", err), true
+		}
+	}
+
+	return nil, true
+}
+
+func (d *reshareDealer) HandleDKGResponse(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
+	resp, err := wire.DecodePedersenResponse(msg.Data)
+	if err == nil && resp.Response == nil {
+		err = errors.New("response is empty")
+	}
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
+	}
+	// The instance already knows our own responses.
+	if msg.GetAddrString() == crypto.Address(d.addrBytes).String() {
+		return nil
+	}
+	d.responses.add(msg.GetAddrString(), int(resp.Index), resp)
+
+	if err := d.Transit(); err != nil {
+		return fmt.Errorf("failed to Transit: %v", err)
+	}
+
+	return nil
+}
+
+func (d *reshareDealer) IsResponsesReady() bool {
+	return d.responses.messagesCount >= d.expectedResponses || d.phaseTimedOut
+}
+
+// ProcessResponses answers the complaints about our own deal with
+// justifications; unlike the fresh DKG, only actual justifications are sent.
+func (d *reshareDealer) ProcessResponses() (error, bool) {
+	if !d.IsResponsesReady() {
+		d.logger.Debug("reshareDealer: process responses: responses are not ready", "have", d.responses.messagesCount, "want", d.expectedResponses)
+		return nil, false
+	}
+	if d.phaseTimedOut {
+		d.instance.SetTimeout()
+	}
+
+	var messages []*alias.DKGData
+	for _, addr := range d.responses.addrs() {
+		for _, response := range d.responses.addrToData[addr] {
+			resp := response.(*dkg.Response)
+			if !resp.Response.Status && int(resp.Index) != d.oldIdx {
+				d.complaints[complaintKey(resp.Index, resp.Response.Index)] = true
+			}
+			justification, err := d.instance.ProcessResponse(resp)
+			if err != nil {
+				// E.g. a response to a deal we have not received before the
+				// timeout; the dealer is left out of QUAL.
+				d.logger.Info("reshareDealer: failed to process response", "from", addr, "dealer", resp.Index, "error", err)
+				continue
+			}
+			if justification == nil {
+				continue
+			}
+			data, err := wire.EncodePedersenJustification(justification)
+			if err != nil {
+				return fmt.Errorf("failed to encode justification: %v", err), true
+			}
+			messages = append(messages, &alias.DKGData{
+				Type:    alias.DKGJustification,
+				RoundID: d.roundID,
+				Addr:    d.addrBytes,
+				Data:    data,
+			})
+		}
+	}
+	d.eventFirer.FireEvent(types.EventDKGResponsesProcessed, d.roundID)
+
+	if len(messages) > 0 {
+		if err := d.SendMsgCb(messages); err != nil {
+			return fmt.Errorf("failed to send justifications: %v", err), true
+		}
+	}
+
+	return nil, true
+}
+
+func (d *reshareDealer) HandleDKGJustification(msg *alias.DKGData) error {
+	if !d.admit(msg) {
+		return nil
+	}
+
+	justification, err := wire.DecodePedersenJustification(d.suiteG2, msg.Data)
+	if err == nil && justification.Justification == nil {
+		err = errors.New("justification is empty")
+	}
+	if err != nil {
+		d.addMalformed(msg, err)
+		return nil
+	}
+	// Our own justifications are processed along with the responses.
+	if msg.GetAddrString() == crypto.Address(d.addrBytes).String() {
+		return nil
+	}
+	d.justifications.add(msg.GetAddrString(), 0, justification)
+	d.justified[complaintKey(justification.Index, justification.Justification.Index)] = true
+
+	if err := d.Transit(); err != nil {
+		return fmt.Errorf("failed to Transit: %v", err)
+	}
+
+	return nil
+}
+
+func (d *reshareDealer) IsJustificationsReady() bool {
+	if d.phaseTimedOut {
+		return true
+	}
+	for key := range d.complaints {
+		if !d.justified[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *reshareDealer) ProcessJustifications() (error, bool) {
+	if d.newIdx < 0 {
+		// Validators leaving the group get no share.
+		return nil, true
+	}
+	if !d.IsJustificationsReady() {
+		d.logger.Debug("reshareDealer: justifications are not ready")
+		return nil, false
+	}
+	if d.phaseTimedOut {
+		d.instance.SetTimeout()
+	}
+
+	for _, addr := range d.justifications.addrs() {
+		for _, just := range d.justifications.addrToData[addr] {
+			if err := d.instance.ProcessJustification(just.(*dkg.Justification)); err != nil {
+				// A bad justification disqualifies the dealer.
+				d.logger.Info("reshareDealer: invalid justification", "from", addr, "error", err)
+			}
+		}
+	}
+	d.eventFirer.FireEvent(types.EventDKGJustificationsProcessed, d.roundID)
+
+	qual := d.instance.QUAL()
+	d.logger.Info("reshareDealer: got the QUAL set", "qual", qual)
+	if len(qual) < d.numDealers {
+		qualSet := make(map[int]bool, len(qual))
+		for _, idx := range qual {
+			qualSet[idx] = true
+		}
+		present := d.pubKeysSenders()
+		for idx, addr := range d.oldAddrs {
+			if !qualSet[idx] && present[addr.String()] && !d.isLoser(addr) {
+				d.addLoser(d.qualProof(addr))
+			}
+		}
+		if d.timeouts.IsZero() {
+			return errors.New("some of share holders failed to reshare"), true
+		}
+	}
+	if !d.instance.ThresholdCertified() {
+		return errors.New("instance is not certified"), true
+	}
+	d.eventFirer.FireEvent(types.EventDKGInstanceCertified, d.roundID)
+	d.finished = true
+
+	return nil, true
+}
+
+// GetVerifier returns the verifier holding the new share of the group key.
+// Validators leaving the group never get one.
+func (d *reshareDealer) GetVerifier() (types.Verifier, error) {
+	if !d.finished {
+		return nil, types.ErrDKGVerifierNotReady
+	}
+
+	distKeyShare, err := d.instance.DistKeyShare()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DistKeyShare: %v", err)
+	}
+	groupKey := d.masterPubKey.Commit()
+	if d.renew != nil {
+		if distKeyShare, err = d.renew.Renew(d.suiteG2, distKeyShare); err != nil {
+			return nil, fmt.Errorf("failed to refresh share: %v", err)
+		}
+		groupKey = d.renew.Public()
+	}
+	if !distKeyShare.Public().Equal(groupKey) {
+		return nil, errors.New("reshared group key differs from the original one")
+	}
+
+	var (
+		masterPubKey = share.NewPubPoly(d.suiteG2, nil, distKeyShare.Commitments())
+		newShare     = &blsShare.BLSShare{
+			ID:   d.newIdx,
+			Pub:  &share.PubShare{I: d.newIdx, V: d.pubKey},
+			Priv: distKeyShare.PriShare(),
+		}
+		t, n = d.threshold(), len(d.newAddrs)
+	)
+	if err := blsShare.CheckThreshold(masterPubKey, t, n); err != nil {
+		return nil, err
+	}
+
+	holders := make([]string, len(d.newAddrs))
+	for i, addr := range d.newAddrs {
+		holders[i] = addr.String()
+	}
+	verifier := blsShare.NewSuiteBLSVerifier(d.suite, masterPubKey, []*blsShare.BLSShare{newShare}, t, n)
+	verifier.SetHolders(holders)
+	return verifier, nil
+}
+
+// zeroKey returns the key the validators reshare during a refresh: the zero
+// polynomial of the group's threshold and the node's share of it. It is built
+// on start, after the suite of the dealer is set.
+func (d *reshareDealer) zeroKey() (*share.PubPoly, *share.PriShare) {
+	zeros := make([]kyber.Point, len(d.renew.Commits))
+	for i := range zeros {
+		zeros[i] = d.suiteG2.Point().Null()
+	}
+
+	return share.NewPubPoly(d.suiteG2, nil, zeros), &share.PriShare{I: d.renew.Share.I, V: d.suiteG2.Scalar().Zero()}
+}
+
+func complaintKey(dealer, verifier uint32) string {
+	return fmt.Sprintf("%d/%d", dealer, verifier)
+}
+
+// unionValidators returns the validators present in either of the sets.
+func unionValidators(a, b *tmtypes.ValidatorSet) *tmtypes.ValidatorSet {
+	var (
+		out  []*tmtypes.Validator
+		seen = make(map[string]bool)
+	)
+	for _, set := range []*tmtypes.ValidatorSet{a, b} {
+		for _, validator := range set.Validators {
+			if seen[validator.Address.String()] {
+				continue
+			}
+			seen[validator.Address.String()] = true
+			out = append(out, validator.Copy())
+		}
+	}
+	return tmtypes.NewValidatorSet(out)
+}
+
+// sortedAddrs returns the addresses of the validators in the order DKG
+// indexes them.
+func sortedAddrs(validators *tmtypes.ValidatorSet) []crypto.Address {
+	out := make([]crypto.Address, 0, validators.Size())
+	for _, validator := range validators.Validators {
+		out = append(out, validator.Address)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+func indexOf(list []crypto.Address, s string) int {
+	for i, item := range list {
+		if item.String() == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,426 @@
+package dealer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/tmhash"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// VirtualValidators is a validator set in which every validator is replaced
+// with a number of virtual validators proportional to its voting power. Each
+// virtual validator holds one DKG share, so thresholds computed over the
+// virtual set are thresholds over (quantized) voting power.
+type VirtualValidators struct {
+	*tmtypes.ValidatorSet
+
+	// owners maps a virtual address to the real validator.
+	owners map[string]*tmtypes.Validator
+}
+
+// NewVirtualValidators splits maxShares virtual validators among the
+// validators by largest remainder (Hamilton) apportionment: every validator
+// first gets the integer part of its quota maxShares*power/total, and the
+// shares left go to the largest fractional parts, ties broken by the order of
+// the validators.
+//
+// Every validator gets its quota rounded either down or up, so the number of
+// shares of any k validators is less than k away from their quota: their
+// fraction of the shares is within k/maxShares of their fraction of the voting
+// power. Validators with a quota below one may get no share at all.
+func NewVirtualValidators(validators *tmtypes.ValidatorSet, maxShares int) (*VirtualValidators, error) {
+	if maxShares <= 0 {
+		return nil, fmt.Errorf("invalid number of shares %d", maxShares)
+	}
+	total := validators.TotalVotingPower()
+	if total <= 0 {
+		return nil, fmt.Errorf("validator set has no voting power")
+	}
+
+	var (
+		numShares  = make([]int, len(validators.Validators))
+		remainders = make([]*big.Int, len(validators.Validators))
+		left       = maxShares
+	)
+	for i, validator := range validators.Validators {
+		// The products may not fit in an int64.
+		quota, remainder := new(big.Int).QuoRem(
+			new(big.Int).Mul(big.NewInt(validator.VotingPower), big.NewInt(int64(maxShares))),
+			big.NewInt(total),
+			new(big.Int),
+		)
+		numShares[i], remainders[i] = int(quota.Int64()), remainder
+		left -= numShares[i]
+	}
+	order := make([]int, len(validators.Validators))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	for _, i := range order[:left] {
+		numShares[i]++
+	}
+
+	var (
+		virtual []*tmtypes.Validator
+		owners  = make(map[string]*tmtypes.Validator)
+	)
+	for i, validator := range validators.Validators {
+		for k := 0; k < numShares[i]; k++ {
+			addr := VirtualAddress(validator.Address, k)
+			virtual = append(virtual, &tmtypes.Validator{
+				Address:     addr,
+				PubKey:      validator.PubKey,
+				VotingPower: 1,
+			})
+			owners[addr.String()] = validator
+		}
+	}
+
+	return &VirtualValidators{
+		ValidatorSet: tmtypes.NewValidatorSet(virtual),
+		owners:       owners,
+	}, nil
+}
+
+// VirtualAddress is the address of the k-th virtual validator of addr.
+func VirtualAddress(addr crypto.Address, k int) crypto.Address {
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], uint32(k))
+	return crypto.Address(tmhash.SumTruncated(append(append([]byte{}, addr...), idx[:]...)))
+}
+
+// Owner returns the real validator behind the virtual address.
+func (vv *VirtualValidators) Owner(addr crypto.Address) *tmtypes.Validator {
+	return vv.owners[addr.String()]
+}
+
+// Shares returns the virtual addresses of the real validator.
+func (vv *VirtualValidators) Shares(addr crypto.Address) []crypto.Address {
+	var out []crypto.Address
+	for _, validator := range vv.Validators {
+		if owner := vv.owners[validator.Address.String()]; owner != nil && owner.Address.String() == addr.String() {
+			out = append(out, validator.Address)
+		}
+	}
+	return out
+}
+
+// virtualPV signs on behalf of a virtual validator with the real key.
+type virtualPV struct {
+	tmtypes.PrivValidator
+	addr crypto.Address
+}
+
+func (pv *virtualPV) GetPubKey() crypto.PubKey {
+	return &virtualPubKey{PubKey: pv.PrivValidator.GetPubKey(), addr: pv.addr}
+}
+
+type virtualPubKey struct {
+	crypto.PubKey
+	addr crypto.Address
+}
+
+func (pk *virtualPubKey) Address() crypto.Address {
+	return pk.addr
+}
+
+// WeightedDealer runs one dealer per virtual validator owned by this node.
+// All the dealers take part in the same round and see every message; the
+// verifier produced holds the shares of all of them.
+type WeightedDealer struct {
+	Dealer // The first of the dealers, used for the read-only methods.
+
+	dealers    []Dealer
+	validators *VirtualValidators
+	roundID    int
+	logger     log.Logger
+
+	store DealerStore
+	seed  []byte
+
+	// err is the error the virtual validators failed with; Start returns it.
+	err error
+}
+
+var _ Dealer = &WeightedDealer{}
+
+// NewWeightedDealerConstructor returns a constructor of weighted dealers that
+// builds the per-share dealers with newDealer.
+func NewWeightedDealerConstructor(newDealer DKGDealerConstructor, maxShares int) DKGDealerConstructor {
+	return func(validators *tmtypes.ValidatorSet, pv tmtypes.PrivValidator, sendMsgCb func([]*alias.DKGData) error, eventFirer events.Fireable, logger log.Logger, startRound int) Dealer {
+		virtual, err := NewVirtualValidators(validators, maxShares)
+		if err != nil {
+			// The constructor can not fail; the round does, once started.
+			return &WeightedDealer{roundID: startRound, logger: logger, err: fmt.Errorf("failed to build virtual validators: %v", err)}
+		}
+
+		d := &WeightedDealer{validators: virtual, roundID: startRound, logger: logger}
+		for _, addr := range virtual.Shares(pv.GetPubKey().Address()) {
+			d.dealers = append(d.dealers, newDealer(virtual.ValidatorSet, &virtualPV{PrivValidator: pv, addr: addr}, sendMsgCb, eventFirer, logger, startRound))
+		}
+		if len(d.dealers) == 0 {
+			// Not a validator, or one with no share; the dealer will fail to
+			// verify messages just like an unweighted one would.
+			d.dealers = append(d.dealers, newDealer(virtual.ValidatorSet, pv, sendMsgCb, eventFirer, logger, startRound))
+		}
+		d.Dealer = d.dealers[0]
+
+		return d
+	}
+}
+
+// Start starts every dealer with its own seed derived from the weighted
+// dealer's seed, so that only one seed needs to be stored.
+func (d *WeightedDealer) Start() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.seed == nil {
+		d.seed = make([]byte, seedSize)
+		if _, err := rand.Read(d.seed); err != nil {
+			return fmt.Errorf("failed to generate seed: %v", err)
+		}
+	}
+	if d.store != nil {
+		if err := d.store.SaveSeed(d.roundID, d.seed); err != nil {
+			return fmt.Errorf("failed to save seed: %v", err)
+		}
+	}
+
+	for k, dealer := range d.dealers {
+		var idx [4]byte
+		binary.BigEndian.PutUint32(idx[:], uint32(k))
+		seed := sha256.Sum256(append(append([]byte{}, d.seed...), idx[:]...))
+		dealer.SetSeed(seed[:])
+		if err := dealer.Start(); err != nil {
+			return fmt.Errorf("failed to start dealer #%d: %v", k, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *WeightedDealer) Transit() error {
+	return d.each(func(dealer Dealer) error { return dealer.Transit() })
+}
+
+func (d *WeightedDealer) GenerateTransitions() {
+	for _, dealer := range d.dealers {
+		dealer.GenerateTransitions()
+	}
+}
+
+// Owner returns the real validator behind the virtual address.
+func (d *WeightedDealer) Owner(addr crypto.Address) *tmtypes.Validator {
+	return d.validators.Owner(addr)
+}
+
+// GetLosers returns the real validators behind the virtual losers of all the
+// dealers.
+func (d *WeightedDealer) GetLosers() []*tmtypes.Validator {
+	return d.owners(func(dealer Dealer) []*tmtypes.Validator { return dealer.GetLosers() })
+}
+
+func (d *WeightedDealer) PopLosers() []*tmtypes.Validator {
+	return d.owners(func(dealer Dealer) []*tmtypes.Validator { return dealer.PopLosers() })
+}
+
+func (d *WeightedDealer) owners(losers func(Dealer) []*tmtypes.Validator) []*tmtypes.Validator {
+	var (
+		out  []*tmtypes.Validator
+		seen = make(map[string]bool)
+	)
+	for _, dealer := range d.dealers {
+		for _, loser := range losers(dealer) {
+			if loser == nil {
+				continue
+			}
+			owner := d.validators.Owner(loser.Address)
+			if owner == nil || seen[owner.Address.String()] {
+				continue
+			}
+			seen[owner.Address.String()] = true
+			out = append(out, owner)
+		}
+	}
+	return out
+}
+
+// GetLoserProofs returns the proofs collected by all the dealers, one per
+// virtual loser. The proofs refer to virtual addresses and participant
+// indexes, so they only verify against the virtual validators; see
+// VerifyLoserProof.
+func (d *WeightedDealer) GetLoserProofs() []*types.LoserProof {
+	return d.proofs(func(dealer Dealer) []*types.LoserProof { return dealer.GetLoserProofs() })
+}
+
+// VerifyLoserProof verifies a proof returned by GetLoserProofs against the
+// virtual validators and returns the real validator it incriminates.
+func (d *WeightedDealer) VerifyLoserProof(suite *blsShare.Suite, proof *types.LoserProof) (*tmtypes.Validator, error) {
+	if err := types.VerifyLoserProof(suite, d.validators.ValidatorSet, proof); err != nil {
+		return nil, err
+	}
+	owner := d.validators.Owner(proof.Addr)
+	if owner == nil {
+		return nil, fmt.Errorf("no owner of virtual validator %s", proof.Addr)
+	}
+	return owner, nil
+}
+
+func (d *WeightedDealer) proofs(proofs func(Dealer) []*types.LoserProof) []*types.LoserProof {
+	var (
+		out  []*types.LoserProof
+		seen = make(map[string]bool)
+	)
+	for _, dealer := range d.dealers {
+		for _, proof := range proofs(dealer) {
+			if seen[proof.Addr.String()] {
+				continue
+			}
+			seen[proof.Addr.String()] = true
+			out = append(out, proof)
+		}
+	}
+	return out
+}
+
+func (d *WeightedDealer) SetPhaseTimeouts(timeouts PhaseTimeouts) {
+	for _, dealer := range d.dealers {
+		dealer.SetPhaseTimeouts(timeouts)
+	}
+}
+
+func (d *WeightedDealer) SetThresholdPolicy(policy blsShare.ThresholdPolicy) {
+	for _, dealer := range d.dealers {
+		dealer.SetThresholdPolicy(policy)
+	}
+}
+
+func (d *WeightedDealer) SetSuite(suite *blsShare.Suite) {
+	for _, dealer := range d.dealers {
+		dealer.SetSuite(suite)
+	}
+}
+
+func (d *WeightedDealer) NewBlock(height int64) error {
+	return d.each(func(dealer Dealer) error { return dealer.NewBlock(height) })
+}
+
+// SetStore makes the weighted dealer checkpoint the round. The dealers share
+// the seed and the messages, so they only store their own timeouts.
+func (d *WeightedDealer) SetStore(store DealerStore) {
+	d.store = store
+	for k, dealer := range d.dealers {
+		if store == nil {
+			dealer.SetStore(nil)
+			continue
+		}
+		dealer.SetStore(&shareStore{DealerStore: store, share: k})
+	}
+}
+
+func (d *WeightedDealer) ReplayTimeout(timeout TimeoutEvent) error {
+	if timeout.Share < 0 || timeout.Share >= len(d.dealers) {
+		return fmt.Errorf("no dealer #%d", timeout.Share)
+	}
+	return d.dealers[timeout.Share].ReplayTimeout(timeout)
+}
+
+func (d *WeightedDealer) SetSeed(seed []byte) {
+	d.seed = seed
+}
+
+func (d *WeightedDealer) HandleDKGPubKey(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGPubKey(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGDeal(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGDeal(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGResponse(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGResponse(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGJustification(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGJustification(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGCommit(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGCommit(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGComplaint(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGComplaint(msg) })
+}
+
+func (d *WeightedDealer) HandleDKGReconstructCommit(msg *alias.DKGData) error {
+	return d.handle(msg, func(dealer Dealer) error { return dealer.HandleDKGReconstructCommit(msg) })
+}
+
+func (d *WeightedDealer) handle(msg *alias.DKGData, handler func(Dealer) error) error {
+	if d.store != nil {
+		if err := d.store.AddMessage(d.roundID, msg); err != nil {
+			d.logger.Error("WeightedDealer: failed to persist message", "round", d.roundID, "type", msg.Type, "error", err)
+		}
+	}
+	return d.each(handler)
+}
+
+// shareStore is the store of one of the dealers of a weighted dealer: it
+// tags the timeouts with the dealer's index and leaves the rest to the
+// weighted dealer.
+type shareStore struct {
+	DealerStore
+	share int
+}
+
+func (s *shareStore) SaveSeed(int, []byte) error           { return nil }
+func (s *shareStore) AddMessage(int, *alias.DKGData) error { return nil }
+func (s *shareStore) AddTimeout(roundID int, timeout TimeoutEvent) error {
+	timeout.Share = s.share
+	return s.DealerStore.AddTimeout(roundID, timeout)
+}
+
+func (d *WeightedDealer) each(f func(Dealer) error) error {
+	for k, dealer := range d.dealers {
+		if err := f(dealer); err != nil {
+			return fmt.Errorf("dealer #%d: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// GetVerifier returns a verifier holding the shares of all the dealers; it is
+// ready once every dealer has finished.
+func (d *WeightedDealer) GetVerifier() (types.Verifier, error) {
+	var verifiers []*blsShare.BLSVerifier
+	for _, dealer := range d.dealers {
+		verifier, err := dealer.GetVerifier()
+		if err != nil {
+			return nil, err
+		}
+		blsVerifier, ok := verifier.(*blsShare.BLSVerifier)
+		if !ok {
+			// Mock dealers produce mock verifiers; there is nothing to combine.
+			return verifier, nil
+		}
+		verifiers = append(verifiers, blsVerifier)
+	}
+
+	return blsShare.CombineBLSVerifiers(verifiers)
+}
@@ -0,0 +1,129 @@
+package dealer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/types"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+func newWeightedSet(powers ...int64) *tmtypes.ValidatorSet {
+	var validators []*tmtypes.Validator
+	for _, power := range powers {
+		validators = append(validators, tm.NewValidator(tm.NewMockPV().GetPubKey(), power))
+	}
+	return tmtypes.NewValidatorSet(validators)
+}
+
+func TestVirtualValidators(t *testing.T) {
+	for _, tc := range []struct {
+		powers    []int64
+		maxShares int
+	}{
+		{[]int64{1, 1, 1, 97}, 10},
+		{[]int64{1, 1, 1, 1}, 10},
+		{[]int64{5, 3, 2}, 7},
+		{[]int64{1 << 58, 3, 1 << 57}, 1000},
+		{[]int64{17, 29, 31, 37, 41, 43, 47}, 100},
+	} {
+		validators := newWeightedSet(tc.powers...)
+		virtual, err := NewVirtualValidators(validators, tc.maxShares)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if virtual.Size() != tc.maxShares {
+			t.Fatalf("%v: %d shares, want %d", tc.powers, virtual.Size(), tc.maxShares)
+		}
+		total := big.NewInt(validators.TotalVotingPower())
+		for _, validator := range validators.Validators {
+			shares := virtual.Shares(validator.Address)
+			for _, addr := range shares {
+				if owner := virtual.Owner(addr); owner == nil || owner.Address.String() != validator.Address.String() {
+					t.Fatalf("%v: share %s has another owner", tc.powers, addr)
+				}
+			}
+			// |shares - maxShares*power/total| < 1
+			diff := new(big.Int).Mul(big.NewInt(int64(len(shares))), total)
+			diff.Sub(diff, new(big.Int).Mul(big.NewInt(validator.VotingPower), big.NewInt(int64(tc.maxShares))))
+			if diff.Abs(diff).Cmp(total) >= 0 {
+				t.Fatalf("%v: power %d got %d shares", tc.powers, validator.VotingPower, len(shares))
+			}
+		}
+	}
+
+	// The remaining share goes to the largest remainder, not to everybody.
+	validators := newWeightedSet(1, 1, 1, 97)
+	virtual, err := NewVirtualValidators(validators, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, validator := range validators.Validators {
+		want := 0
+		if validator.VotingPower == 97 {
+			want = 10
+		}
+		if got := len(virtual.Shares(validator.Address)); got != want {
+			t.Fatalf("power %d got %d shares, want %d", validator.VotingPower, got, want)
+		}
+	}
+
+	if _, err := NewVirtualValidators(validators, 0); err == nil {
+		t.Fatal("no error for zero shares")
+	}
+}
+
+// A weighted dealer that can not split the validators fails the round
+// instead of running an unweighted one.
+func TestWeightedDealerError(t *testing.T) {
+	pv := tm.NewMockPV()
+	validators := tmtypes.NewValidatorSet([]*tmtypes.Validator{tm.NewValidator(pv.GetPubKey(), 1)})
+	d := NewWeightedDealerConstructor(NewDKGDealer, 0)(validators, pv, func([]*alias.DKGData) error { return nil },
+		events.NewEventSwitch(), log.NewNopLogger(), 0)
+	if err := d.Start(); err == nil {
+		t.Fatal("the dealer started")
+	}
+}
+
+func TestWeightedLoserProof(t *testing.T) {
+	const maxShares = 8
+	r := newTestRound(t, 4, NewWeightedDealerConstructor(NewDKGDealer, maxShares))
+	r.replace(3, NewWeightedDealerConstructor(NewByzantineDealerConstructor(NewDKGDealer, ByzantineRule{
+		Action: ByzantineCorrupt,
+		Types:  []alias.DKGDataType{alias.DKGResponse},
+		Limit:  1,
+		Corrupt: func(_ *blsShare.Suite, msg *alias.DKGData) error {
+			msg.Data = []byte("garbage")
+			return nil
+		},
+	}), maxShares))
+	r.start()
+	d := r.dealers[0].(*WeightedDealer)
+	r.run(10, func() bool { return len(d.GetLoserProofs()) > 0 })
+
+	proofs := d.GetLoserProofs()
+	if len(proofs) == 0 {
+		t.Fatal("no loser proofs")
+	}
+	for _, proof := range proofs {
+		if proof.Reason != types.LoserMalformedMessage {
+			t.Fatalf("unexpected reason %d", proof.Reason)
+		}
+		// The proofs name virtual validators.
+		if err := types.VerifyLoserProof(blsShare.BN256, r.validators, proof); err == nil {
+			t.Fatal("a proof about a virtual validator verifies against the real ones")
+		}
+		owner, err := d.VerifyLoserProof(blsShare.BN256, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner.Address.String() != r.pvs[3].GetPubKey().Address().String() {
+			t.Fatalf("the proof incriminates %s", owner.Address)
+		}
+	}
+}
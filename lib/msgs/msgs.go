@@ -39,8 +39,11 @@ func (msg MsgSendDKGData) ValidateBasic() error {
 	if msg.Owner.Empty() {
 		return fmt.Errorf("data validation failed: empty owner")
 	}
+	if msg.Data == nil {
+		return fmt.Errorf("data validation failed: %w", alias.ErrEmptyData)
+	}
 	if err := msg.Data.ValidateBasic(); err != nil {
-		return fmt.Errorf("data validation failed: %v", err)
+		return fmt.Errorf("data validation failed: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,96 @@
+package offChain
+
+import (
+	dkgalias "github.com/corestario/dkglib/lib/alias"
+	dkglib "github.com/corestario/dkglib/lib/dealer"
+	dkgtypes "github.com/corestario/dkglib/lib/types"
+	"github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+)
+
+// Reasons for rejecting a message, as reported in Metrics.RejectedMessages.
+const (
+	RejectRetiredRound   = "retired_round"
+	RejectFutureRound    = "future_round"
+	RejectInvalidMessage = "invalid_message"
+	RejectRateLimited    = "rate_limited"
+)
+
+// AdmissionPolicy limits the rounds other validators can make the node open
+// before the node has started them itself (with CheckDKGTime or
+// StartDKGRound). Opening a round costs a key pair and a broadcast, so a
+// validator must not be able to make the others open rounds at will.
+type AdmissionPolicy struct {
+	// LookAhead is how far above the latest round started by the node a
+	// round may be opened.
+	LookAhead int
+	// MaxOpenedRounds is the number of rounds a validator may open per
+	// RateWindow blocks.
+	MaxOpenedRounds int
+	// RateWindow is counted in blocks; the DKG interval if zero.
+	RateWindow int64
+}
+
+// DefaultAdmissionPolicy accepts the rounds started by the others slightly
+// before the node, including a retry.
+var DefaultAdmissionPolicy = AdmissionPolicy{LookAhead: 1, MaxOpenedRounds: 2}
+
+// admitRound returns the dealer of a round not seen yet, which the message
+// opens, or the reason for rejecting the message. The message is verified
+// and the sender checked before the dealer is started.
+func (m *OffChainDKG) admitRound(dkgMsg *dkgtypes.DKGDataMessage, height int64, validators *alias.ValidatorSet) (dkglib.Dealer, string) {
+	msg := dkgMsg.Data
+	if msg.RoundID > m.dkgRoundID+m.admissionPolicy.LookAhead {
+		return nil, RejectFutureRound
+	}
+
+	// The next round may be scheduled as another kind; the ones after it
+	// are regular.
+	newDealer, kind := m.newDKGDealer, roundKindDKG
+	if msg.RoundID == m.dkgRoundID+1 {
+		newDealer, kind, validators = m.nextRound(validators)
+	}
+	dealer := m.newDealer(newDealer, validators, msg.RoundID)
+	if err := dealer.VerifyMessage(*dkgMsg); err != nil {
+		m.Logger.Debug("dkgState: can't verify message opening a round", "round", msg.RoundID, "error", err)
+		return nil, RejectInvalidMessage
+	}
+
+	window := m.admissionPolicy.RateWindow
+	if window <= 0 {
+		window = m.dkgNumBlocks
+	}
+	sender := roundOpener(dealer, msg)
+	var recent []int64
+	for _, h := range m.openedRounds[sender] {
+		if h > height-window {
+			recent = append(recent, h)
+		}
+	}
+	if len(recent) >= m.admissionPolicy.MaxOpenedRounds {
+		m.openedRounds[sender] = recent
+		return nil, RejectRateLimited
+	}
+	m.openedRounds[sender] = append(recent, height)
+	m.metrics.OpenedRounds.Add(1)
+	m.roundKinds[msg.RoundID] = kind
+
+	return dealer, ""
+}
+
+// roundOpener returns the validator the rounds opened by the message are
+// counted against. With weighted shares the message comes from a virtual
+// validator, and all of them count against the validator that owns them.
+func roundOpener(dealer dkglib.Dealer, msg *dkgalias.DKGData) string {
+	if weighted, ok := dealer.(*dkglib.WeightedDealer); ok {
+		if owner := weighted.Owner(crypto.Address(msg.Addr)); owner != nil {
+			return owner.Address.String()
+		}
+	}
+	return msg.GetAddrString()
+}
+
+func (m *OffChainDKG) reject(msg *dkgalias.DKGData, reason string) {
+	m.Logger.Debug("dkgState: rejecting message", "reason", reason, "round", msg.RoundID, "from", msg.GetAddrString())
+	m.metrics.RejectedMessages.With("reason", reason).Add(1)
+}
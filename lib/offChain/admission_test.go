@@ -0,0 +1,181 @@
+package offChain
+
+import (
+	"fmt"
+	"testing"
+
+	dkgalias "github.com/corestario/dkglib/lib/alias"
+	dkglib "github.com/corestario/dkglib/lib/dealer"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+// open makes node i start rounds up to roundID and returns the first message
+// it sends for each, by round.
+func (net *testNet) open(i, roundID int) map[int]*dkgalias.DKGData {
+	out := make(map[int]*dkgalias.DKGData)
+	for id := net.nodes[i].dkgRoundID + 1; id <= roundID; id++ {
+		out[id] = net.start(i)[0]
+	}
+	return out
+}
+
+func TestAdmitRound(t *testing.T) {
+	net := newTestNet(t, 2)
+	opening := net.open(1, 2)
+
+	net.handle(0, opening[2])
+	if _, ok := net.nodes[0].dkgRoundToDealer[2]; ok {
+		t.Fatal("round 2 has been opened ahead of round 1")
+	}
+
+	bad := *opening[1]
+	bad.Signature = append([]byte(nil), bad.Signature...)
+	bad.Signature[0] ^= 1
+	net.handle(0, &bad)
+
+	outsider := tm.NewMockPV()
+	forged := *opening[1]
+	forged.Addr = outsider.GetPubKey().Address()
+	if err := outsider.SignData("test", &forged); err != nil {
+		t.Fatal(err)
+	}
+	net.handle(0, &forged)
+	if _, ok := net.nodes[0].dkgRoundToDealer[1]; ok {
+		t.Fatal("round 1 has been opened by an invalid message")
+	}
+
+	net.handle(0, opening[1])
+	if net.nodes[0].dkgRoundToDealer[1] == nil {
+		t.Fatal("round 1 has not been opened")
+	}
+	if kind := net.nodes[0].roundKinds[1]; kind != roundKindDKG {
+		t.Fatalf("round 1 opened as %q", kind)
+	}
+	net.noErrors()
+
+	m := net.metrics[0]
+	for reason, want := range map[string]float64{
+		RejectFutureRound:    1,
+		RejectInvalidMessage: 2,
+		RejectRateLimited:    0,
+	} {
+		if got := m.count("rejected_messages", "reason", reason); got != want {
+			t.Errorf("%v %s rejections, want %v", got, reason, want)
+		}
+	}
+	if got := m.count("opened_rounds"); got != 1 {
+		t.Errorf("%v opened rounds, want 1", got)
+	}
+}
+
+func TestAdmitRoundRateLimit(t *testing.T) {
+	net := newTestNet(t, 3, WithAdmissionPolicy(AdmissionPolicy{LookAhead: 10, MaxOpenedRounds: 2, RateWindow: 5}))
+	opening := net.open(1, 4)
+	other := net.open(2, 3)
+
+	net.height = 1
+	net.handle(0, opening[1])
+	net.handle(0, opening[2])
+	net.handle(0, opening[3])
+	if _, ok := net.nodes[0].dkgRoundToDealer[3]; ok {
+		t.Fatal("node 1 opened a third round within the window")
+	}
+	// The limit is per validator.
+	net.handle(0, other[3])
+	if net.nodes[0].dkgRoundToDealer[3] == nil {
+		t.Fatal("node 2 could not open round 3")
+	}
+
+	// The rounds opened at height 1 are out of the window at height 6.
+	net.height = 5
+	net.handle(0, opening[4])
+	if _, ok := net.nodes[0].dkgRoundToDealer[4]; ok {
+		t.Fatal("node 1 opened a third round within the window")
+	}
+	net.height = 6
+	net.handle(0, opening[4])
+	if net.nodes[0].dkgRoundToDealer[4] == nil {
+		t.Fatal("node 1 could not open round 4 once the window passed")
+	}
+	net.noErrors()
+
+	m := net.metrics[0]
+	if got := m.count("rejected_messages", "reason", RejectRateLimited); got != 2 {
+		t.Errorf("%v rate limited rejections, want 2", got)
+	}
+	if got := m.count("opened_rounds"); got != 4 {
+		t.Errorf("%v opened rounds, want 4", got)
+	}
+}
+
+// The virtual validators of a weighted validator share its limit.
+func TestAdmitRoundWeighted(t *testing.T) {
+	net := newTestNet(t, 2,
+		WithWeightedShares(8),
+		WithAdmissionPolicy(AdmissionPolicy{LookAhead: 10, MaxOpenedRounds: 2, RateWindow: 5}),
+	)
+	var opening []*dkgalias.DKGData
+	for roundID := 1; roundID <= 3; roundID++ {
+		messages := net.start(1)
+		// Open each round with the message of another virtual validator.
+		opening = append(opening, messages[(roundID-1)*len(messages)/4])
+	}
+	owner := string(net.pvs[1].GetPubKey().Address())
+	for i, msg := range opening {
+		if string(msg.Addr) == owner {
+			t.Fatal("the message is sent by the validator itself")
+		}
+		for _, prev := range opening[:i] {
+			if string(msg.Addr) == string(prev.Addr) {
+				t.Fatal("the messages are sent by the same virtual validator")
+			}
+		}
+	}
+
+	for _, msg := range opening {
+		net.handle(0, msg)
+	}
+	if _, ok := net.nodes[0].dkgRoundToDealer[3]; ok {
+		t.Fatal("a third round has been opened within the window")
+	}
+	if _, ok := net.nodes[0].dkgRoundToDealer[2].(*dkglib.WeightedDealer); !ok {
+		t.Fatal("round 2 is not weighted")
+	}
+	net.noErrors()
+}
+
+// prometheusRuns keeps the metrics of repeated runs apart: they are
+// registered with the default registry, once per namespace.
+var prometheusRuns int
+
+func TestPrometheusMetrics(t *testing.T) {
+	prometheusRuns++
+	namespace := fmt.Sprintf("test%d", prometheusRuns)
+	m := PrometheusMetrics(namespace, "chain_id", "test-chain")
+	m.RejectedMessages.With("reason", RejectFutureRound).Add(1)
+	m.OpenedRounds.Add(2)
+
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range metric.GetLabel() {
+				name += "," + label.GetName() + "=" + label.GetValue()
+			}
+			values[name] = metric.GetCounter().GetValue()
+		}
+	}
+	for name, want := range map[string]float64{
+		namespace + "_dkg_rejected_messages,chain_id=test-chain,reason=future_round": 1,
+		namespace + "_dkg_opened_rounds,chain_id=test-chain":                         2,
+	} {
+		if got, ok := values[name]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
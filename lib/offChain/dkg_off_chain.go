@@ -1,7 +1,9 @@
 package offChain
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -14,11 +16,13 @@ import (
 	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/libs/events"
 	"github.com/tendermint/tendermint/libs/log"
+	"go.dedis.ch/kyber/v3/share"
 )
 
 const (
-	BlocksAhead         = 20  // Agree to swap verifier after around this number of blocks.
-	DefaultDKGNumBlocks = 100 //DefaultDKGNumBlocks sets how often node should make DKG(in blocks)
+	BlocksAhead           = 20  // Agree to swap verifier after around this number of blocks.
+	DefaultDKGNumBlocks   = 100 //DefaultDKGNumBlocks sets how often node should make DKG(in blocks)
+	DefaultRoundRetention = 2   // Number of latest rounds kept after they are over.
 )
 
 type OffChainDKG struct {
@@ -30,11 +34,30 @@ type OffChainDKG struct {
 
 	dkgMsgQueue      chan *dkgtypes.DKGDataMessage // message queue used for dkgState-related messages.
 	dkgRoundToDealer map[int]dkglib.Dealer
+	roundKinds       map[int]string // Kind of the dealer of each round, see nextRound.
 	dkgRoundID       int
+	roundRetention   int
+	retiredBelow     int // Rounds with lower IDs are retired, their messages are dropped.
 	dkgNumBlocks     int64
 	newDKGDealer     dkglib.DKGDealerConstructor
+	scheduled        *scheduledRound // Kind of the next round, if not a regular one.
+	phaseTimeouts    dkglib.PhaseTimeouts
+	dealerStore      dkglib.DealerStore
+	keystore         *blsShare.Keystore
+	weightedShares   int
+	thresholdPolicy  blsShare.ThresholdPolicy
+	pairingSuite     *blsShare.Suite
+	dealerSeed       []byte
 	privValidator    alias.PrivValidator
 
+	retryPolicy RetryPolicy
+	failures    int   // Failed rounds since the latest successful one.
+	retryHeight int64 // Height to start the next retry at; zero if none.
+
+	admissionPolicy AdmissionPolicy
+	openedRounds    map[string][]int64 // Heights of the rounds opened by each validator.
+	metrics         *Metrics
+
 	Logger  log.Logger
 	evsw    events.EventSwitch
 	chainID string
@@ -47,8 +70,16 @@ func NewOffChainDKG(evsw events.EventSwitch, chainID string, options ...DKGOptio
 		evsw:             evsw,
 		dkgMsgQueue:      make(chan *dkgtypes.DKGDataMessage, alias.MsgQueueSize),
 		dkgRoundToDealer: make(map[int]dkglib.Dealer),
+		roundKinds:       make(map[int]string),
 		newDKGDealer:     dkglib.NewDKGDealer,
 		dkgNumBlocks:     DefaultDKGNumBlocks,
+		roundRetention:   DefaultRoundRetention,
+		thresholdPolicy:  blsShare.DefaultThresholdPolicy,
+		pairingSuite:     blsShare.BN256,
+		retryPolicy:      DefaultRetryPolicy,
+		admissionPolicy:  DefaultAdmissionPolicy,
+		openedRounds:     make(map[string][]int64),
+		metrics:          NopMetrics(),
 		chainID:          chainID,
 	}
 
@@ -59,10 +90,43 @@ func NewOffChainDKG(evsw events.EventSwitch, chainID string, options ...DKGOptio
 	if dkg.dkgNumBlocks == 0 {
 		dkg.dkgNumBlocks = DefaultDKGNumBlocks // We do not want to panic if the value is not provided.
 	}
+	if dkg.roundRetention < 1 {
+		dkg.roundRetention = 1 // The current round is always kept.
+	}
+
+	if dkg.weightedShares > 0 {
+		dkg.newDKGDealer = dkglib.NewWeightedDealerConstructor(dkg.newDKGDealer, dkg.weightedShares)
+	}
+
+	if dkg.keystore != nil {
+		dkg.loadVerifiers()
+	}
 
 	return dkg
 }
 
+// loadVerifiers restores the verifiers produced before a restart. The latest
+// one is scheduled to become active at its activation height (or at the next
+// CheckDKGTime call, if that height has already passed), the one before it is
+// used until then.
+func (m *OffChainDKG) loadVerifiers() {
+	records, err := m.keystore.Latest(2)
+	if err != nil {
+		m.Logger.Error("dkgState: failed to load verifiers from keystore", "error", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+	m.nextVerifier = records[0].Verifier
+	m.changeHeight = records[0].ActivationHeight
+	m.dkgRoundID = records[0].RoundID
+	if len(records) > 1 && m.verifier == nil {
+		m.verifier = records[1].Verifier
+	}
+	m.Logger.Info("dkgState: loaded verifier from keystore", "round", m.dkgRoundID, "change_height", m.changeHeight)
+}
+
 // DKGOption sets an optional parameter on the dkgState.
 type DKGOption func(*OffChainDKG)
 
@@ -82,6 +146,72 @@ func WithPVKey(pv alias.PrivValidator) DKGOption {
 	return func(d *OffChainDKG) { d.privValidator = pv }
 }
 
+// WithPhaseTimeouts makes dealers stop waiting for the messages of a phase
+// after the given number of blocks or amount of time.
+func WithPhaseTimeouts(timeouts dkglib.PhaseTimeouts) DKGOption {
+	return func(d *OffChainDKG) { d.phaseTimeouts = timeouts }
+}
+
+// WithDealerStore makes dealers checkpoint their state to the store; a round
+// found in the store is resumed instead of being started from scratch.
+func WithDealerStore(store dkglib.DealerStore) DKGOption {
+	return func(d *OffChainDKG) { d.dealerStore = store }
+}
+
+// WithKeystore makes the verifiers produced by finished rounds survive
+// restarts; the latest stored verifier is loaded on construction.
+func WithKeystore(ks *blsShare.Keystore) DKGOption {
+	return func(d *OffChainDKG) { d.keystore = ks }
+}
+
+// WithWeightedShares gives every validator a number of shares proportional to
+// its voting power, maxShares in total (give or take one per validator).
+func WithWeightedShares(maxShares int) DKGOption {
+	return func(d *OffChainDKG) { d.weightedShares = maxShares }
+}
+
+// WithThresholdPolicy sets the number of shares needed to recover the group
+// key; the default is more than two thirds. Dealers refuse to start with an
+// invalid policy.
+func WithThresholdPolicy(policy blsShare.ThresholdPolicy) DKGOption {
+	return func(d *OffChainDKG) { d.thresholdPolicy = policy }
+}
+
+// WithPairingSuite sets the curve the keys are generated on; the default is
+// bn256.
+func WithPairingSuite(suite *blsShare.Suite) DKGOption {
+	return func(d *OffChainDKG) { d.pairingSuite = suite }
+}
+
+// WithDealerSeed derives the secrets of the dealers from the seed instead of
+// the system randomness, which makes the rounds reproducible. It is meant for
+// simulations; anyone who knows the seed knows the shares.
+func WithDealerSeed(seed []byte) DKGOption {
+	return func(d *OffChainDKG) { d.dealerSeed = seed }
+}
+
+// WithRoundRetention sets the number of latest rounds whose dealers are kept
+// once the rounds are over; older ones are freed.
+func WithRoundRetention(rounds int) DKGOption {
+	return func(d *OffChainDKG) { d.roundRetention = rounds }
+}
+
+// WithAdmissionPolicy sets which rounds the messages of other validators can
+// open (see AdmissionPolicy).
+func WithAdmissionPolicy(policy AdmissionPolicy) DKGOption {
+	return func(d *OffChainDKG) { d.admissionPolicy = policy }
+}
+
+// WithMetrics sets the metrics of the messages and rounds; none by default.
+func WithMetrics(metrics *Metrics) DKGOption {
+	return func(d *OffChainDKG) { d.metrics = metrics }
+}
+
+// WithRetryPolicy sets what happens after a round fails (see RetryPolicy).
+func WithRetryPolicy(policy RetryPolicy) DKGOption {
+	return func(d *OffChainDKG) { d.retryPolicy = policy }
+}
+
 func WithDKGDealerConstructor(newDealer dkglib.DKGDealerConstructor) DKGOption {
 	return func(d *OffChainDKG) {
 		if newDealer == nil {
@@ -100,36 +230,52 @@ func (m *OffChainDKG) HandleOffChainShare(
 	height int64,
 	validators *alias.ValidatorSet,
 	pubKey crypto.PubKey,
-) (switchToOnChain bool) {
+) (switchToOnChain bool, err error) {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
+	if err := dkgMsg.ValidateBasic(); err != nil {
+		m.Logger.Info("dkgState: invalid message", "error", err)
+		return false, nil
+	}
+
 	var msg = dkgMsg.Data
+	if msg.RoundID < m.retiredBelow {
+		m.reject(msg, RejectRetiredRound)
+		return false, nil
+	}
 	dealer, ok := m.dkgRoundToDealer[msg.RoundID]
+	// A message opening a round has been verified on admission.
+	opened := !ok
 	if !ok {
 		m.Logger.Debug("dkgState: dealer not found, creating a new dealer", "round_id", msg.RoundID)
-		dealer = m.newDKGDealer(validators, m.privValidator, m.sendSignedMessage, m.evsw, m.Logger, msg.RoundID)
+		var reason string
+		if dealer, reason = m.admitRound(dkgMsg, height, validators); dealer == nil {
+			m.reject(msg, reason)
+			return false, nil
+		}
 		m.dkgRoundToDealer[msg.RoundID] = dealer
-		if err := dealer.Start(); err != nil {
-			m.Logger.Debug("dealer start failed, panic", "error", err.Error())
-			panic(fmt.Sprintf("failed to start a dealer (round %d): %v", m.dkgRoundID, err))
+		if err := m.startDealer(dealer, msg.RoundID); err != nil {
+			rerr := m.roundFailed(msg.RoundID, height, "start dealer", err)
+			return rerr.Fallback, rerr
 		}
 	}
 	if dealer == nil {
 		m.Logger.Debug("dkgState: received message for inactive round:", "round", msg.RoundID)
-		return false
+		return false, nil
 	}
 	m.Logger.Debug("dkgState: received message with signature:", "signature", hex.EncodeToString(dkgMsg.Data.Signature))
 
-	if err := dealer.VerifyMessage(*dkgMsg); err != nil {
-		m.Logger.Info("DKG: can't verify message:", "error", err.Error())
-		return false
+	if !opened {
+		if err := dealer.VerifyMessage(*dkgMsg); err != nil {
+			m.Logger.Info("DKG: can't verify message:", "error", err.Error())
+			return false, nil
+		}
+		m.Logger.Info("DKG: message verified")
 	}
-	m.Logger.Info("DKG: message verified")
 
 	fromAddr := crypto.Address(msg.Addr).String()
 
-	var err error
 	switch msg.Type {
 	case dkgalias.DKGPubKey:
 		m.Logger.Info("dkgState: received PubKey message", "from", fromAddr, "own", m.privValidator.GetPubKey().Address())
@@ -155,49 +301,226 @@ func (m *OffChainDKG) HandleOffChainShare(
 	}
 	if err != nil {
 		m.Logger.Error("dkgState: failed to handle message", "error", err, "type", msg.Type)
-		m.dkgRoundToDealer[msg.RoundID] = nil
-		return false
+		rerr := m.roundFailed(msg.RoundID, height, "handle message", err)
+		return rerr.Fallback, rerr
 	}
 
+	return m.checkVerifier(dealer, msg.RoundID, height)
+}
+
+// checkVerifier switches to the next verifier if the dealer has finished the
+// round. It returns true if the round has failed and on-chain DKG should be
+// used instead.
+func (m *OffChainDKG) checkVerifier(dealer dkglib.Dealer, roundID int, height int64) (switchToOnChain bool, err error) {
 	verifier, err := dealer.GetVerifier()
 	if err == dkgtypes.ErrDKGVerifierNotReady {
 		m.Logger.Debug("dkgState: verifier not ready")
-		return false
+		return false, nil
 	}
 	if err != nil {
 		m.Logger.Debug("dkgState: verifier should be ready, but it's not ready:", "error", err)
-		m.dkgRoundToDealer[msg.RoundID] = nil
-		return true
+		rerr := m.roundFailed(roundID, height, "get verifier", err)
+		return rerr.Fallback, rerr
+	}
+	m.failures, m.retryHeight = 0, 0
+	if m.scheduled != nil && m.roundKinds[roundID] == m.scheduled.kind {
+		m.scheduled = nil
 	}
 	m.Logger.Info("dkgState: verifier is ready, killing older rounds")
-	for roundID := range m.dkgRoundToDealer {
-		if roundID < msg.RoundID {
-			m.dkgRoundToDealer[msg.RoundID] = nil
+	for id := range m.dkgRoundToDealer {
+		if id < roundID {
+			m.dkgRoundToDealer[id] = nil
+		}
+	}
+	m.retireRounds()
+	if m.dealerStore != nil {
+		if err := m.dealerStore.Delete(roundID); err != nil {
+			m.Logger.Error("dkgState: failed to delete finished round from store", "round", roundID, "error", err)
 		}
 	}
 	m.nextVerifier = verifier
 	m.changeHeight = (height + BlocksAhead) - ((height + BlocksAhead) % 5)
+	if blsVerifier, ok := verifier.(*blsShare.BLSVerifier); ok && m.keystore != nil {
+		err := m.keystore.Save(&blsShare.VerifierRecord{
+			Verifier:         blsVerifier,
+			RoundID:          roundID,
+			ActivationHeight: m.changeHeight,
+		})
+		if err != nil {
+			m.Logger.Error("dkgState: failed to save verifier to keystore", "round", roundID, "error", err)
+		}
+	}
 	m.evsw.FireEvent(dkgtypes.EventDKGSuccessful, m.changeHeight)
 
 	m.Logger.Info("handle off-chain share success")
 
-	return false
+	return false, nil
+}
+
+// retireRounds frees the dealers of the rounds that fall out of the
+// retention window, whether they are over or not: a round that old will not
+// be used any more, and its late messages are dropped.
+func (m *OffChainDKG) retireRounds() {
+	watermark := m.dkgRoundID - m.roundRetention + 1
+	if watermark <= m.retiredBelow {
+		return
+	}
+
+	for id, dealer := range m.dkgRoundToDealer {
+		if id >= watermark {
+			continue
+		}
+		if dealer != nil {
+			if _, err := dealer.GetVerifier(); err == dkgtypes.ErrDKGVerifierNotReady {
+				m.Logger.Info("dkgState: retiring unfinished round", "round", id)
+			}
+		}
+		delete(m.dkgRoundToDealer, id)
+		delete(m.roundKinds, id)
+		if m.dealerStore != nil {
+			if err := m.dealerStore.Delete(id); err != nil {
+				m.Logger.Error("dkgState: failed to delete retired round from store", "round", id, "error", err)
+			}
+		}
+	}
+	m.retiredBelow = watermark
+	m.Logger.Debug("dkgState: retired rounds", "below", watermark)
 }
 
+// roundFailed ends the round and lets the retry policy decide what comes
+// next. The failure is fired as EventDKGRoundFailed and returned.
+func (m *OffChainDKG) roundFailed(roundID int, height int64, op string, err error) *dkgtypes.RoundError {
+	m.dkgRoundToDealer[roundID] = nil
+	rerr := &dkgtypes.RoundError{RoundID: roundID, Op: op, Err: err}
+
+	// Only the failure of the latest round calls for a new one.
+	if roundID >= m.dkgRoundID {
+		m.failures++
+		if m.failures <= m.retryPolicy.MaxRetries {
+			m.retryHeight = height + m.retryPolicy.backoff(m.failures)
+			m.Logger.Info("dkgState: round failed, retrying", "round", roundID, "retry", m.failures, "height", m.retryHeight, "error", err)
+		} else {
+			m.failures, m.retryHeight = 0, 0
+			rerr.Fallback = m.retryPolicy.OnChainFallback
+			m.Logger.Info("dkgState: round failed, giving up", "round", roundID, "on_chain_fallback", rerr.Fallback, "error", err)
+		}
+	}
+	m.evsw.FireEvent(dkgtypes.EventDKGRoundFailed, rerr)
+
+	return rerr
+}
+
+func (m *OffChainDKG) newDealer(newDKGDealer dkglib.DKGDealerConstructor, validators *alias.ValidatorSet, roundID int) dkglib.Dealer {
+	dealer := newDKGDealer(validators, m.privValidator, m.sendSignedMessage, m.evsw, m.Logger, roundID)
+	dealer.SetPhaseTimeouts(m.phaseTimeouts)
+	dealer.SetThresholdPolicy(m.thresholdPolicy)
+	dealer.SetSuite(m.pairingSuite)
+	if m.dealerSeed != nil {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("%x/%d", m.dealerSeed, roundID)))
+		dealer.SetSeed(seed[:])
+	}
+	return dealer
+}
+
+// startDealer starts the dealer, or resumes it if the round is found in
+// the dealer store.
+func (m *OffChainDKG) startDealer(dealer dkglib.Dealer, roundID int) error {
+	if m.dealerStore == nil {
+		return dealer.Start()
+	}
+	snapshot, err := m.dealerStore.Load(roundID)
+	if err != nil {
+		return fmt.Errorf("failed to load round %d: %v", roundID, err)
+	}
+	if snapshot == nil {
+		dealer.SetStore(m.dealerStore)
+		return dealer.Start()
+	}
+	m.Logger.Info("dkgState: resuming round from store", "round", roundID, "messages", len(snapshot.Messages))
+
+	return dkglib.Resume(dealer, m.dealerStore, snapshot, m.Logger)
+}
+
+// notifyDealers passes the new height to the active dealers, so that they
+// can give up waiting for messages of an expired phase. It returns the first
+// round failure.
+func (m *OffChainDKG) notifyDealers(height int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var failure error
+	for roundID, dealer := range m.dkgRoundToDealer {
+		if dealer == nil {
+			continue
+		}
+		if _, err := dealer.GetVerifier(); err != dkgtypes.ErrDKGVerifierNotReady {
+			continue // The round is already over.
+		}
+		err := dealer.NewBlock(height)
+		if err != nil {
+			m.Logger.Error("dkgState: round failed after phase timeout", "round", roundID, "error", err)
+			err = m.roundFailed(roundID, height, "phase timeout", err)
+		} else {
+			_, err = m.checkVerifier(dealer, roundID, height)
+		}
+		if err != nil && failure == nil {
+			failure = err
+		}
+	}
+
+	return failure
+}
+
+// startRound starts the next round, of the kind given by nextRound. The
+// round may have been opened already by the messages of other validators.
 func (m *OffChainDKG) startRound(validators *alias.ValidatorSet) error {
+	newDKGDealer, kind, validators := m.nextRound(validators)
 	m.dkgRoundID++
-	m.Logger.Info("OffChainDKG: starting round", "round_id", m.dkgRoundID)
+	m.Logger.Info("OffChainDKG: starting round", "round_id", m.dkgRoundID, "kind", kind)
+	m.retireRounds()
 	_, ok := m.dkgRoundToDealer[m.dkgRoundID]
 	if !ok {
-		dealer := m.newDKGDealer(validators, m.privValidator, m.sendSignedMessage, m.evsw, m.Logger, m.dkgRoundID)
+		dealer := m.newDealer(newDKGDealer, validators, m.dkgRoundID)
 		m.dkgRoundToDealer[m.dkgRoundID] = dealer
+		m.roundKinds[m.dkgRoundID] = kind
 		m.evsw.FireEvent(dkgtypes.EventDKGStart, m.dkgRoundID)
-		return dealer.Start()
+		return m.startDealer(dealer, m.dkgRoundID)
+	}
+	// A scheduled round may be over before the node starts it, which ends
+	// the schedule; the other way round, the node would run a round of
+	// another kind than the one scheduled.
+	if opened := m.roundKinds[m.dkgRoundID]; kind != roundKindDKG && opened != kind {
+		return fmt.Errorf("round %d has been opened as a %s round, not as a %s one; schedule it ahead", m.dkgRoundID, opened, kind)
 	}
 
 	return nil
 }
 
+// Kinds of rounds.
+const (
+	roundKindDKG     = "dkg"
+	roundKindReshare = "reshare"
+	roundKindRefresh = "refresh"
+)
+
+// scheduledRound is a round of another kind than a regular DKG one, to be run
+// as the next round.
+type scheduledRound struct {
+	kind       string
+	newDealer  dkglib.DKGDealerConstructor
+	validators *alias.ValidatorSet
+}
+
+// nextRound returns the dealer constructor, the kind and the validators of
+// the round following the latest one started by the node: the scheduled
+// round if any, a regular DKG round with validators otherwise.
+func (m *OffChainDKG) nextRound(validators *alias.ValidatorSet) (dkglib.DKGDealerConstructor, string, *alias.ValidatorSet) {
+	if m.scheduled != nil {
+		return m.scheduled.newDealer, m.scheduled.kind, m.scheduled.validators
+	}
+	return m.newDKGDealer, roundKindDKG, validators
+}
+
 func (m *OffChainDKG) sendDKGMessage(msg *dkgalias.DKGData) {
 	// Broadcast to peers. This will not lead to processing the message
 	// on the sending node, we need to send it manually (see below).
@@ -237,30 +560,211 @@ func (m *OffChainDKG) Sign(data *dkgalias.DKGData) error {
 	return nil
 }
 
-func (m *OffChainDKG) CheckDKGTime(height int64, validators *alias.ValidatorSet) {
+func (m *OffChainDKG) CheckDKGTime(height int64, validators *alias.ValidatorSet) error {
 	if (height == -1) && m.nextVerifier == nil {
-		return
+		return nil
 	}
 
-	if (height == -1) || m.changeHeight == height {
+	if (height == -1) || (m.nextVerifier != nil && m.changeHeight <= height) {
 		m.Logger.Info("dkgState: time to update verifier", m.changeHeight, height)
 		m.verifier, m.nextVerifier = m.nextVerifier, nil
 		m.changeHeight = 0
 		m.evsw.FireEvent(dkgtypes.EventDKGKeyChange, height)
 	}
 
-	if height > 1 && height%m.dkgNumBlocks == 0 {
+	var failure error
+	if height > 0 {
+		failure = m.notifyDealers(height)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	scheduled := height > 1 && height%m.dkgNumBlocks == 0
+	retry := m.retryHeight > 0 && height >= m.retryHeight
+	if scheduled || retry {
+		m.retryHeight = 0
 		if err := m.startRound(validators); err != nil {
-			m.Logger.Debug("failed to start a dealer", "round", m.dkgRoundID, "error", err)
-			panic(fmt.Sprintf("failed to start a dealer (round %d): %v", m.dkgRoundID, err))
+			m.Logger.Error("failed to start a dealer", "round", m.dkgRoundID, "error", err)
+			rerr := m.roundFailed(m.dkgRoundID, height, "start dealer", err)
+			if failure == nil {
+				failure = rerr
+			}
 		}
 	}
+
+	return failure
 }
 
+// StartDKGRound starts the next round: a regular one, or the one scheduled
+// with ScheduleReshareRound.
 func (m *OffChainDKG) StartDKGRound(validators *alias.ValidatorSet) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.startRound(validators)
+}
+
+// StartReshareRound schedules a resharing round (see ScheduleReshareRound) and
+// starts it right away. Unless the round has been scheduled beforehand, the
+// node may get its messages before it has started it: a round opened by those
+// messages as a regular one is not turned into a resharing one, and an error
+// is returned.
+func (m *OffChainDKG) StartReshareRound(oldValidators, validators *alias.ValidatorSet, masterPubKey *share.PubPoly) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.scheduleReshareRound(oldValidators, validators, masterPubKey); err != nil {
+		return err
+	}
 	return m.startRound(validators)
 }
 
+// ScheduleReshareRound makes the next round move the shares of the group key
+// from oldValidators to validators (see dkglib.NewReshareDealerConstructor),
+// instead of generating a new key. The round is started as a regular one
+// would be, by CheckDKGTime or StartDKGRound, and is retried until it
+// succeeds. All the nodes of both sets must schedule it before any of them
+// starts it, so that the messages of the round open a resharing round on
+// every node.
+func (m *OffChainDKG) ScheduleReshareRound(oldValidators, validators *alias.ValidatorSet, masterPubKey *share.PubPoly) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.scheduleReshareRound(oldValidators, validators, masterPubKey)
+}
+
+func (m *OffChainDKG) scheduleReshareRound(oldValidators, validators *alias.ValidatorSet, masterPubKey *share.PubPoly) error {
+	if m.weightedShares > 0 {
+		return errors.New("resharing of weighted shares is not supported")
+	}
+
+	var ownShare *share.PriShare
+	if blsVerifier := m.latestBLSVerifier(); blsVerifier != nil {
+		if masterPubKey == nil {
+			masterPubKey = blsVerifier.MasterPubKey()
+		}
+		if blsVerifier.Suite().Name != m.pairingSuite.Name {
+			return fmt.Errorf("the key is on %s, rounds run on %s", blsVerifier.Suite().Name, m.pairingSuite.Name)
+		}
+		if blsVerifier.MasterPubKey().Commit().Equal(masterPubKey.Commit()) {
+			ownShare = blsVerifier.Keypair.Priv
+			// The new validators know the holders from oldValidators only.
+			if err := checkHolders(blsVerifier, oldValidators); err != nil {
+				return err
+			}
+		}
+	}
+	if masterPubKey == nil {
+		return errors.New("no master public key to reshare")
+	}
+	if !oldValidators.HasAddress(m.privValidator.GetPubKey().Address()) {
+		ownShare = nil
+	}
+
+	m.scheduled = &scheduledRound{
+		kind:       roundKindReshare,
+		newDealer:  dkglib.NewReshareDealerConstructor(oldValidators, masterPubKey, ownShare),
+		validators: validators,
+	}
+	return nil
+}
+
+// StartRefreshRound schedules a refresh round (see ScheduleRefreshRound) and
+// starts it right away. As with StartReshareRound, a round opened before by
+// the messages of other validators as another kind is an error.
+func (m *OffChainDKG) StartRefreshRound(validators *alias.ValidatorSet) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.scheduleRefreshRound(validators); err != nil {
+		return err
+	}
+	return m.startRound(validators)
+}
+
+// ScheduleRefreshRound makes the next round refresh the shares of the group
+// key held by validators (see dkglib.NewRefreshDealerConstructor), instead of
+// generating a new key. The refreshed verifier replaces the current one just
+// like the verifier of a new key. It is to be scheduled by all the validators
+// before any of them starts the round, see ScheduleReshareRound.
+func (m *OffChainDKG) ScheduleRefreshRound(validators *alias.ValidatorSet) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.scheduleRefreshRound(validators)
+}
+
+func (m *OffChainDKG) scheduleRefreshRound(validators *alias.ValidatorSet) error {
+	if m.weightedShares > 0 {
+		return errors.New("refresh of weighted shares is not supported")
+	}
+	blsVerifier := m.latestBLSVerifier()
+	if blsVerifier == nil {
+		return errors.New("no share to refresh")
+	}
+	if blsVerifier.Suite().Name != m.pairingSuite.Name {
+		return fmt.Errorf("the key is on %s, rounds run on %s", blsVerifier.Suite().Name, m.pairingSuite.Name)
+	}
+
+	holders, err := holderAddrs(blsVerifier)
+	if err != nil {
+		return err
+	}
+
+	m.scheduled = &scheduledRound{
+		kind:       roundKindRefresh,
+		newDealer:  dkglib.NewRefreshDealerConstructor(holders, blsVerifier.MasterPubKey(), blsVerifier.Keypair.Priv),
+		validators: validators,
+	}
+	return nil
+}
+
+// holderAddrs returns the holders of the shares of the verifier's key by
+// share index; nil if unknown.
+func holderAddrs(verifier *blsShare.BLSVerifier) ([]crypto.Address, error) {
+	var out []crypto.Address
+	for _, holder := range verifier.Holders() {
+		addr, err := hex.DecodeString(holder)
+		if err != nil {
+			return nil, fmt.Errorf("invalid share holder %q: %v", holder, err)
+		}
+		out = append(out, crypto.Address(addr))
+	}
+	return out, nil
+}
+
+// checkHolders checks that validators, sorted by address, are the holders of
+// the shares of the verifier's key. After a round with phase timeouts, the
+// holders are only the validators whose keys were received in time.
+func checkHolders(verifier *blsShare.BLSVerifier, validators *alias.ValidatorSet) error {
+	holders := verifier.Holders()
+	if holders == nil {
+		return nil
+	}
+	if len(holders) != validators.Size() {
+		return fmt.Errorf("the key has %d share holders, not the %d old validators", len(holders), validators.Size())
+	}
+	for _, holder := range holders {
+		addr, err := hex.DecodeString(holder)
+		if err != nil || !validators.HasAddress(addr) {
+			return fmt.Errorf("share holder %s is not an old validator", holder)
+		}
+	}
+	return nil
+}
+
+// latestBLSVerifier returns the verifier of the latest key, which holds the
+// share of the node, if any.
+func (m *OffChainDKG) latestBLSVerifier() *blsShare.BLSVerifier {
+	verifier := m.nextVerifier
+	if verifier == nil {
+		verifier = m.verifier
+	}
+	blsVerifier, _ := verifier.(*blsShare.BLSVerifier)
+	return blsVerifier
+}
+
 func (m *OffChainDKG) MsgQueue() chan *dkgtypes.DKGDataMessage {
 	return m.dkgMsgQueue
 }
@@ -273,6 +777,11 @@ func (m *OffChainDKG) SetVerifier(v dkgtypes.Verifier) {
 	m.verifier = v
 }
 
+// PairingSuite returns the curve the rounds run on.
+func (m *OffChainDKG) PairingSuite() *blsShare.Suite {
+	return m.pairingSuite
+}
+
 func (m *OffChainDKG) GetPrivValidator() alias.PrivValidator {
 	return m.privValidator
 }
@@ -281,17 +790,42 @@ func (m *OffChainDKG) ProcessBlock(roundID int) (error, bool) {
 	return nil, true
 }
 
-func (m *OffChainDKG) GetLosers() []*tmtypes.Validator {
+func (m *OffChainDKG) GetLosers() ([]*tmtypes.Validator, error) {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
 	dealer, ok := m.dkgRoundToDealer[m.dkgRoundID]
-	if !ok {
+	if !ok || dealer == nil {
 		m.Logger.Debug("failed to get dealer for current", "roundID", m.dkgRoundID)
-		panic(fmt.Sprintf("failed to get dealer for current round ID (%d)", m.dkgRoundID))
+		return nil, &dkgtypes.RoundError{RoundID: m.dkgRoundID, Op: "get losers", Err: dkgtypes.ErrRoundNotFound}
+	}
+
+	return dealer.PopLosers(), nil
+}
+
+func (m *OffChainDKG) GetLoserProofs() []*dkgtypes.LoserProof {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	dealer, ok := m.dkgRoundToDealer[m.dkgRoundID]
+	if !ok || dealer == nil {
+		return nil
+	}
+
+	return dealer.GetLoserProofs()
+}
+
+// GetEvidence returns the equivocations detected in the current round.
+func (m *OffChainDKG) GetEvidence() []*dkgtypes.EquivocationEvidence {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	dealer, ok := m.dkgRoundToDealer[m.dkgRoundID]
+	if !ok || dealer == nil {
+		return nil
 	}
 
-	return dealer.PopLosers()
+	return dealer.GetEvidence()
 }
 
 type verifierFunc func(s string, i int) dkgtypes.Verifier
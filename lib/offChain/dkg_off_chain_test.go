@@ -0,0 +1,267 @@
+package offChain
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	dkgalias "github.com/corestario/dkglib/lib/alias"
+	dkglib "github.com/corestario/dkglib/lib/dealer"
+	dkgtypes "github.com/corestario/dkglib/lib/types"
+	"github.com/go-kit/kit/metrics"
+	"github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+// testNet runs nodes that pass the messages of their queues to each other,
+// one block at a time.
+type testNet struct {
+	t          *testing.T
+	validators *alias.ValidatorSet
+	pvs        []alias.PrivValidator
+	nodes      []*OffChainDKG
+	metrics    []*testMetrics
+	errs       [][]error
+	height     int64
+}
+
+func newTestNet(t *testing.T, n int, options ...DKGOption) *testNet {
+	t.Helper()
+	net := &testNet{t: t, errs: make([][]error, n)}
+	var validators []*alias.Validator
+	for i := 0; i < n; i++ {
+		pv := tm.NewMockPV()
+		net.pvs = append(net.pvs, pv)
+		validators = append(validators, tm.NewValidator(pv.GetPubKey(), 1))
+	}
+	net.validators = alias.NewValidatorSet(validators)
+	for _, pv := range net.pvs {
+		m := newTestMetrics()
+		nodeOptions := append([]DKGOption{WithLogger(log.NewNopLogger()), WithPVKey(pv), WithMetrics(m.Metrics())}, options...)
+		net.nodes = append(net.nodes, NewOffChainDKG(events.NewEventSwitch(), "test", nodeOptions...))
+		net.metrics = append(net.metrics, m)
+	}
+	return net
+}
+
+// start makes node i start its next round and returns the messages it sends
+// for it, undelivered.
+func (net *testNet) start(i int) []*dkgalias.DKGData {
+	net.t.Helper()
+	if err := net.nodes[i].StartDKGRound(net.validators); err != nil {
+		net.t.Fatal(err)
+	}
+	return net.drain(i)
+}
+
+// drain empties the queue of node i.
+func (net *testNet) drain(i int) []*dkgalias.DKGData {
+	var out []*dkgalias.DKGData
+	for {
+		select {
+		case msg := <-net.nodes[i].MsgQueue():
+			out = append(out, msg.Data)
+		default:
+			return out
+		}
+	}
+}
+
+// handle passes msg to node i.
+func (net *testNet) handle(i int, msg *dkgalias.DKGData) {
+	_, err := net.nodes[i].HandleOffChainShare(&dkgtypes.DKGDataMessage{Data: msg}, net.height, net.validators, nil)
+	if err != nil {
+		net.errs[i] = append(net.errs[i], err)
+	}
+}
+
+// deliver passes the queued messages to every node until no more are sent.
+func (net *testNet) deliver() {
+	for {
+		var messages []*dkgalias.DKGData
+		for i := range net.nodes {
+			messages = append(messages, net.drain(i)...)
+		}
+		if len(messages) == 0 {
+			return
+		}
+		for _, msg := range messages {
+			for i := range net.nodes {
+				net.handle(i, msg)
+			}
+		}
+	}
+}
+
+// step tells every node about a new block and delivers the messages sent.
+func (net *testNet) step() {
+	net.height++
+	for i, node := range net.nodes {
+		if err := node.CheckDKGTime(net.height, net.validators); err != nil {
+			net.errs[i] = append(net.errs[i], err)
+		}
+	}
+	net.deliver()
+}
+
+// run steps until every node has a new verifier or maxBlocks have passed.
+func (net *testNet) run(maxBlocks int) bool {
+	done := func() bool {
+		for _, node := range net.nodes {
+			if node.nextVerifier == nil {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < maxBlocks && !done(); i++ {
+		net.step()
+	}
+	return done()
+}
+
+func (net *testNet) noErrors() {
+	net.t.Helper()
+	for i, errs := range net.errs {
+		for _, err := range errs {
+			net.t.Errorf("node %d: %v", i, err)
+		}
+	}
+}
+
+// rounds returns the rounds node i has a dealer for, nil or not.
+func (net *testNet) rounds(i int) map[int]bool {
+	out := make(map[int]bool)
+	for id, dealer := range net.nodes[i].dkgRoundToDealer {
+		out[id] = dealer != nil
+	}
+	return out
+}
+
+// testMetrics counts what is added to the metrics, by label values.
+type testMetrics struct {
+	mtx    sync.Mutex
+	counts map[string]float64
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{counts: make(map[string]float64)}
+}
+
+func (m *testMetrics) Metrics() *Metrics {
+	return &Metrics{
+		RejectedMessages: &testCounter{m: m, name: "rejected_messages"},
+		OpenedRounds:     &testCounter{m: m, name: "opened_rounds"},
+	}
+}
+
+func (m *testMetrics) count(name string, labelValues ...string) float64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.counts[strings.Join(append([]string{name}, labelValues...), ",")]
+}
+
+type testCounter struct {
+	m    *testMetrics
+	name string
+}
+
+func (c *testCounter) With(labelValues ...string) metrics.Counter {
+	return &testCounter{m: c.m, name: strings.Join(append([]string{c.name}, labelValues...), ",")}
+}
+
+func (c *testCounter) Add(delta float64) {
+	c.m.mtx.Lock()
+	defer c.m.mtx.Unlock()
+	c.m.counts[c.name] += delta
+}
+
+func TestRetireRounds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := dkglib.NewFileDealerStore(dir, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	net := newTestNet(t, 2, WithRoundRetention(2), WithDealerStore(store))
+	first := net.start(1)
+	net.start(1)
+	if snapshot, err := store.Load(1); err != nil || snapshot == nil {
+		t.Fatalf("round 1 is not stored: %v", err)
+	}
+
+	// Round 3 pushes round 1 out of the window, unfinished as it is.
+	net.start(1)
+	if rounds := net.rounds(1); len(rounds) != 2 || !rounds[2] || !rounds[3] {
+		t.Fatalf("rounds %v, want 2 and 3", rounds)
+	}
+	if net.nodes[1].retiredBelow != 2 {
+		t.Fatalf("retired below %d, want 2", net.nodes[1].retiredBelow)
+	}
+	if _, ok := net.nodes[1].roundKinds[1]; ok {
+		t.Fatal("the kind of round 1 is kept")
+	}
+	if snapshot, err := store.Load(1); err != nil || snapshot != nil {
+		t.Fatalf("round 1 is still stored: %v", err)
+	}
+
+	// The late messages of round 1 do not open it again.
+	for _, msg := range first {
+		net.handle(1, msg)
+	}
+	if _, ok := net.nodes[1].dkgRoundToDealer[1]; ok {
+		t.Fatal("round 1 has been opened again")
+	}
+	if got := net.metrics[1].count("rejected_messages", "reason", RejectRetiredRound); got != float64(len(first)) {
+		t.Fatalf("%v retired round rejections, want %d", got, len(first))
+	}
+	net.noErrors()
+}
+
+// A successful round ends the rounds before it right away, and retires them
+// once they fall out of the window.
+func TestRetireFinishedRounds(t *testing.T) {
+	net := newTestNet(t, 4, WithRoundRetention(2))
+	for i := range net.nodes {
+		net.start(i) // Round 1 never gets its messages.
+	}
+	for i := range net.nodes {
+		if err := net.nodes[i].StartDKGRound(net.validators); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !net.run(20) {
+		t.Fatalf("round 2 did not finish after %d blocks", net.height)
+	}
+	net.noErrors()
+	for i := range net.nodes {
+		if rounds := net.rounds(i); len(rounds) != 2 || rounds[1] || !rounds[2] {
+			t.Fatalf("node %d: rounds %v, want round 1 ended and round 2 active", i, rounds)
+		}
+	}
+
+	for i := range net.nodes {
+		net.start(i)
+		if rounds := net.rounds(i); len(rounds) != 2 || !rounds[2] || !rounds[3] {
+			t.Fatalf("node %d: rounds %v, want 2 and 3", i, rounds)
+		}
+	}
+}
+
+// The current round is kept whatever the retention.
+func TestRetireRoundsMinimum(t *testing.T) {
+	net := newTestNet(t, 1, WithRoundRetention(0))
+	net.start(0)
+	net.start(0)
+	if rounds := net.rounds(0); len(rounds) != 1 || !rounds[2] {
+		t.Fatalf("rounds %v, want 2", rounds)
+	}
+}
@@ -0,0 +1,55 @@
+package offChain
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+
+	prometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// MetricsSubsystem is a subsystem shared by all metrics exposed by this
+	// package.
+	MetricsSubsystem = "dkg"
+)
+
+// Metrics contains metrics exposed by this package.
+type Metrics struct {
+	// Number of messages rejected before being handled, by reason.
+	RejectedMessages metrics.Counter
+	// Number of rounds opened on a message of another validator.
+	OpenedRounds metrics.Counter
+}
+
+// PrometheusMetrics returns Metrics build using Prometheus client library.
+// Optionally, labels can be provided along with their values ("foo",
+// "fooValue").
+func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
+	labels := []string{}
+	for i := 0; i < len(labelsAndValues); i += 2 {
+		labels = append(labels, labelsAndValues[i])
+	}
+	return &Metrics{
+		RejectedMessages: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "rejected_messages",
+			Help:      "Number of messages rejected before being handled, by reason.",
+		}, append(labels, "reason")).With(labelsAndValues...),
+		OpenedRounds: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "opened_rounds",
+			Help:      "Number of rounds opened on a message of another validator.",
+		}, labels).With(labelsAndValues...),
+	}
+}
+
+// NopMetrics returns no-op Metrics.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		RejectedMessages: discard.NewCounter(),
+		OpenedRounds:     discard.NewCounter(),
+	}
+}
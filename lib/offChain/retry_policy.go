@@ -0,0 +1,36 @@
+package offChain
+
+// RetryPolicy decides what happens after an off-chain round fails: a new
+// round is started after a backoff, and once the retries are exhausted the
+// node may fall back to on-chain DKG.
+type RetryPolicy struct {
+	MaxRetries int   // Number of rounds started after a failure; zero means none.
+	Backoff    int64 // Blocks to wait before the first retry; doubles with every retry.
+	MaxBackoff int64 // Upper bound of the backoff; none if zero.
+	// OnChainFallback makes the node switch to on-chain DKG once the
+	// retries are exhausted. Otherwise it waits for the next scheduled
+	// round.
+	OnChainFallback bool
+}
+
+// DefaultRetryPolicy falls back to on-chain DKG on the first failure.
+var DefaultRetryPolicy = RetryPolicy{OnChainFallback: true}
+
+// backoff returns the number of blocks to wait before the retry (counted
+// from 1).
+func (p RetryPolicy) backoff(retry int) int64 {
+	backoff := p.Backoff
+	if backoff < 1 {
+		backoff = 1
+	}
+	for i := 1; i < retry; i++ {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
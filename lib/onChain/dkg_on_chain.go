@@ -1,75 +1,115 @@
 package onChain
 
 import (
-	"bytes"
-	"encoding/gob"
+	"errors"
 	"fmt"
 	"os"
 
 	authtxb "github.com/corestario/cosmos-utils/client/authtypes"
 	"github.com/corestario/cosmos-utils/client/context"
-	"github.com/corestario/cosmos-utils/client/utils"
 	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
 	"github.com/corestario/dkglib/lib/dealer"
-	"github.com/corestario/dkglib/lib/msgs"
 	"github.com/corestario/dkglib/lib/types"
-	"github.com/cosmos/cosmos-sdk/client/keys"
-	sdk "github.com/cosmos/cosmos-sdk/types"
-	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	tmtypes "github.com/tendermint/tendermint/alias"
 	"github.com/tendermint/tendermint/libs/events"
 	"github.com/tendermint/tendermint/libs/log"
+	"go.dedis.ch/kyber/v3/share"
 )
 
 type OnChainDKG struct {
-	cli             *context.Context
-	txBldr          *authtxb.TxBuilder
+	transport       Transport
 	dealer          dealer.Dealer
+	pv              tmtypes.PrivValidator
 	typesList       []alias.DKGDataType
 	logger          log.Logger
 	lastAccSequence int
+	dealerStore     dealer.DealerStore
+	thresholdPolicy blsShare.ThresholdPolicy
+	pairingSuite    *blsShare.Suite
+	phaseTimeouts   dealer.PhaseTimeouts
+	blocks          int64 // Number of ProcessBlock calls so far.
+
+	pending []*alias.DKGData // Messages of future rounds.
+}
+
+// OnChainOption sets an optional parameter on the OnChainDKG.
+type OnChainOption func(*OnChainDKG)
+
+// WithDealerStore makes the dealer checkpoint its state to the store; a round
+// found in the store is resumed by StartRound instead of being started from
+// scratch.
+func WithDealerStore(store dealer.DealerStore) OnChainOption {
+	return func(m *OnChainDKG) { m.dealerStore = store }
+}
+
+// WithThresholdPolicy sets the number of shares needed to recover the group
+// key; the default is more than two thirds.
+func WithThresholdPolicy(policy blsShare.ThresholdPolicy) OnChainOption {
+	return func(m *OnChainDKG) { m.thresholdPolicy = policy }
+}
+
+// WithPairingSuite sets the curve the keys are generated on; the default is
+// bn256.
+func WithPairingSuite(suite *blsShare.Suite) OnChainOption {
+	return func(m *OnChainDKG) { m.pairingSuite = suite }
+}
+
+// WithPhaseTimeouts makes the dealer stop waiting for the messages of a
+// phase after the given number of blocks or amount of time; the blocks are
+// counted in ProcessBlock calls.
+func WithPhaseTimeouts(timeouts dealer.PhaseTimeouts) OnChainOption {
+	return func(m *OnChainDKG) { m.phaseTimeouts = timeouts }
 }
 
-func NewOnChainDKG(cli *context.Context, txBldr *authtxb.TxBuilder) *OnChainDKG {
-	return &OnChainDKG{
-		cli:    cli,
-		txBldr: txBldr,
-		logger: log.NewTMLogger(os.Stdout),
+// WithTransport replaces the cosmos client the data is sent and received
+// through, e.g. with a Ledger.
+func WithTransport(transport Transport) OnChainOption {
+	return func(m *OnChainDKG) { m.transport = transport }
+}
+
+func NewOnChainDKG(cli *context.Context, txBldr *authtxb.TxBuilder, options ...OnChainOption) *OnChainDKG {
+	m := &OnChainDKG{
+		logger:          log.NewTMLogger(os.Stdout),
+		thresholdPolicy: blsShare.DefaultThresholdPolicy,
+		pairingSuite:    blsShare.BN256,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if m.transport == nil {
+		m.transport = NewCosmosTransport(cli, txBldr, m.logger)
 	}
+
+	return m
+}
+
+// PairingSuite returns the curve the keys are generated on.
+func (m *OnChainDKG) PairingSuite() *blsShare.Suite {
+	return m.pairingSuite
 }
 
 func (m *OnChainDKG) GetVerifier() (types.Verifier, error) {
 	return m.dealer.GetVerifier()
 }
 
+// ProcessBlock feeds the DKG messages received since the previous call to
+// the dealer, then tells it about the new block, so that a phase with
+// missing messages times out.
 func (m *OnChainDKG) ProcessBlock(roundID int) (error, bool) {
-	for _, dataType := range []alias.DKGDataType{
-		alias.DKGPubKey,
-		alias.DKGCommits,
-		alias.DKGDeal,
-		alias.DKGResponse,
-	} {
-		messages, err := m.getDKGMessages(dataType, roundID)
-		if err != nil {
-			return fmt.Errorf("failed to getDKGMessages: %v", err), false
-		}
-		var handler func(msg *alias.DKGData) error
-		switch dataType {
-		case alias.DKGPubKey:
-			handler = m.dealer.HandleDKGPubKey
-		case alias.DKGCommits:
-			handler = m.dealer.HandleDKGCommit
-		case alias.DKGDeal:
-			handler = m.dealer.HandleDKGDeal
-		case alias.DKGResponse:
-			handler = m.dealer.HandleDKGResponse
-		}
-		for _, msg := range messages {
-			if err := handler(msg.Data); err != nil {
-				return fmt.Errorf("failed to handle message: %v", err), false
-			}
+	messages, err := m.newMessages(roundID)
+	if err != nil {
+		return fmt.Errorf("failed to get new messages: %v", err), false
+	}
+	for _, msg := range messages {
+		if err := dealer.HandleMessage(m.dealer, msg); err != nil {
+			return fmt.Errorf("failed to handle message: %v", err), false
 		}
 	}
+	m.blocks++
+	if err := m.dealer.NewBlock(m.blocks); err != nil {
+		return fmt.Errorf("phase timeout: %v", err), false
+	}
 
 	if _, err := m.dealer.GetVerifier(); err == types.ErrDKGVerifierNotReady {
 		return nil, false
@@ -86,7 +126,24 @@ func (m *OnChainDKG) StartRound(
 	eventFirer events.Fireable,
 	logger log.Logger,
 	startRound int) error {
+	m.pv = pv
+	m.transport.Reset()
+
 	m.dealer = dealer.NewOnChainDKGDealer(validators, pv, m.sendMsg, eventFirer, logger, startRound)
+	m.dealer.SetThresholdPolicy(m.thresholdPolicy)
+	m.dealer.SetSuite(m.pairingSuite)
+	m.dealer.SetPhaseTimeouts(m.phaseTimeouts)
+	if m.dealerStore != nil {
+		snapshot, err := m.dealerStore.Load(startRound)
+		if err != nil {
+			return fmt.Errorf("failed to load round %d: %v", startRound, err)
+		}
+		if snapshot != nil {
+			m.logger.Info("Resume on-chain dkg", "round", startRound, "messages", len(snapshot.Messages))
+			return dealer.Resume(m.dealer, m.dealerStore, snapshot, m.logger)
+		}
+		m.dealer.SetStore(m.dealerStore)
+	}
 	if err := m.dealer.Start(); err != nil {
 		m.logger.Debug("Start on-chain dkg")
 		return fmt.Errorf("failed to start dealer: %v", err)
@@ -99,68 +156,74 @@ func (m *OnChainDKG) GetLosers() []*tmtypes.Validator {
 	return m.dealer.GetLosers()
 }
 
+func (m *OnChainDKG) GetLoserProofs() []*types.LoserProof {
+	return m.dealer.GetLoserProofs()
+}
+
+// GetEvidence returns the equivocations detected in the current round.
+func (m *OnChainDKG) GetEvidence() []*types.EquivocationEvidence {
+	return m.dealer.GetEvidence()
+}
+
 func (m *OnChainDKG) sendMsg(data []*alias.DKGData) error {
-	var messages []sdk.Msg
 	for _, item := range data {
-		item := item
-		msg := msgs.NewMsgSendDKGData(item, m.cli.GetFromAddress())
-		if err := msg.ValidateBasic(); err != nil {
-			return fmt.Errorf("failed to validate basic: %v", err)
+		// The messages are signed by the validator, so that evidence of
+		// equivocation can be verified by anyone.
+		if err := m.pv.SignData(m.transport.ChainID(), item); err != nil {
+			return fmt.Errorf("failed to sign data: %v", err)
 		}
-		messages = append(messages, msg)
 	}
 
-	kb, err := keys.NewKeyBaseFromDir(m.cli.Home)
-	if err != nil {
-		m.logger.Error("on-chain DKG send msg error", "function", "NewKeyBaseFromDir", "error", err)
-		return err
-	}
-	keysList, err := kb.List()
-	if err != nil {
-		m.logger.Error("on-chain DKG send msg error", "function", "List", "error", err)
-		return err
-	}
-	if len(keysList) == 0 {
-		err := fmt.Errorf("key list error: account does not exist")
-		m.logger.Error("on-chain DKG send msg error", "error", err)
-		return err
-	}
+	return m.transport.Broadcast(data)
+}
 
-	accRetriever := authTypes.NewAccountRetriever(m.cli)
-	_, accSequence, err := accRetriever.GetAccountNumberSequence(keysList[0].GetAddress())
-	if err != nil {
-		m.logger.Error("on-chain DKG send msg error", "function", "GetAccountNumberSequence", "error", err)
-		return err
+// newMessages returns the DKG messages of the round that have not been
+// handled yet. Messages of future rounds are kept until their round starts.
+func (m *OnChainDKG) newMessages(roundID int) ([]*alias.DKGData, error) {
+	messages, err := m.transport.Messages(roundID)
+
+	var out, future []*alias.DKGData
+	for _, data := range append(m.pending, messages...) {
+		switch {
+		case data.RoundID == roundID:
+			out = append(out, data)
+		case data.RoundID > roundID:
+			future = append(future, data)
+		}
 	}
+	m.pending = future
 
-	tmpTxBldr := m.txBldr.WithSequence(accSequence)
-	m.txBldr = &tmpTxBldr
-
-	err = utils.GenerateOrBroadcastMsgs(*m.cli, *m.txBldr, messages, false)
-	if err != nil {
-		return fmt.Errorf("failed to broadcast msg: %v", err)
-	}
+	return out, err
+}
 
+func (m *OnChainDKG) StartDKGRound(validators *tmtypes.ValidatorSet) error {
 	return nil
 }
 
-func (m *OnChainDKG) getDKGMessages(dataType alias.DKGDataType, roundID int) ([]*msgs.MsgSendDKGData, error) {
-	res, _, err := m.cli.QueryWithData(fmt.Sprintf("custom/randapp/dkgData/%d/%d", dataType, roundID), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query for DKG data: %v", err)
-	}
+// StartReshareRound is not supported on chain: resharing needs the old share
+// holders to deal, which on-chain rounds do not know about.
+func (m *OnChainDKG) StartReshareRound(oldValidators, validators *tmtypes.ValidatorSet, masterPubKey *share.PubPoly) error {
+	return errors.New("resharing is not supported by on-chain DKG")
+}
 
-	var data []*msgs.MsgSendDKGData
-	var dec = gob.NewDecoder(bytes.NewBuffer(res))
-	if err := dec.Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode DKG data: %v", err)
-	}
+// ScheduleReshareRound is not supported on chain, see StartReshareRound.
+func (m *OnChainDKG) ScheduleReshareRound(oldValidators, validators *tmtypes.ValidatorSet, masterPubKey *share.PubPoly) error {
+	return errors.New("resharing is not supported by on-chain DKG")
+}
 
-	return data, nil
+// StartRefreshRound is not supported on chain, see StartReshareRound.
+func (m *OnChainDKG) StartRefreshRound(validators *tmtypes.ValidatorSet) error {
+	return errors.New("refresh is not supported by on-chain DKG")
 }
 
-func (m *OnChainDKG) StartDKGRound(validators *tmtypes.ValidatorSet) error {
-	return nil
+// ScheduleRefreshRound is not supported on chain, see StartReshareRound.
+func (m *OnChainDKG) ScheduleRefreshRound(validators *tmtypes.ValidatorSet) error {
+	return errors.New("refresh is not supported by on-chain DKG")
+}
+
+// Stop releases the transport, e.g. its event subscription.
+func (m *OnChainDKG) Stop() {
+	m.transport.Close()
 }
 
 func (m *OnChainDKG) IsOnChain() bool {
@@ -0,0 +1,215 @@
+package onChain
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/msgs"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+const (
+	dkgSubscriber    = "dkglib-onchain"
+	dkgTxQuery       = "message.action='send_dkg_data'"
+	dkgEventsQuery   = "tm.event='Tx' AND " + dkgTxQuery
+	subscribeTimeout = 5 * time.Second
+	eventsCapacity   = 1000
+	txSearchPerPage  = 100
+)
+
+// subscribe subscribes to the transactions carrying DKG data. If it fails,
+// the node polls for new transactions instead.
+func (m *cosmosTransport) subscribe() {
+	if m.events != nil || m.cli == nil || m.cli.Client == nil {
+		return
+	}
+	if !m.cli.Client.IsRunning() {
+		if err := m.cli.Client.Start(); err != nil {
+			m.logger.Info("on-chain DKG: failed to start RPC client, polling for DKG data", "error", err)
+			return
+		}
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), subscribeTimeout)
+	defer cancel()
+	events, err := m.cli.Client.Subscribe(ctx, dkgSubscriber, dkgEventsQuery, eventsCapacity)
+	if err != nil {
+		m.logger.Info("on-chain DKG: failed to subscribe to DKG data, polling instead", "error", err)
+		return
+	}
+	m.events = events
+}
+
+// unsubscribe drops the event subscription, if any.
+func (m *cosmosTransport) unsubscribe() {
+	if m.events == nil {
+		return
+	}
+	m.events = nil
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), subscribeTimeout)
+	defer cancel()
+	if err := m.cli.Client.Unsubscribe(ctx, dkgSubscriber, dkgEventsQuery); err != nil {
+		m.logger.Info("on-chain DKG: failed to unsubscribe from DKG data", "error", err)
+	}
+}
+
+func (m *cosmosTransport) Close() {
+	m.unsubscribe()
+}
+
+// Messages returns the DKG data committed since the previous call. The
+// transactions are taken from the event subscription or searched for; if
+// neither works, the data of the round is queried.
+func (m *cosmosTransport) Messages(roundID int) ([]*alias.DKGData, error) {
+	var err error
+	if m.events == nil || !m.drainEvents() {
+		if err = m.pollTxs(); err != nil {
+			m.logger.Info("on-chain DKG: tx search failed, querying all DKG data", "error", err)
+			err = m.queryMessages(roundID)
+		}
+	}
+
+	out := m.pending
+	m.pending = nil
+
+	return out, err
+}
+
+// drainEvents takes all the events received since the last call. The RPC
+// client drops the events that do not fit in a full channel, so a full
+// channel means that transactions may have been missed: drainEvents then
+// subscribes anew, rewinds the height cursor to the first height that may
+// have been missed and returns false for the caller to search for them.
+func (m *cosmosTransport) drainEvents() bool {
+	from := m.lastHeight
+	overflow := len(m.events) == cap(m.events)
+drain:
+	for {
+		select {
+		case event, ok := <-m.events:
+			if !ok {
+				overflow = true
+				break drain
+			}
+			if txEvent, ok := event.Data.(tmtypes.EventDataTx); ok {
+				m.addTx(txEvent.Height, txEvent.Tx, txEvent.Result.Code)
+			}
+		default:
+			break drain
+		}
+	}
+	if !overflow {
+		return true
+	}
+
+	m.logger.Info("on-chain DKG: DKG data events may have been dropped, resubscribing", "height", from)
+	m.unsubscribe()
+	m.subscribe()
+	// Transactions of the last height seen may have been dropped as well;
+	// the ones already handled are skipped.
+	if from > 0 {
+		from--
+	}
+	m.lastHeight = from
+	return false
+}
+
+// pollTxs fetches the transactions committed after the height cursor.
+func (m *cosmosTransport) pollTxs() error {
+	if m.cli.Client == nil {
+		return errors.New("no RPC client")
+	}
+	query := fmt.Sprintf("%s AND tx.height>%d", dkgTxQuery, m.lastHeight)
+	for page := 1; ; page++ {
+		res, err := m.cli.Client.TxSearch(query, false, page, txSearchPerPage)
+		if err != nil {
+			return err
+		}
+		for _, tx := range res.Txs {
+			m.addTx(tx.Height, tx.Tx, tx.TxResult.Code)
+		}
+		if len(res.Txs) == 0 || page*txSearchPerPage >= res.TotalCount {
+			return nil
+		}
+	}
+}
+
+// addTx extracts DKG data from a successfully delivered transaction.
+func (m *cosmosTransport) addTx(height int64, tx tmtypes.Tx, code uint32) {
+	if height > m.lastHeight {
+		m.lastHeight = height
+	}
+	if code != 0 {
+		return
+	}
+	decoded, err := authTypes.DefaultTxDecoder(m.cli.Codec)(tx)
+	if err != nil {
+		m.logger.Info("on-chain DKG: failed to decode tx", "height", height, "error", err)
+		return
+	}
+	for idx, msg := range decoded.GetMsgs() {
+		dkgMsg, ok := msg.(msgs.MsgSendDKGData)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%X/%d", tx.Hash(), idx)
+		if m.seen[key] {
+			continue
+		}
+		m.seen[key] = true
+		if err := dkgMsg.ValidateBasic(); err != nil {
+			continue
+		}
+		m.pending = append(m.pending, dkgMsg.Data)
+	}
+}
+
+// queryMessages is the last resort for nodes that do not index transactions:
+// it fetches all the data of the round and keeps the messages not seen before.
+func (m *cosmosTransport) queryMessages(roundID int) error {
+	for _, dataType := range []alias.DKGDataType{
+		alias.DKGPubKey,
+		alias.DKGCommits,
+		alias.DKGDeal,
+		alias.DKGResponse,
+	} {
+		messages, err := m.getDKGMessages(dataType, roundID)
+		if err != nil {
+			return fmt.Errorf("failed to getDKGMessages: %v", err)
+		}
+		// The data of a round is append-only, so everything past the offset
+		// is new.
+		offset := m.queryOffsets[dataType]
+		if offset > len(messages) {
+			offset = len(messages)
+		}
+		for _, msg := range messages[offset:] {
+			if msg.Data != nil {
+				m.pending = append(m.pending, msg.Data)
+			}
+		}
+		m.queryOffsets[dataType] = len(messages)
+	}
+	return nil
+}
+
+func (m *cosmosTransport) getDKGMessages(dataType alias.DKGDataType, roundID int) ([]*msgs.MsgSendDKGData, error) {
+	res, _, err := m.cli.QueryWithData(fmt.Sprintf("custom/randapp/dkgData/%d/%d", dataType, roundID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for DKG data: %v", err)
+	}
+
+	var data []*msgs.MsgSendDKGData
+	var dec = gob.NewDecoder(bytes.NewBuffer(res))
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode DKG data: %v", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package onChain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/msgs"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const dkgDataQueryPath = "custom/randapp/dkgData"
+
+// Ledger is an in-memory stand-in for RandApp: it orders the DKG data sent
+// by the nodes into blocks and answers the DKG data queries. It lets the
+// on-chain DKG run without a node, e.g. in tests.
+type Ledger struct {
+	mtx     sync.Mutex
+	chainID string
+	mempool [][]msgs.MsgSendDKGData // Transactions not committed yet.
+	blocks  [][]msgs.MsgSendDKGData // The block at height h is blocks[h-1].
+	data    map[string][]*msgs.MsgSendDKGData
+}
+
+func NewLedger(chainID string) *Ledger {
+	return &Ledger{
+		chainID: chainID,
+		data:    make(map[string][]*msgs.MsgSendDKGData),
+	}
+}
+
+// Commit puts the transactions received since the previous commit into a new
+// block, in the order received, and returns its height.
+func (l *Ledger) Commit() int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	var block []msgs.MsgSendDKGData
+	for _, tx := range l.mempool {
+		for _, msg := range tx {
+			block = append(block, msg)
+			key := dataKey(msg.Data.Type, msg.Data.RoundID)
+			msg := msg
+			l.data[key] = append(l.data[key], &msg)
+		}
+	}
+	l.mempool = nil
+	l.blocks = append(l.blocks, block)
+
+	return int64(len(l.blocks))
+}
+
+func (l *Ledger) Height() int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return int64(len(l.blocks))
+}
+
+// QueryDKGData returns the committed data of a type sent in the round.
+func (l *Ledger) QueryDKGData(dataType alias.DKGDataType, roundID int) []*msgs.MsgSendDKGData {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return append([]*msgs.MsgSendDKGData(nil), l.data[dataKey(dataType, roundID)]...)
+}
+
+// Query answers custom/randapp/dkgData/<type>/<round> the way RandApp does,
+// with the gob-encoded data.
+func (l *Ledger) Query(path string, _ []byte) ([]byte, int64, error) {
+	var dataType alias.DKGDataType
+	var roundID int
+	if !strings.HasPrefix(path, dkgDataQueryPath+"/") {
+		return nil, 0, fmt.Errorf("unknown query path %s", path)
+	}
+	if _, err := fmt.Sscanf(path[len(dkgDataQueryPath)+1:], "%d/%d", &dataType, &roundID); err != nil {
+		return nil, 0, fmt.Errorf("invalid query path %s: %v", path, err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(l.QueryDKGData(dataType, roundID)); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode DKG data: %v", err)
+	}
+
+	return buf.Bytes(), l.Height(), nil
+}
+
+// Transport returns a transport that sends the data on behalf of the owner.
+func (l *Ledger) Transport(owner sdk.AccAddress) Transport {
+	return &ledgerTransport{ledger: l, owner: owner}
+}
+
+func (l *Ledger) submit(tx []msgs.MsgSendDKGData) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.mempool = append(l.mempool, tx)
+}
+
+// since returns the data of the blocks after the height and the current
+// height.
+func (l *Ledger) since(height int64) ([]*alias.DKGData, int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	var out []*alias.DKGData
+	for _, block := range l.blocks[height:] {
+		for _, msg := range block {
+			out = append(out, msg.Data)
+		}
+	}
+
+	return out, int64(len(l.blocks))
+}
+
+func dataKey(dataType alias.DKGDataType, roundID int) string {
+	return fmt.Sprintf("%d/%d", dataType, roundID)
+}
+
+type ledgerTransport struct {
+	ledger     *Ledger
+	owner      sdk.AccAddress
+	lastHeight int64
+}
+
+func (t *ledgerTransport) ChainID() string {
+	return t.ledger.chainID
+}
+
+// Broadcast submits the data as a single transaction, which is rejected as a
+// whole if any message is invalid.
+func (t *ledgerTransport) Broadcast(data []*alias.DKGData) error {
+	var tx []msgs.MsgSendDKGData
+	for _, item := range data {
+		msg := msgs.NewMsgSendDKGData(item, t.owner)
+		if err := msg.ValidateBasic(); err != nil {
+			return fmt.Errorf("failed to validate basic: %v", err)
+		}
+		tx = append(tx, msg)
+	}
+	t.ledger.submit(tx)
+
+	return nil
+}
+
+func (t *ledgerTransport) Reset() {}
+
+func (t *ledgerTransport) Close() {}
+
+func (t *ledgerTransport) Messages(roundID int) ([]*alias.DKGData, error) {
+	data, height := t.ledger.since(t.lastHeight)
+	t.lastHeight = height
+	return data, nil
+}
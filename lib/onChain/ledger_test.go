@@ -0,0 +1,132 @@
+package onChain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/msgs"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	tmalias "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+// runLedgerRound runs an on-chain round of n validators over the ledger, one
+// ProcessBlock per node after every commit, and returns the nodes.
+func runLedgerRound(t *testing.T, l *Ledger, n, roundID, maxBlocks int) []*OnChainDKG {
+	t.Helper()
+	var pvs []types.PrivValidator
+	var vals []*types.Validator
+	for i := 0; i < n; i++ {
+		pv := types.NewMockPV()
+		pvs = append(pvs, pv)
+		vals = append(vals, types.NewValidator(pv.GetPubKey(), 1))
+	}
+	validators := tmalias.NewValidatorSet(vals)
+
+	var nodes []*OnChainDKG
+	for _, pv := range pvs {
+		m := NewOnChainDKG(nil, nil, WithTransport(l.Transport(sdk.AccAddress(pv.GetPubKey().Address()))))
+		m.logger = log.NewNopLogger()
+		if err := m.StartRound(validators, pv, events.NewEventSwitch(), log.NewNopLogger(), roundID); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, m)
+	}
+
+	done := make([]bool, n)
+	for h := 0; h < maxBlocks; h++ {
+		l.Commit()
+		finished := true
+		for i, m := range nodes {
+			if done[i] {
+				continue
+			}
+			err, ok := m.ProcessBlock(roundID)
+			if err != nil {
+				t.Fatalf("node %d at height %d: %v", i, l.Height(), err)
+			}
+			done[i] = ok
+			finished = finished && ok
+		}
+		if finished {
+			return nodes
+		}
+	}
+	t.Fatalf("the round is not over after %d blocks", maxBlocks)
+	return nil
+}
+
+func TestLedgerRound(t *testing.T) {
+	const n, roundID = 4, 1
+	l := NewLedger("test-chain")
+	nodes := runLedgerRound(t, l, n, roundID, 20)
+
+	var key string
+	for i, m := range nodes {
+		v, err := m.GetVerifier()
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		nodeKey, err := blsShare.DumpMasterPubKey(v.(*blsShare.BLSVerifier).MasterPubKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			key = nodeKey
+		} else if nodeKey != key {
+			t.Fatalf("node %d has another master key than node 0", i)
+		}
+	}
+
+	// The commits carry their position in ToIndex; they were rejected by
+	// ValidateBasic when only deals could have one.
+	commits := l.QueryDKGData(alias.DKGCommits, roundID)
+	if len(commits) == 0 {
+		t.Fatal("no commits on the ledger")
+	}
+	var positioned bool
+	for _, msg := range commits {
+		if err := msg.Data.ValidateBasic(); err != nil {
+			t.Fatal(err)
+		}
+		positioned = positioned || msg.Data.ToIndex != 0
+	}
+	if !positioned {
+		t.Fatal("no commit has a position")
+	}
+}
+
+func TestLedgerQuery(t *testing.T) {
+	const n, roundID = 4, 1
+	l := NewLedger("test-chain")
+	runLedgerRound(t, l, n, roundID, 20)
+
+	res, height, err := l.Query(dkgDataQueryPath+"/0/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != l.Height() {
+		t.Fatalf("height %d, expected %d", height, l.Height())
+	}
+	var data []*msgs.MsgSendDKGData
+	if err := gob.NewDecoder(bytes.NewBuffer(res)).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != n {
+		t.Fatalf("%d public keys, expected %d", len(data), n)
+	}
+	for _, msg := range data {
+		if msg.Data.Type != alias.DKGPubKey || msg.Data.RoundID != roundID {
+			t.Fatalf("unexpected data: type %d, round %d", msg.Data.Type, msg.Data.RoundID)
+		}
+	}
+
+	if _, _, err := l.Query("custom/randapp/other", nil); err == nil {
+		t.Fatal("an unknown path was answered")
+	}
+}
@@ -0,0 +1,108 @@
+package onChain
+
+import (
+	"fmt"
+
+	authtxb "github.com/corestario/cosmos-utils/client/authtypes"
+	"github.com/corestario/cosmos-utils/client/context"
+	"github.com/corestario/cosmos-utils/client/utils"
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/msgs"
+	"github.com/cosmos/cosmos-sdk/client/keys"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/tendermint/tendermint/libs/log"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// Transport carries the DKG data of an OnChainDKG to the chain and back.
+type Transport interface {
+	// ChainID is the chain the data is signed for.
+	ChainID() string
+	// Broadcast submits the signed data in a single transaction.
+	Broadcast(data []*alias.DKGData) error
+	// Reset prepares the transport for a new round.
+	Reset()
+	// Messages returns the data committed since the previous call, in the
+	// order of the chain. The data of other rounds may be returned too.
+	Messages(roundID int) ([]*alias.DKGData, error)
+	// Close releases the subscriptions of the transport.
+	Close()
+}
+
+// cosmosTransport talks to a RandApp node through the cosmos client.
+type cosmosTransport struct {
+	cli    *context.Context
+	txBldr *authtxb.TxBuilder
+	logger log.Logger
+
+	events       <-chan ctypes.ResultEvent // Nil if polling is used.
+	lastHeight   int64                     // Height of the latest tx seen.
+	seen         map[string]bool           // Handled messages, by tx hash and index.
+	pending      []*alias.DKGData          // Messages not returned yet.
+	queryOffsets map[alias.DKGDataType]int // Used if txs are not indexed.
+}
+
+func NewCosmosTransport(cli *context.Context, txBldr *authtxb.TxBuilder, logger log.Logger) Transport {
+	return &cosmosTransport{
+		cli:          cli,
+		txBldr:       txBldr,
+		logger:       logger,
+		seen:         make(map[string]bool),
+		queryOffsets: make(map[alias.DKGDataType]int),
+	}
+}
+
+func (m *cosmosTransport) ChainID() string {
+	return m.txBldr.ChainID()
+}
+
+func (m *cosmosTransport) Reset() {
+	m.seen = make(map[string]bool)
+	m.queryOffsets = make(map[alias.DKGDataType]int)
+	m.subscribe()
+}
+
+func (m *cosmosTransport) Broadcast(data []*alias.DKGData) error {
+	var messages []sdk.Msg
+	for _, item := range data {
+		msg := msgs.NewMsgSendDKGData(item, m.cli.GetFromAddress())
+		if err := msg.ValidateBasic(); err != nil {
+			return fmt.Errorf("failed to validate basic: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	kb, err := keys.NewKeyBaseFromDir(m.cli.Home)
+	if err != nil {
+		m.logger.Error("on-chain DKG send msg error", "function", "NewKeyBaseFromDir", "error", err)
+		return err
+	}
+	keysList, err := kb.List()
+	if err != nil {
+		m.logger.Error("on-chain DKG send msg error", "function", "List", "error", err)
+		return err
+	}
+	if len(keysList) == 0 {
+		err := fmt.Errorf("key list error: account does not exist")
+		m.logger.Error("on-chain DKG send msg error", "error", err)
+		return err
+	}
+
+	accRetriever := authTypes.NewAccountRetriever(m.cli)
+	_, accSequence, err := accRetriever.GetAccountNumberSequence(keysList[0].GetAddress())
+	if err != nil {
+		m.logger.Error("on-chain DKG send msg error", "function", "GetAccountNumberSequence", "error", err)
+		return err
+	}
+
+	tmpTxBldr := m.txBldr.WithSequence(accSequence)
+	m.txBldr = &tmpTxBldr
+
+	err = utils.GenerateOrBroadcastMsgs(*m.cli, *m.txBldr, messages, false)
+	if err != nil {
+		return fmt.Errorf("failed to broadcast msg: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package onChain
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/corestario/cosmos-utils/client/context"
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/msgs"
+	"github.com/corestario/dkglib/lib/wire"
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/tendermint/tendermint/libs/log"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+// fakeClient serves committed transactions to the cosmos transport. Its
+// subscription drops the events that do not fit, like the RPC clients do.
+type fakeClient struct {
+	rpcclient.Client
+
+	events       chan ctypes.ResultEvent
+	txs          []*ctypes.ResultTx
+	subscribed   int
+	unsubscribed int
+}
+
+func (c *fakeClient) IsRunning() bool { return true }
+
+func (c *fakeClient) Subscribe(_ gocontext.Context, _, _ string, outCapacity ...int) (<-chan ctypes.ResultEvent, error) {
+	c.subscribed++
+	c.events = make(chan ctypes.ResultEvent, outCapacity[0])
+	return c.events, nil
+}
+
+func (c *fakeClient) Unsubscribe(gocontext.Context, string, string) error {
+	c.unsubscribed++
+	c.events = nil
+	return nil
+}
+
+func (c *fakeClient) TxSearch(query string, _ bool, page, _ int) (*ctypes.ResultTxSearch, error) {
+	var height int64
+	if _, err := fmt.Sscanf(query[strings.LastIndex(query, ">")+1:], "%d", &height); err != nil {
+		return nil, err
+	}
+	res := &ctypes.ResultTxSearch{}
+	if page > 1 {
+		return res, nil
+	}
+	for _, tx := range c.txs {
+		if tx.Height > height {
+			res.Txs = append(res.Txs, tx)
+		}
+	}
+	res.TotalCount = len(res.Txs)
+	return res, nil
+}
+
+// commit adds a transaction carrying one public key at the given height.
+func (c *fakeClient) commit(t *testing.T, cdc *codec.Codec, height int64) {
+	t.Helper()
+	data, err := wire.EncodePoint(bn256.NewSuiteG2().Point().Pick(bn256.NewSuiteG2().RandomStream()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := types.NewMockPV().GetPubKey().Address()
+	msg := msgs.NewMsgSendDKGData(&alias.DKGData{Type: alias.DKGPubKey, Addr: owner, Data: data}, sdk.AccAddress(owner))
+	tx, err := authTypes.DefaultTxEncoder(cdc)(authTypes.NewStdTx([]sdk.Msg{msg}, authTypes.StdFee{}, nil, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.txs = append(c.txs, &ctypes.ResultTx{Height: height, Tx: tx})
+	if c.events == nil {
+		return
+	}
+	select {
+	case c.events <- ctypes.ResultEvent{Data: types.EventDataTx{TxResult: types.TxResult{Height: height, Tx: tx}}}:
+	default:
+	}
+}
+
+func newTestTransport() (*cosmosTransport, *fakeClient, *codec.Codec) {
+	cdc := codec.New()
+	sdk.RegisterCodec(cdc)
+	codec.RegisterCrypto(cdc)
+	authTypes.RegisterCodec(cdc)
+	cdc.RegisterConcrete(msgs.MsgSendDKGData{}, "dkglib/SendDKGData", nil)
+
+	client := &fakeClient{}
+	cli := (&context.Context{}).WithCodec(cdc).WithClient(client)
+	transport := NewCosmosTransport(cli, nil, log.NewNopLogger()).(*cosmosTransport)
+	return transport, client, cdc
+}
+
+func TestTransportEvents(t *testing.T) {
+	transport, client, cdc := newTestTransport()
+	transport.Reset()
+	for h := int64(1); h <= 3; h++ {
+		client.commit(t, cdc, h)
+	}
+	messages, err := transport.Messages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("%d messages, want 3", len(messages))
+	}
+
+	transport.Close()
+	if client.unsubscribed != 1 || transport.events != nil {
+		t.Fatal("the transport did not unsubscribe")
+	}
+}
+
+// Events dropped by a full subscription are searched for.
+func TestTransportOverflow(t *testing.T) {
+	transport, client, cdc := newTestTransport()
+	transport.Reset()
+	client.commit(t, cdc, 1)
+	if messages, err := transport.Messages(0); err != nil || len(messages) != 1 {
+		t.Fatalf("Messages() = %d messages, %v", len(messages), err)
+	}
+
+	n := eventsCapacity + 10
+	for i := 0; i < n; i++ {
+		client.commit(t, cdc, int64(2+i/10))
+	}
+	messages, err := transport.Messages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != n {
+		t.Fatalf("%d messages, want %d", len(messages), n)
+	}
+	if client.subscribed != 2 || transport.events == nil {
+		t.Fatal("the transport did not subscribe again")
+	}
+
+	// The new subscription works.
+	client.commit(t, cdc, 1000)
+	if messages, err := transport.Messages(0); err != nil || len(messages) != 1 {
+		t.Fatalf("Messages() = %d messages, %v", len(messages), err)
+	}
+}
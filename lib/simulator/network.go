@@ -0,0 +1,76 @@
+package simulator
+
+import (
+	"bytes"
+	"sort"
+
+	dkgalias "github.com/corestario/dkglib/lib/alias"
+)
+
+// NetworkConfig describes how the messages travel between the nodes. Delays
+// are counted in blocks; a message with zero delay is delivered in the block
+// it is sent in.
+type NetworkConfig struct {
+	DropRate float64 // Probability for a message to be lost on the way to a peer.
+	MinDelay int64
+	MaxDelay int64
+	Reorder  bool // Deliver the messages of a block in random order, not in the order sent.
+}
+
+type envelope struct {
+	msg       *dkgalias.DKGData
+	from, to  int
+	deliverAt int64
+	seq       uint64
+}
+
+// network keeps the messages in flight.
+type network struct {
+	cfg       NetworkConfig
+	inFlight  []*envelope
+	partition map[int]int // Node to group; nodes of different groups can't talk.
+	seq       uint64
+
+	sent, dropped, delivered int
+}
+
+func (n *network) connected(from, to int) bool {
+	if from == to || n.partition == nil {
+		return true
+	}
+	return n.partition[from] == n.partition[to]
+}
+
+// due removes and returns the messages to be delivered at the height.
+func (n *network) due(height int64) []*envelope {
+	var out, rest []*envelope
+	for _, env := range n.inFlight {
+		if env.deliverAt <= height {
+			out = append(out, env)
+		} else {
+			rest = append(rest, env)
+		}
+	}
+	n.inFlight = rest
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+
+	return out
+}
+
+// sortMessages puts the messages sent in a single call in a canonical order,
+// so that the order does not depend on map iteration inside the dealers.
+func sortMessages(msgs []*dkgalias.DKGData) {
+	sort.SliceStable(msgs, func(i, j int) bool {
+		a, b := msgs[i], msgs[j]
+		if a.RoundID != b.RoundID {
+			return a.RoundID < b.RoundID
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.ToIndex != b.ToIndex {
+			return a.ToIndex < b.ToIndex
+		}
+		return bytes.Compare(a.Data, b.Data) < 0
+	})
+}
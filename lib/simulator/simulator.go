@@ -0,0 +1,299 @@
+// Package simulator runs several off-chain DKG nodes in a single process over
+// a simulated network. All the randomness comes from a seed, so a scenario
+// always ends the same way and protocol regressions can be caught by unit
+// tests.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	dkgalias "github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/dealer"
+	"github.com/corestario/dkglib/lib/offChain"
+	dkgtypes "github.com/corestario/dkglib/lib/types"
+	"github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/libs/events"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+const chainID = "simulator"
+
+type Config struct {
+	Seed          int64
+	NumValidators int
+	DKGNumBlocks  int64 // How often CheckDKGTime starts a round; the off-chain default if zero.
+	Network       NetworkConfig
+	// Options are passed to every node. Phase timeouts must be given in
+	// blocks: wall-clock ones make the outcome depend on the host.
+	Options []offChain.DKGOption
+	// Dealers picks the dealer constructor of every node, e.g. to make some
+	// of them Byzantine (see dealer.NewDealerConstructor). Nil means honest
+	// dealers.
+	Dealers func(i int) dealer.DKGDealerConstructor
+	Logger  log.Logger
+}
+
+type Node struct {
+	Index int
+	PV    *types.MockPV
+	DKG   *offChain.OffChainDKG
+
+	evsw   events.EventSwitch
+	outbox []*dkgalias.DKGData
+	errs   []*NodeError
+}
+
+// NodeError is an error a node returned from CheckDKGTime or
+// HandleOffChainShare.
+type NodeError struct {
+	Node   int
+	Height int64
+	Err    error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("node %d at height %d: %v", e.Node, e.Height, e.Err)
+}
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
+// Errors returns the errors the node has returned so far, oldest first.
+func (n *Node) Errors() []*NodeError { return n.errs }
+
+func (n *Node) recordError(height int64, err error) {
+	if err != nil {
+		n.errs = append(n.errs, &NodeError{Node: n.Index, Height: height, Err: err})
+	}
+}
+
+type Simulator struct {
+	nodes      []*Node
+	validators *alias.ValidatorSet
+	rand       *rand.Rand
+	net        *network
+	height     int64
+}
+
+func New(cfg Config) (*Simulator, error) {
+	if cfg.NumValidators < 1 {
+		return nil, errors.New("simulator: at least one validator is needed")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.NewNopLogger()
+	}
+	s := &Simulator{
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+		net:  &network{cfg: cfg.Network},
+	}
+
+	var validators []*types.Validator
+	for i := 0; i < cfg.NumValidators; i++ {
+		secret := []byte(fmt.Sprintf("%d/%d", cfg.Seed, i))
+		pv := types.NewMockPVWithParams(ed25519.GenPrivKeyFromSecret(secret), false, false)
+		validators = append(validators, types.NewValidator(pv.GetPubKey(), 1))
+
+		node := &Node{Index: i, PV: pv, evsw: events.NewEventSwitch()}
+		if err := node.evsw.Start(); err != nil {
+			return nil, fmt.Errorf("simulator: failed to start event switch: %v", err)
+		}
+		err := node.evsw.AddListenerForEvent("simulator", dkgtypes.EventDKGData, func(data events.EventData) {
+			if msg, ok := data.(*dkgalias.DKGData); ok {
+				node.outbox = append(node.outbox, msg)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("simulator: failed to add listener: %v", err)
+		}
+
+		options := []offChain.DKGOption{
+			offChain.WithLogger(cfg.Logger.With("node", i)),
+			offChain.WithPVKey(pv),
+			offChain.WithDKGNumBlocks(cfg.DKGNumBlocks),
+			offChain.WithDealerSeed(secret),
+		}
+		options = append(options, cfg.Options...)
+		if cfg.Dealers != nil {
+			options = append(options, offChain.WithDKGDealerConstructor(cfg.Dealers(i)))
+		}
+		node.DKG = offChain.NewOffChainDKG(node.evsw, chainID, options...)
+		s.nodes = append(s.nodes, node)
+	}
+	s.validators = alias.NewValidatorSet(validators)
+
+	return s, nil
+}
+
+func (s *Simulator) Nodes() []*Node                  { return s.nodes }
+func (s *Simulator) Validators() *alias.ValidatorSet { return s.validators }
+func (s *Simulator) Height() int64                   { return s.height }
+
+// Verifiers returns the active verifier of every node.
+func (s *Simulator) Verifiers() []dkgtypes.Verifier {
+	var out []dkgtypes.Verifier
+	for _, node := range s.nodes {
+		out = append(out, node.DKG.Verifier())
+	}
+	return out
+}
+
+// Errors returns the errors of all the nodes, by node.
+func (s *Simulator) Errors() []*NodeError {
+	var out []*NodeError
+	for _, node := range s.nodes {
+		out = append(out, node.errs...)
+	}
+	return out
+}
+
+// Stats returns the number of messages sent, lost and delivered so far.
+func (s *Simulator) Stats() (sent, dropped, delivered int) {
+	return s.net.sent, s.net.dropped, s.net.delivered
+}
+
+func (s *Simulator) SetNetwork(cfg NetworkConfig) {
+	s.net.cfg = cfg
+}
+
+// Partition splits the nodes into groups that can't reach each other; the
+// nodes not listed make a group of their own. Messages sent across groups
+// are lost.
+func (s *Simulator) Partition(groups ...[]int) {
+	s.net.partition = make(map[int]int)
+	for i := range s.nodes {
+		s.net.partition[i] = -1
+	}
+	for g, group := range groups {
+		for _, i := range group {
+			s.net.partition[i] = g
+		}
+	}
+}
+
+func (s *Simulator) Heal() {
+	s.net.partition = nil
+}
+
+// StartRound makes every node start a new DKG round with the validators.
+func (s *Simulator) StartRound() error {
+	for _, node := range s.nodes {
+		if err := node.DKG.StartDKGRound(s.validators); err != nil {
+			return fmt.Errorf("simulator: node %d failed to start round: %v", node.Index, err)
+		}
+		s.flush(node)
+	}
+	s.deliver()
+
+	return nil
+}
+
+// Step produces the next block: every node is notified of the height, then
+// the messages due are delivered. The errors the nodes return are recorded,
+// see Errors.
+func (s *Simulator) Step() {
+	s.height++
+	for _, node := range s.nodes {
+		node.recordError(s.height, node.DKG.CheckDKGTime(s.height, s.validators))
+		s.flush(node)
+	}
+	s.deliver()
+}
+
+func (s *Simulator) Run(blocks int) {
+	for i := 0; i < blocks; i++ {
+		s.Step()
+	}
+}
+
+// RunUntil steps until cond holds, for at most maxBlocks blocks, and reports
+// whether cond holds.
+func (s *Simulator) RunUntil(cond func(*Simulator) bool, maxBlocks int) bool {
+	for i := 0; i < maxBlocks; i++ {
+		if cond(s) {
+			return true
+		}
+		s.Step()
+	}
+	return cond(s)
+}
+
+// Stop releases the event switches of the nodes.
+func (s *Simulator) Stop() {
+	for _, node := range s.nodes {
+		node.evsw.Stop()
+	}
+}
+
+// flush routes the messages the node has sent since the previous call.
+func (s *Simulator) flush(node *Node) {
+	// The node's own copies are delivered through the network like the
+	// others, the queue is only drained.
+	for drained := false; !drained; {
+		select {
+		case <-node.DKG.MsgQueue():
+		default:
+			drained = true
+		}
+	}
+
+	msgs := node.outbox
+	node.outbox = nil
+	sortMessages(msgs)
+	for _, msg := range msgs {
+		for _, to := range s.nodes {
+			s.send(msg, node.Index, to.Index)
+		}
+	}
+}
+
+func (s *Simulator) send(msg *dkgalias.DKGData, from, to int) {
+	s.net.sent++
+	cfg := s.net.cfg
+	delay := int64(0)
+	if from != to {
+		if cfg.DropRate > 0 && s.rand.Float64() < cfg.DropRate {
+			s.net.dropped++
+			return
+		}
+		delay = cfg.MinDelay
+		if cfg.MaxDelay > cfg.MinDelay {
+			delay += s.rand.Int63n(cfg.MaxDelay - cfg.MinDelay + 1)
+		}
+	}
+	s.net.seq++
+	s.net.inFlight = append(s.net.inFlight, &envelope{
+		msg:       msg,
+		from:      from,
+		to:        to,
+		deliverAt: s.height + delay,
+		seq:       s.net.seq,
+	})
+}
+
+// deliver hands the messages due to their receivers, including the ones sent
+// in response with zero delay.
+func (s *Simulator) deliver() {
+	for {
+		envs := s.net.due(s.height)
+		if len(envs) == 0 {
+			return
+		}
+		if s.net.cfg.Reorder {
+			s.rand.Shuffle(len(envs), func(i, j int) { envs[i], envs[j] = envs[j], envs[i] })
+		}
+		for _, env := range envs {
+			if !s.net.connected(env.from, env.to) {
+				s.net.dropped++
+				continue
+			}
+			s.net.delivered++
+			node := s.nodes[env.to]
+			_, err := node.DKG.HandleOffChainShare(&dkgtypes.DKGDataMessage{Data: env.msg}, s.height, s.validators, nil)
+			node.recordError(s.height, err)
+			s.flush(node)
+		}
+	}
+}
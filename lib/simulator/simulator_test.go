@@ -0,0 +1,219 @@
+package simulator
+
+import (
+	"testing"
+
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/dealer"
+	"github.com/corestario/dkglib/lib/offChain"
+)
+
+// newSimulator returns a simulator of 4 validators unless cfg says
+// otherwise; the caller stops it.
+func newSimulator(t *testing.T, cfg Config) *Simulator {
+	t.Helper()
+	if cfg.NumValidators == 0 {
+		cfg.NumValidators = 4
+	}
+	if cfg.DKGNumBlocks == 0 {
+		cfg.DKGNumBlocks = 1000
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// startRound starts the round; messages with no delay are exchanged right
+// away, so a healthy round may be over once it returns.
+func startRound(t *testing.T, s *Simulator) {
+	t.Helper()
+	if err := s.StartRound(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// allReady is true once the listed nodes, all of them if none are listed,
+// have a verifier.
+func allReady(nodes ...int) func(*Simulator) bool {
+	return func(s *Simulator) bool {
+		verifiers := s.Verifiers()
+		if len(nodes) == 0 {
+			for i := range verifiers {
+				nodes = append(nodes, i)
+			}
+		}
+		for _, i := range nodes {
+			if verifiers[i] == nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// masterKeys returns the master public key of every node, empty for the
+// nodes without a verifier.
+func masterKeys(t *testing.T, s *Simulator) []string {
+	t.Helper()
+	var out []string
+	for _, v := range s.Verifiers() {
+		if v == nil {
+			out = append(out, "")
+			continue
+		}
+		key, err := blsShare.DumpMasterPubKey(v.(*blsShare.BLSVerifier).MasterPubKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+// sameKey checks that the listed nodes share a master key and returns it.
+func sameKey(t *testing.T, s *Simulator, nodes ...int) string {
+	t.Helper()
+	keys := masterKeys(t, s)
+	for _, i := range nodes[1:] {
+		if keys[i] != keys[nodes[0]] {
+			t.Fatalf("node %d has another master key than node %d", i, nodes[0])
+		}
+	}
+	return keys[nodes[0]]
+}
+
+// noErrors fails the test on the errors of the listed nodes, of all of them
+// if none are listed.
+func noErrors(t *testing.T, s *Simulator, nodes ...int) {
+	t.Helper()
+	if len(nodes) == 0 {
+		for _, err := range s.Errors() {
+			t.Error(err)
+		}
+		return
+	}
+	for _, i := range nodes {
+		for _, err := range s.Nodes()[i].Errors() {
+			t.Error(err)
+		}
+	}
+}
+
+func TestHappyPath(t *testing.T) {
+	run := func(seed int64) string {
+		s := newSimulator(t, Config{Seed: seed})
+		defer s.Stop()
+		startRound(t, s)
+		if !s.RunUntil(allReady(), 50) {
+			t.Fatalf("seed %d: no key after %d blocks", seed, s.Height())
+		}
+		noErrors(t, s)
+		if sent, dropped, delivered := s.Stats(); dropped != 0 || sent != delivered {
+			t.Fatalf("seed %d: sent %d, dropped %d, delivered %d", seed, sent, dropped, delivered)
+		}
+		return sameKey(t, s, 0, 1, 2, 3)
+	}
+
+	key := run(1)
+	if run(1) != key {
+		t.Fatal("the same seed gave another key")
+	}
+	if run(2) == key {
+		t.Fatal("another seed gave the same key")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	net := NetworkConfig{MaxDelay: 3, Reorder: true}
+	for seed := int64(1); seed <= 5; seed++ {
+		s := newSimulator(t, Config{Seed: seed, Network: net})
+		defer s.Stop()
+		startRound(t, s)
+		if !s.RunUntil(allReady(), 100) {
+			t.Fatalf("seed %d: no key after %d blocks", seed, s.Height())
+		}
+		noErrors(t, s)
+		sameKey(t, s, 0, 1, 2, 3)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	options := []offChain.DKGOption{
+		offChain.WithPhaseTimeouts(dealer.PhaseTimeouts{Blocks: 5}),
+		offChain.WithRetryPolicy(offChain.RetryPolicy{MaxRetries: 5, Backoff: 2}),
+	}
+
+	// A lost public key fails the round; a retry with no losses succeeds.
+	s := newSimulator(t, Config{Seed: 3, Network: NetworkConfig{DropRate: 0.02, MaxDelay: 1}, Options: options})
+	defer s.Stop()
+	startRound(t, s)
+	if !s.RunUntil(allReady(), 100) {
+		t.Fatalf("no key after %d blocks", s.Height())
+	}
+	if _, dropped, _ := s.Stats(); dropped == 0 {
+		t.Fatal("no message was lost")
+	}
+	sameKey(t, s, 0, 1, 2, 3)
+
+	// Under heavy losses the rounds keep failing, and every node says so.
+	s = newSimulator(t, Config{Seed: 1, Network: NetworkConfig{DropRate: 0.1, MaxDelay: 1}, Options: options})
+	defer s.Stop()
+	startRound(t, s)
+	s.Run(100)
+	for _, node := range s.Nodes() {
+		if len(node.Errors()) == 0 {
+			t.Errorf("node %d reported no error", node.Index)
+		}
+		if node.DKG.Verifier() != nil {
+			t.Errorf("node %d has a key", node.Index)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	s := newSimulator(t, Config{
+		Seed: 4,
+		Options: []offChain.DKGOption{
+			offChain.WithPhaseTimeouts(dealer.PhaseTimeouts{Blocks: 5}),
+			offChain.WithRetryPolicy(offChain.RetryPolicy{MaxRetries: 5, Backoff: 2}),
+		},
+	})
+	defer s.Stop()
+	// Node 3 is cut off from the start. Rather than run the round without
+	// it, which the node could not tell from the others, every node fails it.
+	s.Partition([]int{0, 1, 2})
+	startRound(t, s)
+	s.Run(10)
+	for _, node := range s.Nodes() {
+		if len(node.Errors()) == 0 {
+			t.Errorf("node %d reported no error", node.Index)
+		}
+		if node.DKG.Verifier() != nil {
+			t.Errorf("node %d has a key", node.Index)
+		}
+	}
+
+	// Once the partition heals, a retry brings in every node.
+	s.Heal()
+	if !s.RunUntil(allReady(), 100) {
+		t.Fatalf("no key after %d blocks", s.Height())
+	}
+	sameKey(t, s, 0, 1, 2, 3)
+}
+
+// Regression: a deal that arrived before the dealer had created its own
+// deals, i.e. before the dealer knew its index, was matched against index 0.
+// With random delays some nodes get all the public keys, and send their
+// deals, blocks before the others do.
+func TestEarlyDeals(t *testing.T) {
+	s := newSimulator(t, Config{Seed: 2, NumValidators: 5, Network: NetworkConfig{MinDelay: 0, MaxDelay: 4}})
+	defer s.Stop()
+	startRound(t, s)
+	if !s.RunUntil(allReady(), 100) {
+		t.Fatalf("no key after %d blocks", s.Height())
+	}
+	noErrors(t, s)
+	sameKey(t, s, 0, 1, 2, 3, 4)
+}
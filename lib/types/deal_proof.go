@@ -0,0 +1,351 @@
+package types
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/wire"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	vsspedersen "go.dedis.ch/kyber/v3/share/vss/pedersen"
+	vss "go.dedis.ch/kyber/v3/share/vss/rabin"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The deals are encrypted the way kyber's VSS implementations do it; the
+// helpers below mirror the unexported parts of go.dedis.ch/kyber/v3/share/vss.
+const sharedKeyLength = 32
+
+// sealedDeal is the part of an encrypted deal shared by both VSS schemes.
+type sealedDeal struct {
+	index     uint32
+	dhKey     []byte
+	signature []byte
+	nonce     []byte
+	cipher    []byte
+}
+
+// verifyDealProof checks a complaint about a deal: the complainer's
+// Diffie-Hellman key opens the deal, which is proven bad if it can not be
+// opened, fails the checks the complainer's VSS verifier runs or, for
+// LoserCommitsMismatch, carries commitments other than the ones the dealer
+// has published.
+func verifyDealProof(suite blsShare.Group, validators *tmtypes.ValidatorSet, proof *LoserProof) error {
+	msg := proof.Message
+	if err := verifyRoundMessage(validators, msg, proof.Addr, proof.RoundID); err != nil {
+		return err
+	}
+	if msg.Type != alias.DKGDeal {
+		return fmt.Errorf("message of type %d is not a deal", msg.Type)
+	}
+
+	addrs, verifiers, err := roundPubKeys(suite, validators, proof.PubKeys, proof.RoundID)
+	if err != nil {
+		return err
+	}
+	// A deal that can not be opened only proves the dealer wrong if it was
+	// sealed for all the validators: otherwise the subset may not be the one
+	// the dealer used.
+	complete := len(verifiers) == validators.Size()
+	dealerIdx, complainerIdx := indexOf(addrs, proof.Addr), indexOf(addrs, proof.Complainer)
+	if complainerIdx < 0 || complainerIdx == dealerIdx {
+		return fmt.Errorf("invalid complainer %s", proof.Complainer)
+	}
+	if msg.ToIndex != complainerIdx {
+		return errors.New("deal is not addressed to the complainer")
+	}
+	rev, err := wire.DecodeDHReveal(suite, proof.DHReveal)
+	if err != nil {
+		return fmt.Errorf("failed to decode DH reveal: %v", err)
+	}
+
+	var (
+		commits []kyber.Point
+		fault   error
+	)
+	_, kind, _ := wire.Header(msg.Data)
+	switch kind {
+	case wire.KindRabinDeal:
+		commits, fault, err = openRabinDeal(suite, msg.Data, verifiers, dealerIdx, complainerIdx, rev, complete)
+	case wire.KindPedersenDeal:
+		commits, fault, err = openPedersenDeal(suite, msg.Data, verifiers, dealerIdx, complainerIdx, rev, complete)
+	default:
+		fault = fmt.Errorf("unexpected deal kind %d", kind)
+	}
+	if err != nil {
+		return err
+	}
+	if fault != nil {
+		return nil
+	}
+
+	if proof.Reason == LoserCommitsMismatch {
+		return verifyCommitsMismatch(suite, validators, proof, commits)
+	}
+	return errors.New("deal is valid")
+}
+
+// roundPubKeys returns the addresses and the DKG public keys of the validators
+// the round was run with, sorted by address as the dealers do. After a phase
+// timeout that is the subset whose keys were received in time.
+func roundPubKeys(suite blsShare.Group, validators *tmtypes.ValidatorSet, msgs []*alias.DKGData, roundID int) ([]crypto.Address, []kyber.Point, error) {
+	if len(msgs) == 0 || len(msgs) > validators.Size() {
+		return nil, nil, fmt.Errorf("want public keys of at most %d validators, got %d", validators.Size(), len(msgs))
+	}
+	sorted := append([]*alias.DKGData(nil), msgs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].GetAddrString() < sorted[j].GetAddrString() })
+
+	var (
+		addrs = make([]crypto.Address, len(sorted))
+		keys  = make([]kyber.Point, len(sorted))
+	)
+	for i, msg := range sorted {
+		if msg == nil || msg.Type != alias.DKGPubKey {
+			return nil, nil, errors.New("public key message expected")
+		}
+		if i > 0 && bytes.Equal(msg.Addr, sorted[i-1].Addr) {
+			return nil, nil, fmt.Errorf("duplicate public key of %s", msg.GetAddrString())
+		}
+		if err := verifyRoundMessage(validators, msg, msg.Addr, roundID); err != nil {
+			return nil, nil, err
+		}
+		key, err := wire.DecodePoint(suite, msg.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode public key of %s: %v", msg.GetAddrString(), err)
+		}
+		addrs[i], keys[i] = crypto.Address(msg.Addr), key
+	}
+	return addrs, keys, nil
+}
+
+func indexOf(addrs []crypto.Address, addr crypto.Address) int {
+	for i := range addrs {
+		if bytes.Equal(addrs[i], addr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// openRabinDeal opens an off-chain deal. A non-nil fault is what is wrong
+// with the deal; a non-nil error means the proof itself is invalid.
+func openRabinDeal(suite blsShare.Group, data []byte, verifiers []kyber.Point, dealerIdx, complainerIdx int, rev *wire.DHReveal, complete bool) ([]kyber.Point, error, error) {
+	d, err := wire.DecodeDeal(suite, data)
+	if err != nil {
+		return nil, err, nil
+	}
+	if d.Deal == nil {
+		return nil, errors.New("deal is empty"), nil
+	}
+	dhKey, err := d.Deal.DHKey.MarshalBinary()
+	if err != nil {
+		return nil, err, nil
+	}
+	sealed := &sealedDeal{d.Index, dhKey, d.Deal.Signature, d.Deal.Nonce, d.Deal.Cipher}
+	plaintext, fault, err := openDeal(suite, sealed, verifiers, dealerIdx, complainerIdx, rev, complete, rabinContext)
+	if fault != nil || err != nil {
+		return nil, fault, err
+	}
+
+	deal := &vss.Deal{}
+	if err := decodeProtobuf(suite, plaintext, deal); err != nil {
+		return nil, err, nil
+	}
+	fi, gi := deal.SecShare, deal.RndShare
+	switch {
+	case fi == nil || gi == nil || fi.V == nil || gi.V == nil:
+		return nil, errors.New("deal has no shares"), nil
+	case fi.I != gi.I:
+		return nil, errors.New("not the same index for f and g share in deal"), nil
+	case fi.I != complainerIdx:
+		return nil, errors.New("deal is for another verifier"), nil
+	case !validT(int(deal.T), len(verifiers)):
+		return nil, errors.New("invalid t received in deal"), nil
+	}
+	ci := suite.Point().Add(suite.Point().Mul(fi.V, nil), suite.Point().Mul(gi.V, deriveH(suite, verifiers)))
+	if !ci.Equal(share.NewPubPoly(suite, nil, deal.Commitments).Eval(fi.I).V) {
+		return nil, errors.New("share does not verify against commitments in deal"), nil
+	}
+	return deal.Commitments, nil, nil
+}
+
+// openPedersenDeal is openRabinDeal for on-chain deals.
+func openPedersenDeal(suite blsShare.Group, data []byte, verifiers []kyber.Point, dealerIdx, complainerIdx int, rev *wire.DHReveal, complete bool) ([]kyber.Point, error, error) {
+	d, err := wire.DecodePedersenDeal(data)
+	if err != nil {
+		return nil, err, nil
+	}
+	if d.Deal == nil {
+		return nil, errors.New("deal is empty"), nil
+	}
+	sealed := &sealedDeal{d.Index, d.Deal.DHKey, d.Deal.Signature, d.Deal.Nonce, d.Deal.Cipher}
+	plaintext, fault, err := openDeal(suite, sealed, verifiers, dealerIdx, complainerIdx, rev, complete, pedersenContext)
+	if fault != nil || err != nil {
+		return nil, fault, err
+	}
+
+	deal := &vsspedersen.Deal{}
+	if err := decodeProtobuf(suite, plaintext, deal); err != nil {
+		return nil, err, nil
+	}
+	fi := deal.SecShare
+	switch {
+	case fi == nil || fi.V == nil:
+		return nil, errors.New("deal has no share"), nil
+	case fi.I != complainerIdx:
+		return nil, errors.New("deal is for another verifier"), nil
+	case !validT(int(deal.T), len(verifiers)):
+		return nil, errors.New("invalid t received in deal"), nil
+	}
+	fig := suite.Point().Mul(fi.V, nil)
+	if !fig.Equal(share.NewPubPoly(suite, nil, deal.Commitments).Eval(fi.I).V) {
+		return nil, errors.New("share does not verify against commitments in deal"), nil
+	}
+	return deal.Commitments, nil, nil
+}
+
+// openDeal decrypts the deal with the revealed key. Unless the verifiers are
+// complete, a deal that does not match them is not a fault of the dealer.
+func openDeal(
+	suite blsShare.Group,
+	sealed *sealedDeal,
+	verifiers []kyber.Point,
+	dealerIdx, complainerIdx int,
+	rev *wire.DHReveal,
+	complete bool,
+	context func(blsShare.Group, kyber.Point, []kyber.Point) []byte,
+) ([]byte, error, error) {
+	if int(sealed.index) != dealerIdx {
+		if !complete {
+			return nil, nil, errors.New("dealer index does not match the public keys given")
+		}
+		return nil, errors.New("deal has a wrong dealer index"), nil
+	}
+	dealer, complainer := verifiers[dealerIdx], verifiers[complainerIdx]
+	if err := schnorr.Verify(suite, dealer, sealed.dhKey, sealed.signature); err != nil {
+		return nil, fmt.Errorf("invalid signature of the DH key: %v", err), nil
+	}
+	dhKey := suite.Point()
+	if err := dhKey.UnmarshalBinary(sealed.dhKey); err != nil {
+		return nil, err, nil
+	}
+
+	// The revealed key must be the complainer's longterm key times the
+	// dealer's ephemeral one.
+	if err := rev.Proof.Verify(suite, suite.Point().Base(), dhKey, complainer, rev.Key); err != nil {
+		return nil, nil, fmt.Errorf("invalid DH reveal: %v", err)
+	}
+
+	hkdfContext := context(suite, dealer, verifiers)
+	gcm, err := newAEAD(suite, rev.Key, hkdfContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sealed.nonce) != gcm.NonceSize() {
+		return nil, errors.New("deal has a wrong nonce size"), nil
+	}
+	plaintext, err := gcm.Open(nil, sealed.nonce, sealed.cipher, hkdfContext)
+	if err != nil {
+		if !complete {
+			return nil, nil, fmt.Errorf("failed to decrypt deal with the public keys given: %v", err)
+		}
+		return nil, fmt.Errorf("failed to decrypt deal: %v", err), nil
+	}
+	return plaintext, nil, nil
+}
+
+// verifyCommitsMismatch checks that a commitment the dealer has published
+// differs from the one in its deal. Commitments are matched by ToIndex, so
+// that the proof can not be forged by leaving some of them out.
+func verifyCommitsMismatch(suite blsShare.Group, validators *tmtypes.ValidatorSet, proof *LoserProof, dealCommits []kyber.Point) error {
+	for _, msg := range proof.Commits {
+		if err := verifyRoundMessage(validators, msg, proof.Addr, proof.RoundID); err != nil {
+			return err
+		}
+		if msg.Type != alias.DKGCommits {
+			return fmt.Errorf("message of type %d is not a commit", msg.Type)
+		}
+		commit, err := wire.DecodePoint(suite, msg.Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode commit: %v", err)
+		}
+		if msg.ToIndex < 0 || msg.ToIndex >= len(dealCommits) || !dealCommits[msg.ToIndex].Equal(commit) {
+			return nil
+		}
+	}
+	return errors.New("published commits match the deal")
+}
+
+func newAEAD(suite blsShare.Group, preSharedKey kyber.Point, context []byte) (cipher.AEAD, error) {
+	preBuff, err := preSharedKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sharedKey := make([]byte, sharedKeyLength)
+	if _, err := hkdf.New(suite.Hash, preBuff, nil, context).Read(sharedKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sharedKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func rabinContext(suite blsShare.Group, dealer kyber.Point, verifiers []kyber.Point) []byte {
+	h := suite.XOF([]byte("vss-dealer"))
+	_, _ = dealer.MarshalTo(h)
+	_, _ = h.Write([]byte("vss-verifiers"))
+	for _, v := range verifiers {
+		_, _ = v.MarshalTo(h)
+	}
+	sum := make([]byte, 128)
+	_, _ = h.Read(sum)
+	return sum
+}
+
+func pedersenContext(suite blsShare.Group, dealer kyber.Point, verifiers []kyber.Point) []byte {
+	h := suite.Hash()
+	_, _ = h.Write([]byte("vss-dealer"))
+	_, _ = dealer.MarshalTo(h)
+	_, _ = h.Write([]byte("vss-verifiers"))
+	for _, v := range verifiers {
+		_, _ = v.MarshalTo(h)
+	}
+	return h.Sum(nil)
+}
+
+func deriveH(suite blsShare.Group, verifiers []kyber.Point) kyber.Point {
+	var b bytes.Buffer
+	for _, v := range verifiers {
+		_, _ = v.MarshalTo(&b)
+	}
+	return suite.Point().Pick(suite.XOF(b.Bytes()))
+}
+
+func validT(t, n int) bool {
+	return t >= 2 && t <= n && int(uint32(t)) == t
+}
+
+func decodeProtobuf(suite blsShare.Group, data []byte, v interface{}) error {
+	var (
+		point  kyber.Point
+		scalar kyber.Scalar
+	)
+	constructors := protobuf.Constructors{
+		reflect.TypeOf(&point).Elem():  func() interface{} { return suite.Point() },
+		reflect.TypeOf(&scalar).Elem(): func() interface{} { return suite.Scalar() },
+	}
+	return protobuf.DecodeWithConstructors(data, v, constructors)
+}
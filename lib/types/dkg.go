@@ -9,13 +9,43 @@ import (
 
 var (
 	ErrDKGVerifierNotReady = errors.New("verifier not ready yet")
+	ErrRoundNotFound       = errors.New("DKG round not found")
 )
 
+// RoundError is the failure of a DKG round. It is returned by the DKG
+// methods and fired with EventDKGRoundFailed.
+type RoundError struct {
+	RoundID int
+	Op      string // What failed, e.g. "start dealer".
+	Err     error
+	// Fallback is set if the retry policy gives up on off-chain DKG and
+	// the on-chain one is to be used.
+	Fallback bool
+}
+
+func (e *RoundError) Error() string {
+	return fmt.Sprintf("DKG round %d: %s: %v", e.RoundID, e.Op, e.Err)
+}
+
+func (e *RoundError) Unwrap() error {
+	return e.Err
+}
+
 type DKGDataMessage struct {
 	Data *alias.DKGData
 }
 
 func (m *DKGDataMessage) ValidateBasic() error {
+	if m.Data == nil {
+		return fmt.Errorf("%w: nil data", alias.ErrEmptyData)
+	}
+	if err := m.Data.ValidateBasic(); err != nil {
+		return err
+	}
+	// Off-chain messages must be signed by the validator.
+	if len(m.Data.Signature) == 0 {
+		return alias.ErrMissingSignature
+	}
 	return nil
 }
 
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/wire"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+)
+
+// EquivocationEvidence holds two conflicting signed messages sent by the same
+// participant for the same slot of a round, e.g. two different public keys or
+// two different deals for the same receiver.
+type EquivocationEvidence struct {
+	Addr   crypto.Address
+	First  *alias.DKGData
+	Second *alias.DKGData
+}
+
+// Verify checks the evidence against the validator set the round was run
+// with: both messages must be signed by the validator, take the same slot and
+// differ.
+func (ev *EquivocationEvidence) Verify(validators *tmtypes.ValidatorSet) error {
+	if ev.First == nil || ev.Second == nil {
+		return errors.New("evidence is incomplete")
+	}
+	for _, msg := range []*alias.DKGData{ev.First, ev.Second} {
+		if err := verifyMessage(validators, msg, ev.Addr); err != nil {
+			return err
+		}
+	}
+	if ev.First.Type != ev.Second.Type || ev.First.RoundID != ev.Second.RoundID {
+		return errors.New("messages are of different types or rounds")
+	}
+	first, ok := MessageSlot(ev.First)
+	if !ok {
+		return fmt.Errorf("messages of type %d can not conflict", ev.First.Type)
+	}
+	if second, ok := MessageSlot(ev.Second); !ok || first != second {
+		return errors.New("messages take different slots")
+	}
+	if bytes.Equal(ev.First.SignBytes(""), ev.Second.SignBytes("")) {
+		return errors.New("messages are identical")
+	}
+
+	return nil
+}
+
+// MessageSlot returns the slot the message takes among the messages of its
+// sender, type and round; a participant sends at most one message per slot.
+// The second value is false if the type allows any number of messages.
+func MessageSlot(msg *alias.DKGData) (string, bool) {
+	switch msg.Type {
+	case alias.DKGPubKey:
+		return "", true
+	case alias.DKGDeal:
+		return fmt.Sprint(msg.ToIndex), true
+	case alias.DKGResponse:
+		// A participant responds once to the deal of every other one.
+		_, kind, err := wire.Header(msg.Data)
+		if err != nil {
+			return "", false
+		}
+		switch kind {
+		case wire.KindRabinResponse:
+			resp, err := wire.DecodeResponse(msg.Data)
+			return fmt.Sprint(resp.Index), err == nil
+		case wire.KindPedersenResponse:
+			resp, err := wire.DecodePedersenResponse(msg.Data)
+			return fmt.Sprint(resp.Index), err == nil
+		}
+	case alias.DKGCommits:
+		// Off-chain participants send all of their commitments at once,
+		// on-chain ones send a message per commitment, ToIndex being its
+		// position in the polynomial.
+		_, kind, err := wire.Header(msg.Data)
+		switch {
+		case err != nil:
+			return "", false
+		case kind == wire.KindSecretCommits:
+			return "", true
+		case kind == wire.KindPoint:
+			return fmt.Sprint(msg.ToIndex), true
+		}
+	case alias.DKGJustification:
+		// Justifications answer any number of complaints, but the
+		// acknowledgement that closes them is sent once.
+		if len(msg.Data) == 0 {
+			return "ack", true
+		}
+	case alias.DKGComplaint, alias.DKGReconstructCommit:
+		// One message per dealer, whether there is anything to complain
+		// about or to reveal or not.
+		return fmt.Sprint(msg.ToIndex), true
+	}
+	return "", false
+}
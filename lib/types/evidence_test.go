@@ -0,0 +1,86 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/corestario/dkglib/lib/alias"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	tm "github.com/tendermint/tendermint/types"
+)
+
+func signed(t *testing.T, pv tmtypes.PrivValidator, msg *alias.DKGData) *alias.DKGData {
+	t.Helper()
+	msg.Addr = pv.GetPubKey().Address()
+	if err := pv.SignData("", msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestEquivocationEvidence(t *testing.T) {
+	pv, other := tm.NewMockPV(), tm.NewMockPV()
+	validators := tmtypes.NewValidatorSet([]*tmtypes.Validator{tm.NewValidator(pv.GetPubKey(), 1)})
+	key := func(data string) *alias.DKGData {
+		return signed(t, pv, &alias.DKGData{Type: alias.DKGPubKey, RoundID: 1, Data: []byte(data)})
+	}
+	deal := func(to int) *alias.DKGData {
+		return signed(t, pv, &alias.DKGData{Type: alias.DKGDeal, RoundID: 1, ToIndex: to, Data: []byte("deal")})
+	}
+
+	for name, tc := range map[string]struct {
+		ev  *EquivocationEvidence
+		err string
+	}{
+		"public keys": {ev: &EquivocationEvidence{First: key("a"), Second: key("b")}},
+		"deals":       {ev: &EquivocationEvidence{First: deal(2), Second: signed(t, pv, &alias.DKGData{Type: alias.DKGDeal, RoundID: 1, ToIndex: 2, Data: []byte("other")})}},
+		"incomplete":  {ev: &EquivocationEvidence{First: key("a")}, err: "incomplete"},
+		"forged signature": {
+			ev: &EquivocationEvidence{First: key("a"), Second: func() *alias.DKGData {
+				msg := key("b")
+				msg.Data = []byte("c")
+				return msg
+			}()},
+			err: "invalid DKG message signature",
+		},
+		"signed by another key": {
+			ev: &EquivocationEvidence{First: key("a"), Second: func() *alias.DKGData {
+				msg := signed(t, other, &alias.DKGData{Type: alias.DKGPubKey, RoundID: 1, Data: []byte("b")})
+				msg.Addr = pv.GetPubKey().Address()
+				return msg
+			}()},
+			err: "invalid DKG message signature",
+		},
+		"not a validator": {
+			ev: &EquivocationEvidence{
+				First:  signed(t, other, &alias.DKGData{Type: alias.DKGPubKey, RoundID: 1, Data: []byte("a")}),
+				Second: signed(t, other, &alias.DKGData{Type: alias.DKGPubKey, RoundID: 1, Data: []byte("b")}),
+			},
+			err: "can't find validator",
+		},
+		"same message": {ev: &EquivocationEvidence{First: key("a"), Second: key("a")}, err: "identical"},
+		"different rounds": {
+			ev:  &EquivocationEvidence{First: key("a"), Second: signed(t, pv, &alias.DKGData{Type: alias.DKGPubKey, RoundID: 2, Data: []byte("b")})},
+			err: "different types or rounds",
+		},
+		"different slots": {ev: &EquivocationEvidence{First: deal(1), Second: deal(2)}, err: "different slots"},
+		"no slots": {
+			ev: &EquivocationEvidence{
+				First:  signed(t, pv, &alias.DKGData{Type: alias.DKGJustification, RoundID: 1, Data: []byte("a")}),
+				Second: signed(t, pv, &alias.DKGData{Type: alias.DKGJustification, RoundID: 1, Data: []byte("b")}),
+			},
+			err: "can not conflict",
+		},
+	} {
+		if tc.ev.First != nil {
+			tc.ev.Addr = tc.ev.First.Addr
+		}
+		err := tc.ev.Verify(validators)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %v", name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: expected %q, got %v", name, tc.err, err)
+		}
+	}
+}
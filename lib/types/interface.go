@@ -5,17 +5,45 @@ import (
 	tmtypes "github.com/tendermint/tendermint/alias"
 	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/types"
+	"go.dedis.ch/kyber/v3/share"
 )
 
 type DKG interface {
-	HandleOffChainShare(dkgMsg *DKGDataMessage, height int64, validators *types.ValidatorSet, pubKey crypto.PubKey) (switchToOnChain bool)
-	CheckDKGTime(height int64, validators *types.ValidatorSet)
+	// HandleOffChainShare handles a message of an off-chain round. A failed
+	// round is reported with a *RoundError; switchToOnChain is set if the
+	// on-chain DKG is to be used instead.
+	HandleOffChainShare(dkgMsg *DKGDataMessage, height int64, validators *types.ValidatorSet, pubKey crypto.PubKey) (switchToOnChain bool, err error)
+	// CheckDKGTime starts the rounds that are due and checks the running
+	// ones for timeouts. A failed round is reported with a *RoundError.
+	CheckDKGTime(height int64, validators *types.ValidatorSet) error
 	SetVerifier(verifier Verifier)
 	Verifier() Verifier
 	MsgQueue() chan *DKGDataMessage
-	GetLosers() []*tmtypes.Validator
+	GetLosers() ([]*tmtypes.Validator, error)
+	// GetLoserProofs returns the losers of the current round along with the
+	// proofs of why they lost (see VerifyLoserProof). GetLosers does not
+	// discard them.
+	GetLoserProofs() []*LoserProof
+	// GetEvidence returns the verifiable proofs of misbehaviour collected in
+	// the current round.
+	GetEvidence() []*EquivocationEvidence
 	IsOnChain() bool
 	StartDKGRound(*alias.ValidatorSet) error
+	// StartReshareRound starts a round that moves the shares of the group key
+	// from oldValidators to validators, keeping the key. masterPubKey is the
+	// public polynomial of the key; if nil, the one of the node's latest
+	// verifier is used.
+	StartReshareRound(oldValidators, validators *alias.ValidatorSet, masterPubKey *share.PubPoly) error
+	// ScheduleReshareRound makes the next round a resharing one, see
+	// StartReshareRound. Every node is to schedule it before any of them
+	// starts the round.
+	ScheduleReshareRound(oldValidators, validators *alias.ValidatorSet, masterPubKey *share.PubPoly) error
+	// StartRefreshRound starts a round that refreshes the shares of the group
+	// key held by validators, keeping the key.
+	StartRefreshRound(validators *alias.ValidatorSet) error
+	// ScheduleRefreshRound makes the next round a refresh one, see
+	// ScheduleReshareRound.
+	ScheduleRefreshRound(validators *alias.ValidatorSet) error
 	NewBlockNotify()
 	ProcessBlock(roundID int) (error, bool)
 }
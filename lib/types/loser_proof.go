@@ -0,0 +1,267 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/corestario/dkglib/lib/alias"
+	"github.com/corestario/dkglib/lib/blsShare"
+	"github.com/corestario/dkglib/lib/wire"
+	tmtypes "github.com/tendermint/tendermint/alias"
+	"github.com/tendermint/tendermint/crypto"
+	"go.dedis.ch/kyber/v3"
+	dkgpedersen "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/rabin"
+	vss "go.dedis.ch/kyber/v3/share/vss/rabin"
+)
+
+// LoserReason tells why a participant has been excluded from a round.
+type LoserReason int
+
+const (
+	// LoserMalformedMessage: the participant sent a message that can not be
+	// decoded.
+	LoserMalformedMessage LoserReason = iota + 1
+	// LoserEquivocation: the participant sent two conflicting messages.
+	LoserEquivocation
+	// LoserInvalidDeal: the deal the participant sent to the complainer does
+	// not verify.
+	LoserInvalidDeal
+	// LoserCommitsMismatch: the commitments of the participant's deal differ
+	// from the ones it has published on chain.
+	LoserCommitsMismatch
+	// LoserMissingMessages: nothing has been received from the participant
+	// before the phase timed out.
+	LoserMissingMessages
+	// LoserNotQualified: the participant has been excluded from QUAL because
+	// of the complaints of other participants.
+	LoserNotQualified
+)
+
+var loserReasonNames = map[LoserReason]string{
+	LoserMalformedMessage: "malformed message",
+	LoserEquivocation:     "equivocation",
+	LoserInvalidDeal:      "invalid deal",
+	LoserCommitsMismatch:  "commits mismatch",
+	LoserMissingMessages:  "missing messages",
+	LoserNotQualified:     "not qualified",
+}
+
+func (r LoserReason) String() string {
+	if name, ok := loserReasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("LoserReason(%d)", int(r))
+}
+
+// ErrLoserProofUnverifiable is returned for the reasons that rest on messages
+// that have not been received, which can not be proven.
+var ErrLoserProofUnverifiable = errors.New("loser proof: the reason can not be proven")
+
+// LoserProof tells why a participant has been excluded from a round and holds
+// the signed messages that prove it.
+type LoserProof struct {
+	Addr    crypto.Address
+	Reason  LoserReason
+	RoundID int
+
+	// Message is the malformed message or the deal complained about.
+	Message *alias.DKGData
+	// Evidence holds the conflicting messages of an equivocation.
+	Evidence *EquivocationEvidence
+
+	// Complainer is the receiver of the deal; DHReveal is the key it shares
+	// with the dealer (see wire.DHReveal), which opens the deal.
+	Complainer crypto.Address
+	DHReveal   []byte
+	// PubKeys are the DKG public keys of all the validators: the deal is
+	// encrypted and verified in their context.
+	PubKeys []*alias.DKGData
+	// Commits are the commitments the dealer has published on chain.
+	Commits []*alias.DKGData
+}
+
+// VerifyLoserProof checks the proof against the validator set the round was
+// run with, on the pairing suite of the round. It only depends on its
+// arguments, so every node comes to the same result.
+func VerifyLoserProof(suite *blsShare.Suite, validators *tmtypes.ValidatorSet, proof *LoserProof) error {
+	if proof == nil {
+		return errors.New("loser proof is nil")
+	}
+	if _, validator := validators.GetByAddress(proof.Addr); validator == nil {
+		return fmt.Errorf("can't find validator by address: %s", proof.Addr)
+	}
+
+	switch proof.Reason {
+	case LoserMalformedMessage:
+		if err := verifyRoundMessage(validators, proof.Message, proof.Addr, proof.RoundID); err != nil {
+			return err
+		}
+		if CheckMessage(suite.G2, validators, proof.Message) == nil {
+			return errors.New("message is well-formed")
+		}
+		return nil
+	case LoserEquivocation:
+		ev := proof.Evidence
+		if ev == nil || ev.First == nil {
+			return errors.New("evidence is incomplete")
+		}
+		if !bytes.Equal(ev.Addr, proof.Addr) || ev.First.RoundID != proof.RoundID {
+			return errors.New("evidence is about another participant or round")
+		}
+		return ev.Verify(validators)
+	case LoserInvalidDeal, LoserCommitsMismatch:
+		return verifyDealProof(suite.G2, validators, proof)
+	case LoserMissingMessages, LoserNotQualified:
+		return ErrLoserProofUnverifiable
+	}
+	return fmt.Errorf("unknown loser reason %d", proof.Reason)
+}
+
+// verifyMessage checks that the message is sent and signed by the validator.
+func verifyMessage(validators *tmtypes.ValidatorSet, msg *alias.DKGData, from crypto.Address) error {
+	if msg == nil {
+		return errors.New("message is missing")
+	}
+	if !bytes.Equal(msg.Addr, from) {
+		return fmt.Errorf("message is sent by %s, not %s", msg.GetAddrString(), from)
+	}
+	_, validator := validators.GetByAddress(from)
+	if validator == nil {
+		return fmt.Errorf("can't find validator by address: %s", from)
+	}
+	if !validator.PubKey.VerifyBytes(msg.SignBytes(""), msg.Signature) {
+		return fmt.Errorf("invalid DKG message signature from %s", from)
+	}
+	return nil
+}
+
+func verifyRoundMessage(validators *tmtypes.ValidatorSet, msg *alias.DKGData, from crypto.Address, roundID int) error {
+	if err := verifyMessage(validators, msg, from); err != nil {
+		return err
+	}
+	if msg.RoundID != roundID {
+		return fmt.Errorf("message belongs to round %d, not %d", msg.RoundID, roundID)
+	}
+	return nil
+}
+
+// payloadKinds are the payloads each type of message may carry, off chain or
+// on chain.
+var payloadKinds = map[alias.DKGDataType]map[wire.Kind]bool{
+	alias.DKGPubKey:            {wire.KindPoint: true},
+	alias.DKGDeal:              {wire.KindRabinDeal: true, wire.KindPedersenDeal: true},
+	alias.DKGResponse:          {wire.KindRabinResponse: true, wire.KindPedersenResponse: true},
+	alias.DKGJustification:     {wire.KindRabinJustification: true, wire.KindPedersenJustification: true},
+	alias.DKGCommits:           {wire.KindSecretCommits: true, wire.KindPoint: true},
+	alias.DKGComplaint:         {wire.KindComplaintCommits: true},
+	alias.DKGReconstructCommit: {wire.KindReconstructCommits: true},
+}
+
+// CheckMessage runs CheckPayload and the checks that depend on the validator
+// set of the round: an acknowledgement can not announce more justifications
+// than there are validators.
+func CheckMessage(g kyber.Group, validators *tmtypes.ValidatorSet, msg *alias.DKGData) error {
+	if err := CheckPayload(g, msg); err != nil {
+		return err
+	}
+	if msg.Type == alias.DKGJustification && len(msg.Data) == 0 && msg.NumEntities > validators.Size() {
+		return fmt.Errorf("%d justifications announced for %d validators", msg.NumEntities, validators.Size())
+	}
+	return nil
+}
+
+// CheckPayload decodes the payload of the message the way the off-chain or
+// the on-chain dealer does, and checks that the parts the protocol relies on
+// are there. A signed message that fails it proves its sender malformed.
+func CheckPayload(g kyber.Group, msg *alias.DKGData) error {
+	if len(msg.Data) == 0 {
+		switch msg.Type {
+		case alias.DKGJustification, alias.DKGComplaint, alias.DKGReconstructCommit:
+			// Acknowledgements and void messages.
+			return nil
+		}
+		return errors.New("payload is empty")
+	}
+	_, kind, err := wire.Header(msg.Data)
+	if err != nil {
+		return err
+	}
+	if !payloadKinds[msg.Type][kind] {
+		return fmt.Errorf("unexpected payload kind %d for message of type %d", kind, msg.Type)
+	}
+
+	switch kind {
+	case wire.KindPoint:
+		_, err = wire.DecodePoint(g, msg.Data)
+	case wire.KindRabinDeal:
+		var d *dkg.Deal
+		if d, err = wire.DecodeDeal(g, msg.Data); err == nil && d.Deal == nil {
+			err = errors.New("deal is empty")
+		}
+	case wire.KindPedersenDeal:
+		var d *dkgpedersen.Deal
+		if d, err = wire.DecodePedersenDeal(msg.Data); err == nil && d.Deal == nil {
+			err = errors.New("deal is empty")
+		}
+	case wire.KindRabinResponse:
+		var resp *dkg.Response
+		if resp, err = wire.DecodeResponse(msg.Data); err == nil && resp.Response == nil {
+			err = errors.New("response is empty")
+		}
+	case wire.KindPedersenResponse:
+		var resp *dkgpedersen.Response
+		if resp, err = wire.DecodePedersenResponse(msg.Data); err == nil && resp.Response == nil {
+			err = errors.New("response is empty")
+		}
+	case wire.KindRabinJustification:
+		var j *dkg.Justification
+		if j, err = wire.DecodeJustification(g, msg.Data); err == nil {
+			if j.Justification == nil {
+				err = errors.New("justification is empty")
+			} else {
+				err = checkVSSDeal(j.Justification.Deal)
+			}
+		}
+	case wire.KindPedersenJustification:
+		var j *dkgpedersen.Justification
+		if j, err = wire.DecodePedersenJustification(g, msg.Data); err == nil {
+			if j.Justification == nil || j.Justification.Deal == nil || j.Justification.Deal.SecShare == nil {
+				err = errors.New("justification is incomplete")
+			}
+		}
+	case wire.KindSecretCommits:
+		_, err = wire.DecodeSecretCommits(g, msg.Data)
+	case wire.KindComplaintCommits:
+		var cc *dkg.ComplaintCommits
+		if cc, err = wire.DecodeComplaintCommits(g, msg.Data); err == nil {
+			if int(cc.DealerIndex) != msg.ToIndex {
+				err = fmt.Errorf("complaint about dealer #%d sent as about #%d", cc.DealerIndex, msg.ToIndex)
+			} else {
+				err = checkVSSDeal(cc.Deal)
+			}
+		}
+	case wire.KindReconstructCommits:
+		var rc *dkg.ReconstructCommits
+		if rc, err = wire.DecodeReconstructCommits(g, msg.Data); err == nil {
+			if int(rc.DealerIndex) != msg.ToIndex {
+				err = fmt.Errorf("reconstruct commits of dealer #%d sent as of #%d", rc.DealerIndex, msg.ToIndex)
+			} else if rc.Share == nil {
+				err = errors.New("reconstruct commits hold no share")
+			}
+		}
+	default:
+		err = fmt.Errorf("unexpected payload kind %d", kind)
+	}
+	return err
+}
+
+// checkVSSDeal checks that a deal revealed in a justification or a complaint
+// holds both shares.
+func checkVSSDeal(d *vss.Deal) error {
+	if d == nil || d.SecShare == nil || d.RndShare == nil {
+		return errors.New("deal is incomplete")
+	}
+	return nil
+}
@@ -18,6 +18,9 @@ const (
 	EventDKGReconstructCommitsProcessed = "DKGReconstructCommitsProcessed"
 	EventDKGSuccessful                  = "DKGSuccessful"
 	EventDKGKeyChange                   = "DKGKeyChange"
+	EventDKGRoundFailed                 = "DKGRoundFailed"
+	EventDKGOnChainAttempt              = "DKGOnChainAttempt"
+	EventDKGOnChainDone                 = "DKGOnChainDone"
 )
 
 type Verifier interface {
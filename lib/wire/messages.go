@@ -0,0 +1,333 @@
+package wire
+
+import (
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+	"go.dedis.ch/kyber/v3/share"
+	pedersen "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	rabin "go.dedis.ch/kyber/v3/share/dkg/rabin"
+	vsspedersen "go.dedis.ch/kyber/v3/share/vss/pedersen"
+	vss "go.dedis.ch/kyber/v3/share/vss/rabin"
+)
+
+// EncodePoint is used for public keys and on-chain commits.
+func EncodePoint(p kyber.Point) ([]byte, error) {
+	w := newWriter(KindPoint)
+	w.point(p)
+	return w.result()
+}
+
+func DecodePoint(g kyber.Group, data []byte) (kyber.Point, error) {
+	r := newReader(data, KindPoint, g)
+	p := r.point()
+	return p, r.finish()
+}
+
+func EncodeDeal(d *rabin.Deal) ([]byte, error) {
+	w := newWriter(KindRabinDeal)
+	w.uint32(d.Index)
+	if w.present(d.Deal != nil) {
+		w.point(d.Deal.DHKey)
+		w.bytes(d.Deal.Signature)
+		w.bytes(d.Deal.Nonce)
+		w.bytes(d.Deal.Cipher)
+	}
+	return w.result()
+}
+
+func DecodeDeal(g kyber.Group, data []byte) (*rabin.Deal, error) {
+	r := newReader(data, KindRabinDeal, g)
+	d := &rabin.Deal{Index: r.uint32()}
+	if r.present() {
+		d.Deal = &vss.EncryptedDeal{
+			DHKey:     r.point(),
+			Signature: r.bytes(),
+			Nonce:     r.bytes(),
+			Cipher:    r.bytes(),
+		}
+	}
+	return d, r.finish()
+}
+
+func EncodeResponse(resp *rabin.Response) ([]byte, error) {
+	w := newWriter(KindRabinResponse)
+	w.uint32(resp.Index)
+	if w.present(resp.Response != nil) {
+		w.bytes(resp.Response.SessionID)
+		w.uint32(resp.Response.Index)
+		w.bool(resp.Response.Approved)
+		w.bytes(resp.Response.Signature)
+	}
+	return w.result()
+}
+
+func DecodeResponse(data []byte) (*rabin.Response, error) {
+	r := newReader(data, KindRabinResponse, nil)
+	resp := &rabin.Response{Index: r.uint32()}
+	if r.present() {
+		resp.Response = &vss.Response{
+			SessionID: r.bytes(),
+			Index:     r.uint32(),
+			Approved:  r.bool(),
+			Signature: r.bytes(),
+		}
+	}
+	return resp, r.finish()
+}
+
+func EncodeJustification(j *rabin.Justification) ([]byte, error) {
+	w := newWriter(KindRabinJustification)
+	w.uint32(j.Index)
+	if w.present(j.Justification != nil) {
+		w.bytes(j.Justification.SessionID)
+		w.uint32(j.Justification.Index)
+		w.vssDeal(j.Justification.Deal)
+		w.bytes(j.Justification.Signature)
+	}
+	return w.result()
+}
+
+func DecodeJustification(g kyber.Group, data []byte) (*rabin.Justification, error) {
+	r := newReader(data, KindRabinJustification, g)
+	j := &rabin.Justification{Index: r.uint32()}
+	if r.present() {
+		j.Justification = &vss.Justification{
+			SessionID: r.bytes(),
+			Index:     r.uint32(),
+			Deal:      r.vssDeal(),
+			Signature: r.bytes(),
+		}
+	}
+	return j, r.finish()
+}
+
+func EncodeSecretCommits(sc *rabin.SecretCommits) ([]byte, error) {
+	w := newWriter(KindSecretCommits)
+	w.uint32(sc.Index)
+	w.points(sc.Commitments)
+	w.bytes(sc.SessionID)
+	w.bytes(sc.Signature)
+	return w.result()
+}
+
+func DecodeSecretCommits(g kyber.Group, data []byte) (*rabin.SecretCommits, error) {
+	r := newReader(data, KindSecretCommits, g)
+	sc := &rabin.SecretCommits{
+		Index:       r.uint32(),
+		Commitments: r.points(),
+		SessionID:   r.bytes(),
+		Signature:   r.bytes(),
+	}
+	return sc, r.finish()
+}
+
+func EncodeComplaintCommits(cc *rabin.ComplaintCommits) ([]byte, error) {
+	w := newWriter(KindComplaintCommits)
+	w.uint32(cc.Index)
+	w.uint32(cc.DealerIndex)
+	w.vssDeal(cc.Deal)
+	w.bytes(cc.Signature)
+	return w.result()
+}
+
+func DecodeComplaintCommits(g kyber.Group, data []byte) (*rabin.ComplaintCommits, error) {
+	r := newReader(data, KindComplaintCommits, g)
+	cc := &rabin.ComplaintCommits{
+		Index:       r.uint32(),
+		DealerIndex: r.uint32(),
+		Deal:        r.vssDeal(),
+		Signature:   r.bytes(),
+	}
+	return cc, r.finish()
+}
+
+func EncodeReconstructCommits(rc *rabin.ReconstructCommits) ([]byte, error) {
+	w := newWriter(KindReconstructCommits)
+	w.bytes(rc.SessionID)
+	w.uint32(rc.Index)
+	w.uint32(rc.DealerIndex)
+	w.priShare(rc.Share)
+	w.bytes(rc.Signature)
+	return w.result()
+}
+
+func DecodeReconstructCommits(g kyber.Group, data []byte) (*rabin.ReconstructCommits, error) {
+	r := newReader(data, KindReconstructCommits, g)
+	rc := &rabin.ReconstructCommits{
+		SessionID:   r.bytes(),
+		Index:       r.uint32(),
+		DealerIndex: r.uint32(),
+		Share:       r.priShare(),
+		Signature:   r.bytes(),
+	}
+	return rc, r.finish()
+}
+
+func EncodePedersenDeal(d *pedersen.Deal) ([]byte, error) {
+	w := newWriter(KindPedersenDeal)
+	w.uint32(d.Index)
+	if w.present(d.Deal != nil) {
+		w.bytes(d.Deal.DHKey)
+		w.bytes(d.Deal.Signature)
+		w.bytes(d.Deal.Nonce)
+		w.bytes(d.Deal.Cipher)
+	}
+	w.bytes(d.Signature)
+	return w.result()
+}
+
+func DecodePedersenDeal(data []byte) (*pedersen.Deal, error) {
+	r := newReader(data, KindPedersenDeal, nil)
+	d := &pedersen.Deal{Index: r.uint32()}
+	if r.present() {
+		d.Deal = &vsspedersen.EncryptedDeal{
+			DHKey:     r.bytes(),
+			Signature: r.bytes(),
+			Nonce:     r.bytes(),
+			Cipher:    r.bytes(),
+		}
+	}
+	d.Signature = r.bytes()
+	return d, r.finish()
+}
+
+func EncodePedersenResponse(resp *pedersen.Response) ([]byte, error) {
+	w := newWriter(KindPedersenResponse)
+	w.uint32(resp.Index)
+	if w.present(resp.Response != nil) {
+		w.bytes(resp.Response.SessionID)
+		w.uint32(resp.Response.Index)
+		w.bool(resp.Response.Status)
+		w.bytes(resp.Response.Signature)
+	}
+	return w.result()
+}
+
+func DecodePedersenResponse(data []byte) (*pedersen.Response, error) {
+	r := newReader(data, KindPedersenResponse, nil)
+	resp := &pedersen.Response{Index: r.uint32()}
+	if r.present() {
+		resp.Response = &vsspedersen.Response{
+			SessionID: r.bytes(),
+			Index:     r.uint32(),
+			Status:    r.bool(),
+			Signature: r.bytes(),
+		}
+	}
+	return resp, r.finish()
+}
+
+func EncodePedersenJustification(j *pedersen.Justification) ([]byte, error) {
+	w := newWriter(KindPedersenJustification)
+	w.uint32(j.Index)
+	if w.present(j.Justification != nil) {
+		w.bytes(j.Justification.SessionID)
+		w.uint32(j.Justification.Index)
+		if w.present(j.Justification.Deal != nil) {
+			deal := j.Justification.Deal
+			w.bytes(deal.SessionID)
+			w.priShare(deal.SecShare)
+			w.uint32(deal.T)
+			w.points(deal.Commitments)
+		}
+		w.bytes(j.Justification.Signature)
+	}
+	return w.result()
+}
+
+func DecodePedersenJustification(g kyber.Group, data []byte) (*pedersen.Justification, error) {
+	r := newReader(data, KindPedersenJustification, g)
+	j := &pedersen.Justification{Index: r.uint32()}
+	if r.present() {
+		j.Justification = &vsspedersen.Justification{
+			SessionID: r.bytes(),
+			Index:     r.uint32(),
+		}
+		if r.present() {
+			j.Justification.Deal = &vsspedersen.Deal{
+				SessionID:   r.bytes(),
+				SecShare:    r.priShare(),
+				T:           r.uint32(),
+				Commitments: r.points(),
+			}
+		}
+		j.Justification.Signature = r.bytes()
+	}
+	return j, r.finish()
+}
+
+// DHReveal discloses the Diffie-Hellman key a verifier shares with a dealer,
+// along with the proof that the key was computed with the verifier's longterm
+// key. It lets anyone open the deal the verifier complains about.
+type DHReveal struct {
+	Key   kyber.Point
+	Proof *dleq.Proof
+}
+
+func EncodeDHReveal(rev *DHReveal) ([]byte, error) {
+	if rev.Proof == nil {
+		return nil, errors.New("wire: nil DLEQ proof")
+	}
+	w := newWriter(KindDHReveal)
+	w.point(rev.Key)
+	w.scalar(rev.Proof.C)
+	w.scalar(rev.Proof.R)
+	w.point(rev.Proof.VG)
+	w.point(rev.Proof.VH)
+	return w.result()
+}
+
+func DecodeDHReveal(g kyber.Group, data []byte) (*DHReveal, error) {
+	r := newReader(data, KindDHReveal, g)
+	rev := &DHReveal{
+		Key: r.point(),
+		Proof: &dleq.Proof{
+			C:  r.scalar(),
+			R:  r.scalar(),
+			VG: r.point(),
+			VH: r.point(),
+		},
+	}
+	return rev, r.finish()
+}
+
+func (w *writer) vssDeal(d *vss.Deal) {
+	if !w.present(d != nil) {
+		return
+	}
+	w.bytes(d.SessionID)
+	w.priShare(d.SecShare)
+	w.priShare(d.RndShare)
+	w.uint32(d.T)
+	w.points(d.Commitments)
+}
+
+func (r *reader) vssDeal() *vss.Deal {
+	if !r.present() {
+		return nil
+	}
+	return &vss.Deal{
+		SessionID:   r.bytes(),
+		SecShare:    r.priShare(),
+		RndShare:    r.priShare(),
+		T:           r.uint32(),
+		Commitments: r.points(),
+	}
+}
+
+func (w *writer) priShare(s *share.PriShare) {
+	if !w.present(s != nil) {
+		return
+	}
+	w.uint32(uint32(s.I))
+	w.scalar(s.V)
+}
+
+func (r *reader) priShare() *share.PriShare {
+	if !r.present() {
+		return nil
+	}
+	return &share.PriShare{I: int(r.uint32()), V: r.scalar()}
+}
@@ -0,0 +1,255 @@
+// Package wire implements the binary encoding of the kyber objects carried in
+// DKGData.Data.
+//
+// Every payload starts with a two-byte header: the format version and the
+// payload kind. The body is a sequence of fields in the order they are
+// declared in the corresponding kyber structure:
+//
+//	uint32  4 bytes, big-endian
+//	bool    1 byte, 0 or 1
+//	bytes   uint32 length followed by the raw bytes
+//	point   bytes holding kyber.Point.MarshalBinary
+//	scalar  bytes holding kyber.Scalar.MarshalBinary
+//	list    uint32 number of items followed by the items
+//	*T      1 byte presence flag followed by T if the flag is 1
+//
+// The encoding is canonical: a value has exactly one representation, and
+// decoders reject trailing bytes.
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// Version is the current version of the format.
+const Version byte = 1
+
+// Kind tells which structure the payload holds.
+type Kind byte
+
+const (
+	KindPoint Kind = iota + 1
+	KindRabinDeal
+	KindRabinResponse
+	KindRabinJustification
+	KindSecretCommits
+	KindComplaintCommits
+	KindReconstructCommits
+	KindPedersenDeal
+	KindPedersenResponse
+	KindDHReveal
+	KindPedersenJustification
+)
+
+// maxListLen bounds the lists to protect decoders from huge allocations.
+const maxListLen = 1 << 16
+
+var ErrTrailingBytes = errors.New("wire: trailing bytes")
+
+// Header returns the version and the kind of the payload.
+func Header(data []byte) (version byte, kind Kind, err error) {
+	if len(data) < 2 {
+		return 0, 0, errors.New("wire: payload is too short")
+	}
+	return data[0], Kind(data[1]), nil
+}
+
+type writer struct {
+	buf bytes.Buffer
+	err error
+}
+
+func newWriter(kind Kind) *writer {
+	w := &writer{}
+	w.buf.WriteByte(Version)
+	w.buf.WriteByte(byte(kind))
+	return w
+}
+
+func (w *writer) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *writer) bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *writer) bytes(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *writer) present(ok bool) bool {
+	w.bool(ok)
+	return ok
+}
+
+func (w *writer) point(p kyber.Point) {
+	if p == nil {
+		w.setErr(errors.New("wire: nil point"))
+		return
+	}
+	b, err := p.MarshalBinary()
+	w.setErr(err)
+	w.bytes(b)
+}
+
+func (w *writer) scalar(s kyber.Scalar) {
+	if s == nil {
+		w.setErr(errors.New("wire: nil scalar"))
+		return
+	}
+	b, err := s.MarshalBinary()
+	w.setErr(err)
+	w.bytes(b)
+}
+
+func (w *writer) points(ps []kyber.Point) {
+	w.uint32(uint32(len(ps)))
+	for _, p := range ps {
+		w.point(p)
+	}
+}
+
+func (w *writer) setErr(err error) {
+	if w.err == nil && err != nil {
+		w.err = err
+	}
+}
+
+func (w *writer) result() ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf.Bytes(), nil
+}
+
+type reader struct {
+	data  []byte
+	group kyber.Group
+	err   error
+}
+
+func newReader(data []byte, kind Kind, group kyber.Group) *reader {
+	r := &reader{data: data, group: group}
+	version, got, err := Header(data)
+	switch {
+	case err != nil:
+		r.err = err
+	case version != Version:
+		r.err = fmt.Errorf("wire: unsupported version %d", version)
+	case got != kind:
+		r.err = fmt.Errorf("wire: unexpected payload kind %d, want %d", got, kind)
+	default:
+		r.data = data[2:]
+	}
+	return r
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errors.New("wire: unexpected end of payload")
+		return nil
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) bool() bool {
+	b := r.next(1)
+	if b == nil {
+		return false
+	}
+	if b[0] > 1 {
+		r.err = errors.New("wire: invalid bool")
+	}
+	return b[0] == 1
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	b := r.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (r *reader) present() bool {
+	return r.bool()
+}
+
+func (r *reader) point() kyber.Point {
+	b := r.bytes()
+	if r.err != nil {
+		return nil
+	}
+	p := r.group.Point()
+	if err := p.UnmarshalBinary(b); err != nil {
+		r.err = fmt.Errorf("wire: invalid point: %v", err)
+		return nil
+	}
+	return p
+}
+
+func (r *reader) scalar() kyber.Scalar {
+	b := r.bytes()
+	if r.err != nil {
+		return nil
+	}
+	s := r.group.Scalar()
+	if err := s.UnmarshalBinary(b); err != nil {
+		r.err = fmt.Errorf("wire: invalid scalar: %v", err)
+		return nil
+	}
+	return s
+}
+
+func (r *reader) points() []kyber.Point {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if n > maxListLen {
+		r.err = fmt.Errorf("wire: list is too long (%d)", n)
+		return nil
+	}
+	out := make([]kyber.Point, 0, n)
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		out = append(out, r.point())
+	}
+	return out
+}
+
+func (r *reader) finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) != 0 {
+		return ErrTrailingBytes
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+	"go.dedis.ch/kyber/v3/share"
+	pedersen "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	rabin "go.dedis.ch/kyber/v3/share/dkg/rabin"
+	vsspedersen "go.dedis.ch/kyber/v3/share/vss/pedersen"
+	vss "go.dedis.ch/kyber/v3/share/vss/rabin"
+)
+
+var group = bn256.NewSuiteG2()
+
+func point() kyber.Point   { return group.Point().Pick(group.RandomStream()) }
+func scalar() kyber.Scalar { return group.Scalar().Pick(group.RandomStream()) }
+
+func vssDeal() *vss.Deal {
+	return &vss.Deal{
+		SessionID:   []byte("session"),
+		SecShare:    &share.PriShare{I: 2, V: scalar()},
+		RndShare:    &share.PriShare{I: 2, V: scalar()},
+		T:           3,
+		Commitments: []kyber.Point{point(), point(), point()},
+	}
+}
+
+// codec encodes a sample of a message type and decodes payloads of the type.
+type codec struct {
+	kind   Kind
+	encode func() ([]byte, error)
+	decode func([]byte) (interface{}, error)
+	// reencode encodes a decoded value.
+	reencode func(interface{}) ([]byte, error)
+}
+
+func codecs(t *testing.T) map[string]codec {
+	proof, _, _, err := dleq.NewDLEQProof(group, group.Point().Base(), point(), scalar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]codec{
+		"point": {
+			kind:     KindPoint,
+			encode:   func() ([]byte, error) { return EncodePoint(point()) },
+			decode:   func(b []byte) (interface{}, error) { return DecodePoint(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodePoint(v.(kyber.Point)) },
+		},
+		"rabin deal": {
+			kind: KindRabinDeal,
+			encode: func() ([]byte, error) {
+				return EncodeDeal(&rabin.Deal{Index: 1, Deal: &vss.EncryptedDeal{
+					DHKey: point(), Signature: []byte("sig"), Nonce: []byte("nonce"), Cipher: []byte("cipher"),
+				}})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeDeal(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeDeal(v.(*rabin.Deal)) },
+		},
+		"rabin response": {
+			kind: KindRabinResponse,
+			encode: func() ([]byte, error) {
+				return EncodeResponse(&rabin.Response{Index: 1, Response: &vss.Response{
+					SessionID: []byte("session"), Index: 2, Approved: true, Signature: []byte("sig"),
+				}})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeResponse(b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeResponse(v.(*rabin.Response)) },
+		},
+		"rabin justification": {
+			kind: KindRabinJustification,
+			encode: func() ([]byte, error) {
+				return EncodeJustification(&rabin.Justification{Index: 1, Justification: &vss.Justification{
+					SessionID: []byte("session"), Index: 2, Deal: vssDeal(), Signature: []byte("sig"),
+				}})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeJustification(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeJustification(v.(*rabin.Justification)) },
+		},
+		"secret commits": {
+			kind: KindSecretCommits,
+			encode: func() ([]byte, error) {
+				return EncodeSecretCommits(&rabin.SecretCommits{
+					Index: 1, Commitments: []kyber.Point{point(), point()}, SessionID: []byte("session"), Signature: []byte("sig"),
+				})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeSecretCommits(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeSecretCommits(v.(*rabin.SecretCommits)) },
+		},
+		"complaint commits": {
+			kind: KindComplaintCommits,
+			encode: func() ([]byte, error) {
+				return EncodeComplaintCommits(&rabin.ComplaintCommits{
+					Index: 1, DealerIndex: 2, Deal: vssDeal(), Signature: []byte("sig"),
+				})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeComplaintCommits(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeComplaintCommits(v.(*rabin.ComplaintCommits)) },
+		},
+		"reconstruct commits": {
+			kind: KindReconstructCommits,
+			encode: func() ([]byte, error) {
+				return EncodeReconstructCommits(&rabin.ReconstructCommits{
+					SessionID: []byte("session"), Index: 1, DealerIndex: 2,
+					Share: &share.PriShare{I: 1, V: scalar()}, Signature: []byte("sig"),
+				})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodeReconstructCommits(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeReconstructCommits(v.(*rabin.ReconstructCommits)) },
+		},
+		"pedersen deal": {
+			kind: KindPedersenDeal,
+			encode: func() ([]byte, error) {
+				return EncodePedersenDeal(&pedersen.Deal{Index: 1, Deal: &vsspedersen.EncryptedDeal{
+					DHKey: []byte("key"), Signature: []byte("sig"), Nonce: []byte("nonce"), Cipher: []byte("cipher"),
+				}, Signature: []byte("sig")})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodePedersenDeal(b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodePedersenDeal(v.(*pedersen.Deal)) },
+		},
+		"pedersen response": {
+			kind: KindPedersenResponse,
+			encode: func() ([]byte, error) {
+				return EncodePedersenResponse(&pedersen.Response{Index: 1, Response: &vsspedersen.Response{
+					SessionID: []byte("session"), Index: 2, Status: true, Signature: []byte("sig"),
+				}})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodePedersenResponse(b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodePedersenResponse(v.(*pedersen.Response)) },
+		},
+		"pedersen justification": {
+			kind: KindPedersenJustification,
+			encode: func() ([]byte, error) {
+				return EncodePedersenJustification(&pedersen.Justification{Index: 1, Justification: &vsspedersen.Justification{
+					SessionID: []byte("session"),
+					Index:     2,
+					Deal: &vsspedersen.Deal{
+						SessionID: []byte("session"), SecShare: &share.PriShare{I: 2, V: scalar()},
+						T: 3, Commitments: []kyber.Point{point(), point(), point()},
+					},
+					Signature: []byte("sig"),
+				}})
+			},
+			decode:   func(b []byte) (interface{}, error) { return DecodePedersenJustification(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodePedersenJustification(v.(*pedersen.Justification)) },
+		},
+		"dh reveal": {
+			kind:     KindDHReveal,
+			encode:   func() ([]byte, error) { return EncodeDHReveal(&DHReveal{Key: point(), Proof: proof}) },
+			decode:   func(b []byte) (interface{}, error) { return DecodeDHReveal(group, b) },
+			reencode: func(v interface{}) ([]byte, error) { return EncodeDHReveal(v.(*DHReveal)) },
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, c := range codecs(t) {
+		data, err := c.encode()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if version, kind, err := Header(data); err != nil || version != Version || kind != c.kind {
+			t.Fatalf("%s: header %d/%d, %v", name, version, kind, err)
+		}
+		v, err := c.decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		again, err := c.reencode(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("%s: the decoded value encodes differently", name)
+		}
+	}
+}
+
+// The nil optional fields survive a round trip as well.
+func TestRoundTripAbsent(t *testing.T) {
+	data, err := EncodeJustification(&rabin.Justification{Index: 1, Justification: &vss.Justification{Index: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := DecodeJustification(group, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Justification == nil || j.Justification.Deal != nil || j.Justification.Index != 2 {
+		t.Fatalf("unexpected justification %+v", j.Justification)
+	}
+
+	data, err = EncodeDeal(&rabin.Deal{Index: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deal, err := DecodeDeal(group, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deal.Index != 3 || deal.Deal != nil {
+		t.Fatalf("unexpected deal %+v", deal)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for name, c := range codecs(t) {
+		data, err := c.encode()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for n := 0; n < len(data); n++ {
+			if _, err := c.decode(data[:n]); err == nil {
+				t.Fatalf("%s: a payload truncated to %d of %d bytes was accepted", name, n, len(data))
+			}
+		}
+	}
+}
+
+func TestTrailingBytes(t *testing.T) {
+	for name, c := range codecs(t) {
+		data, err := c.encode()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := c.decode(append(data, 0)); err != ErrTrailingBytes {
+			t.Fatalf("%s: expected ErrTrailingBytes, got %v", name, err)
+		}
+	}
+}
+
+func TestWrongHeader(t *testing.T) {
+	data, err := EncodePoint(point())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeResponse(data); err == nil || !strings.Contains(err.Error(), "unexpected payload kind") {
+		t.Fatalf("expected a kind error, got %v", err)
+	}
+	data[0] = Version + 1
+	if _, err := DecodePoint(group, data); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+}
+
+func TestOversizedLengths(t *testing.T) {
+	// A list longer than maxListLen is refused before anything is allocated.
+	w := newWriter(KindSecretCommits)
+	w.uint32(1)
+	w.uint32(maxListLen + 1)
+	data, err := w.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeSecretCommits(group, data); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected a list length error, got %v", err)
+	}
+
+	// A byte string claiming more bytes than there are.
+	data, err = EncodeResponse(&rabin.Response{Index: 1, Response: &vss.Response{SessionID: []byte("session")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint32(data[2+4+1:], 1<<31)
+	if _, err := DecodeResponse(data); err == nil || !strings.Contains(err.Error(), "unexpected end") {
+		t.Fatalf("expected an end of payload error, got %v", err)
+	}
+}
+
+func TestInvalidValues(t *testing.T) {
+	data, err := EncodeResponse(&rabin.Response{Index: 1, Response: &vss.Response{Approved: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header, index, presence, empty session ID, verifier index, approved.
+	data[2+4+1+4+4] = 2
+	if _, err := DecodeResponse(data); err == nil || !strings.Contains(err.Error(), "invalid bool") {
+		t.Fatalf("expected a bool error, got %v", err)
+	}
+
+	w := newWriter(KindPoint)
+	w.bytes([]byte("not a point"))
+	data, err = w.result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodePoint(group, data); err == nil || !strings.Contains(err.Error(), "invalid point") {
+		t.Fatalf("expected a point error, got %v", err)
+	}
+}